@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/moby/moby/client"
+	"github.com/pessolato/httpmicrobench/pkg/orchestration"
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// chaosRecorder writes the chaos events of a run to sink, which is
+// only set once the results directory of the run is created.
+type chaosRecorder struct {
+	sink io.WriteCloser
+}
+
+// steps returns the steps taking the containers targets down with action
+// and, if restartAfter is positive, starting them again restartAfter later.
+//
+// Every action is recorded as a [results.ChaosEvent] right
+// before it is taken, or after it when starting the containers.
+func (r *chaosRecorder) steps(action string, restartAfter time.Duration, targets ...*orchestration.Container) []orchestration.RunStep {
+	down := orchestration.ContainerStopStep(targets...)
+	if action == results.ChaosKill {
+		down = orchestration.ContainerKillStep("SIGKILL", targets...)
+	}
+	steps := []orchestration.RunStep{r.recordStep(action, targets...), down}
+	if restartAfter > 0 {
+		steps = append(steps,
+			orchestration.SleepStep(restartAfter),
+			orchestration.ContainerStartStep(targets...),
+			r.recordStep(results.ChaosStart, targets...),
+		)
+	}
+	return steps
+}
+
+// recordStep returns a RunStep recording a chaos event of action for each of the targets.
+func (r *chaosRecorder) recordStep(action string, targets ...*orchestration.Container) orchestration.RunStep {
+	return func(ctx context.Context, c *client.Client) error {
+		enc := json.NewEncoder(r.sink)
+		for _, t := range targets {
+			err := enc.Encode(results.ChaosEvent{Time: time.Now(), Container: t.Name, Action: action})
+			if err != nil {
+				return fmt.Errorf("error to record chaos event of %s container: %w", t.Name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// closeStep returns a RunStep closing the sink, if set.
+func (r *chaosRecorder) closeStep() orchestration.RunStep {
+	return func(ctx context.Context, c *client.Client) error {
+		if r.sink == nil {
+			return nil
+		}
+		return r.sink.Close()
+	}
+}
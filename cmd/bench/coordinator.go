@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/results"
+	"github.com/pessolato/httpmicrobench/pkg/worker"
+)
+
+// runCoordinator splits the requests of job, and its target rate, across the worker
+// agents listening at workers, sharing secret with them, and gathers the logs each
+// of them streams back into outDir. With a duration, every worker sends its
+// requests for the whole duration.
+//
+// Docker is not used, the workers send their requests to the target of job directly.
+// The progress of the run is written to out.
+func runCoordinator(ctx context.Context, workers []string, secret string, job worker.Job, outDir string, out io.Writer) error {
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return fmt.Errorf("error to create logs dir: %w", err)
+	}
+	manifest := results.Manifest{
+		CreatedAt:        time.Now(),
+		NumberOfRequests: job.NumberOfRequests,
+	}
+
+	shares := worker.Split(job.NumberOfRequests, len(workers))
+	logFs := make([]*os.File, len(workers))
+	for i, addr := range workers {
+		name := fmt.Sprintf("worker-%d", i)
+		logName := name + "-logs.jsonl"
+		logF, err := os.Create(filepath.Join(outDir, logName))
+		if err != nil {
+			return errors.Join(fmt.Errorf("error to create log file for worker %s: %w", addr, err), closeAll(logFs))
+		}
+		logFs[i] = logF
+		mc := results.ManifestContainer{
+			Name:    name,
+			Role:    results.RoleClient,
+			Target:  job.TargetEndpointURI,
+			LogFile: logName,
+		}
+		if job.Duration > 0 {
+			mc.DurationNano = job.Duration.Nanoseconds()
+		} else {
+			mc.NumberOfRequests = shares[i]
+		}
+		manifest.Containers = append(manifest.Containers, mc)
+	}
+	if err := results.WriteManifest(outDir, manifest); err != nil {
+		return errors.Join(err, closeAll(logFs))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(workers))
+	for i, addr := range workers {
+		if shares[i] == 0 && job.Duration == 0 {
+			continue
+		}
+		share := job
+		share.NumberOfRequests = shares[i]
+		share.TargetRPS = job.TargetRPS / float64(len(workers))
+		wg.Go(func() {
+			if share.Duration > 0 {
+				fmt.Fprintf(out, "sending requests for %s from worker %s\n", share.Duration, addr)
+			} else {
+				fmt.Fprintf(out, "sending %d requests from worker %s\n", share.NumberOfRequests, addr)
+			}
+			errs[i] = worker.Run(ctx, addr, secret, share, logFs[i])
+		})
+	}
+	wg.Wait()
+	return errors.Join(errors.Join(errs...), closeAll(logFs))
+}
+
+// closeAll closes the files that are not nil and joins their errors.
+func closeAll(fs []*os.File) error {
+	var errs error
+	for _, f := range fs {
+		if f != nil {
+			errs = errors.Join(errs, f.Close())
+		}
+	}
+	return errs
+}
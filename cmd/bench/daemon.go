@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// Statuses of a run started through the control API.
+const (
+	runRunning   = "running"
+	runSucceeded = "succeeded"
+	runFailed    = "failed"
+	runCanceled  = "canceled"
+)
+
+// errRunActive is returned when a run is started while another is still running.
+var errRunActive = errors.New("a run is still running")
+
+// daemonRun is a run started through the control API, or on the schedule of the daemon.
+type daemonRun struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	Scenario   benchConfig `json:"scenario"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	// Completed and Expected are the requests finished by the clients
+	// so far, failed ones included, and the requests of the whole run.
+	Completed int `json:"completed_requests"`
+	Expected  int `json:"expected_requests"`
+
+	dir    string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// apiScenario holds the options a run request of the control API can set, those of the
+// workload and its load shape, decoded with its name over the options of the daemon.
+//
+// The options of the builds, their toolchains, the images run and the files of the host
+// are left out, so the callers of the API can not run binaries or privileged containers
+// of their choosing on the host, or mount files of the host into the containers.
+type apiScenario struct {
+	Name              string        `json:"name"`
+	NumberOfRequests  int           `json:"number_of_requests"`
+	ResponseLength    int           `json:"response_length"`
+	Workers           []string      `json:"workers"`
+	TargetEndpointURI string        `json:"target_endpoint_uri"`
+	ExternalTargetURI string        `json:"external_target_uri"`
+	ExternalMaxRate   float64       `json:"external_max_rate"`
+	ExternalInFlight  int           `json:"external_concurrency"`
+	ExternalConfirm   bool          `json:"external_confirm"`
+	ExternalInsecure  bool          `json:"external_tls_insecure"`
+	ClientHTTPVersion int           `json:"client_http_version"`
+	MustDrainAndClose bool          `json:"must_drain_and_close"`
+	HTTPVersions      []string      `json:"http_versions"`
+	ClientTargetRPS   float64       `json:"client_target_rps"`
+	BenchDuration     time.Duration `json:"bench_duration"`
+	RequestTimeout    time.Duration `json:"request_timeout"`
+	ValidateResponses bool          `json:"validate_responses"`
+	DisableKeepAlive  bool          `json:"disable_keepalive"`
+	AbortErrorPercent float64       `json:"abort_error_percent"`
+	AbortErrorWindow  int           `json:"abort_error_window"`
+	SLOLatencies      []string      `json:"slo_latencies"`
+	SLOErrorPercent   float64       `json:"slo_max_error_percent"`
+	AcceptEncoding    []string      `json:"accept_encoding"`
+	ThinkTime         time.Duration `json:"think_time"`
+	ThinkTimeDist     string        `json:"think_time_distribution"`
+	GRPCClients       bool          `json:"grpc_clients"`
+	WebSocketClients  bool          `json:"websocket_clients"`
+	WebSocketConns    int           `json:"websocket_connections"`
+	ProxyClients      bool          `json:"proxy_clients"`
+	ProxyHTTPVersion  int           `json:"proxy_upstream_http_version"`
+	ProxyFlush        time.Duration `json:"proxy_flush_interval"`
+	PoolClients       bool          `json:"pool_clients"`
+	PoolConcurrency   int           `json:"pool_concurrency"`
+	PoolMaxConns      int           `json:"pool_max_conns_per_host"`
+	PoolMaxIdleConns  int           `json:"pool_max_idle_conns_per_host"`
+	PoolIdleTimeout   time.Duration `json:"pool_idle_conn_timeout"`
+	PoolMaxStreams    int           `json:"pool_max_concurrent_streams"`
+	DownloadClients   bool          `json:"download_clients"`
+	DownloadLength    int           `json:"download_length"`
+	DownloadRequests  int           `json:"download_requests"`
+	DownloadBuffers   []string      `json:"download_read_buffer_sizes"`
+	UploadClients     bool          `json:"upload_clients"`
+	UploadLength      int           `json:"upload_length"`
+	UploadRequests    int           `json:"upload_requests"`
+	PartialReadBytes  int           `json:"partial_read_bytes"`
+	PcapContainers    []string      `json:"pcap_containers"`
+	PcapFilter        string        `json:"pcap_filter"`
+	PerfContainers    []string      `json:"perf_containers"`
+	PerfAttachDelay   time.Duration `json:"perf_attach_delay"`
+	RuntimeMetrics    time.Duration `json:"runtime_metrics_interval"`
+	Tracing           bool          `json:"tracing"`
+	ChaosContainers   []string      `json:"chaos_containers"`
+	ChaosAction       string        `json:"chaos_action"`
+	ChaosAfter        time.Duration `json:"chaos_after"`
+	ChaosRestartAfter time.Duration `json:"chaos_restart_after"`
+	DNSServer         bool          `json:"dns_server"`
+	DNSTTL            time.Duration `json:"dns_ttl"`
+	DNSLatency        time.Duration `json:"dns_latency"`
+	DNSRotate         bool          `json:"dns_rotate"`
+	TLSSessionTickets bool          `json:"tls_session_tickets"`
+	TLS0RTT           bool          `json:"tls_0rtt"`
+}
+
+// daemon serves the control API, running one benchmark at a time.
+type daemon struct {
+	ctx       context.Context
+	defaults  benchConfig
+	outputDir string
+	token     string
+
+	mu     sync.Mutex
+	runs   map[string]*daemonRun
+	active *daemonRun
+	// latest holds the summary of the latest run of each scenario name, exported as metrics.
+	latest map[string]results.RunSummary
+}
+
+// serveDaemon serves the control API at addr until ctx is canceled, to the requests
+// authenticated with token as bearer token. Addresses without a host, e.g. :8090,
+// are served at the loopback interface only.
+//
+// The scenario of each run is decoded over cfg, so a run
+// request only sets the options it changes.
+//
+// If sched is not nil, the scenarios are also run one after
+// the other every time the schedule is due.
+func serveDaemon(ctx context.Context, addr, token string, cfg benchConfig, outputDir string, sched *schedule, scenarios []scheduledScenario) error {
+	d := &daemon{
+		ctx:       ctx,
+		defaults:  cfg,
+		outputDir: outputDir,
+		token:     token,
+		runs:      make(map[string]*daemonRun),
+		latest:    make(map[string]results.RunSummary),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", d.writeMetrics)
+	mux.HandleFunc("POST /runs", d.startRun)
+	mux.HandleFunc("GET /runs/{id}", d.getRun)
+	mux.HandleFunc("DELETE /runs/{id}", d.cancelRun)
+	mux.HandleFunc("GET /runs/{id}/results", d.listResults)
+	mux.HandleFunc("GET /runs/{id}/results/{file}", d.streamResult)
+
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	}
+	srv := &http.Server{Addr: addr, Handler: d.authenticate(mux)}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	if sched != nil {
+		go d.runSchedule(*sched, scenarios)
+	}
+	log.Printf("serving control API at %s ...", addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	// Wait for the active run to clean up its containers.
+	d.mu.Lock()
+	active := d.active
+	d.mu.Unlock()
+	if active != nil {
+		<-active.done
+	}
+	return nil
+}
+
+// runSchedule runs the scenarios, one after the other, every time the schedule
+// is due until the daemon is stopped. Scenarios due while another run is still
+// running are skipped.
+func (d *daemon) runSchedule(sched schedule, scenarios []scheduledScenario) {
+	for {
+		next := sched.next(time.Now())
+		if next.IsZero() {
+			log.Printf("schedule is never due, no scheduled runs are started")
+			return
+		}
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		for _, sc := range scenarios {
+			run, err := d.start(sc.Name, sc.benchConfig)
+			if err != nil {
+				log.Printf("skipping scheduled run of scenario %s: %s", sc.Name, err)
+				continue
+			}
+			log.Printf("started scheduled run %s of scenario %s", run.ID, sc.Name)
+			<-run.done
+			if d.ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// authenticate wraps the handler h responding unauthorized to the
+// requests without the token of the daemon as bearer token.
+func (d *daemon) authenticate(h http.Handler) http.Handler {
+	want := []byte("Bearer " + d.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// startRun starts a run from the scenario in the request body, named by its
+// optional name field, api by default, which labels the metrics of the run.
+//
+// Only the fields of [apiScenario] can be set, other fields are rejected.
+func (d *daemon) startRun(w http.ResponseWriter, r *http.Request) {
+	sc := scheduledScenario{Name: "api", benchConfig: d.defaults}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to read scenario: %s", err), http.StatusBadRequest)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&apiScenario{}); err != nil {
+			http.Error(w, fmt.Sprintf("invalid scenario: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := json.Unmarshal(body, &sc); err != nil {
+			http.Error(w, fmt.Sprintf("invalid scenario: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := validateScenario(sc.benchConfig); err != nil {
+		http.Error(w, fmt.Sprintf("invalid scenario: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	run, err := d.start(sc.Name, sc.benchConfig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Location", "/runs/"+run.ID)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// start starts a run of the scenario cfg, named name, unless another run is still running.
+//
+// Once the run is done, its summary is appended to the runs file of the output directory.
+func (d *daemon) start(name string, cfg benchConfig) (*daemonRun, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.active != nil {
+		return nil, fmt.Errorf("%w: %s", errRunActive, d.active.ID)
+	}
+
+	id := time.Now().Format("20060102150405")
+	if _, ok := d.runs[id]; ok {
+		return nil, errors.New("a run was started less than a second ago")
+	}
+	ctx, cancel := context.WithCancel(d.ctx)
+	run := &daemonRun{
+		ID:        id,
+		Name:      name,
+		Status:    runRunning,
+		Scenario:  cfg,
+		StartedAt: time.Now(),
+		dir:       filepath.Join(d.outputDir, id),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	d.runs[id] = run
+	d.active = run
+
+	go func() {
+		defer close(run.done)
+		defer cancel()
+		err := runBench(ctx, cfg, run.dir, os.Stderr)
+
+		d.mu.Lock()
+		now := time.Now()
+		run.FinishedAt = &now
+		switch {
+		case ctx.Err() != nil:
+			run.Status = runCanceled
+		case err != nil:
+			run.Status = runFailed
+		default:
+			run.Status = runSucceeded
+		}
+		if err != nil {
+			run.Error = err.Error()
+		}
+		d.active = nil
+		sum := results.RunSummary{
+			ID:         run.ID,
+			Scenario:   run.Name,
+			Status:     run.Status,
+			Error:      run.Error,
+			StartedAt:  run.StartedAt,
+			FinishedAt: now,
+		}
+		d.mu.Unlock()
+
+		// Runs failing before their containers are created have no clients to summarize.
+		if sum.Clients, err = results.SummarizeClients(run.dir); err != nil && sum.Status == runSucceeded {
+			log.Printf("unable to summarize the clients of run %s: %s", run.ID, err)
+		}
+		if err := results.AppendRunSummary(d.outputDir, sum); err != nil {
+			log.Printf("unable to record the summary of run %s: %s", run.ID, err)
+		}
+		d.mu.Lock()
+		d.latest[run.Name] = sum
+		d.mu.Unlock()
+	}()
+	return run, nil
+}
+
+// getRun writes the status and the progress of a run.
+func (d *daemon) getRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := d.run(w, r)
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	status := *run
+	d.mu.Unlock()
+	status.Completed, status.Expected = runProgress(status.dir)
+	writeJSON(w, http.StatusOK, &status)
+}
+
+// cancelRun cancels a run, its containers are still cleaned up.
+func (d *daemon) cancelRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := d.run(w, r)
+	if !ok {
+		return
+	}
+	run.cancel()
+	<-run.done
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listResults writes the names of the result files of a run.
+func (d *daemon) listResults(w http.ResponseWriter, r *http.Request) {
+	run, ok := d.run(w, r)
+	if !ok {
+		return
+	}
+	entries, err := os.ReadDir(run.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, e.Name())
+		}
+	}
+	writeJSON(w, http.StatusOK, files)
+}
+
+// streamResult writes the contents of a result file of a run.
+//
+// With the follow query parameter set to true, the lines written to the
+// file while the run goes on are streamed until the run is done.
+func (d *daemon) streamResult(w http.ResponseWriter, r *http.Request) {
+	run, ok := d.run(w, r)
+	if !ok {
+		return
+	}
+	f, err := os.Open(filepath.Join(run.dir, filepath.Base(r.PathValue("file"))))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	follow := r.URL.Query().Get("follow") == "true"
+	rc := http.NewResponseController(w)
+	for {
+		// Check before copying so the lines written right before the run is done are sent.
+		var done bool
+		select {
+		case <-run.done:
+			done = true
+		default:
+		}
+		if _, err := io.Copy(w, f); err != nil {
+			return
+		}
+		if !follow || done {
+			return
+		}
+		rc.Flush()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-run.done:
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// run looks up the run of the request, writing a not found response if it does not exist.
+func (d *daemon) run(w http.ResponseWriter, r *http.Request) (*daemonRun, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	run, ok := d.runs[r.PathValue("id")]
+	if !ok {
+		http.Error(w, fmt.Sprintf("run %s not found", r.PathValue("id")), http.StatusNotFound)
+	}
+	return run, ok
+}
+
+// validateScenario checks the options of a scenario that would make the run fail late.
+func validateScenario(cfg benchConfig) error {
+	var errs error
+	if cfg.NumberOfRequests < 1 {
+		errs = errors.Join(errs, errors.New("number_of_requests must be at least 1"))
+	}
+	if cfg.BenchDuration < 0 {
+		errs = errors.Join(errs, errors.New("bench_duration must not be negative"))
+	}
+	if cfg.RequestTimeout < 0 {
+		errs = errors.Join(errs, errors.New("request_timeout must not be negative"))
+	}
+	if cfg.ThinkTime < 0 {
+		errs = errors.Join(errs, errors.New("think_time must not be negative"))
+	}
+	if cfg.ThinkTime > 0 && cfg.ThinkTimeDist != "fixed" && cfg.ThinkTimeDist != "uniform" && cfg.ThinkTimeDist != "exponential" {
+		errs = errors.Join(errs, errors.New("think_time_distribution must be fixed, uniform or exponential"))
+	}
+	for _, enc := range cfg.AcceptEncoding {
+		if enc != "gzip" && enc != "br" {
+			errs = errors.Join(errs, fmt.Errorf("accept_encoding item %q must be gzip or br", enc))
+		}
+	}
+	if cfg.AbortErrorPercent < 0 || cfg.AbortErrorPercent > 100 {
+		errs = errors.Join(errs, errors.New("abort_error_percent must be between 0 and 100"))
+	}
+	if cfg.AbortErrorPercent > 0 && cfg.AbortErrorWindow < 1 {
+		errs = errors.Join(errs, errors.New("abort_error_window must be at least 1"))
+	}
+	for _, slo := range cfg.SLOLatencies {
+		if ok, _ := regexp.MatchString(sloLatencyPattern, slo); !ok {
+			errs = errors.Join(errs, fmt.Errorf("slo_latencies item %q must be written as p<percentile>=<duration>", slo))
+		}
+	}
+	if cfg.SLOErrorPercent < 0 || cfg.SLOErrorPercent > 100 {
+		errs = errors.Join(errs, errors.New("slo_max_error_percent must be between 0 and 100"))
+	}
+	if cfg.PartialReadBytes < 0 {
+		errs = errors.Join(errs, errors.New("partial_read_bytes must not be negative"))
+	}
+	if cfg.ResponseLength < 0 {
+		errs = errors.Join(errs, errors.New("response_length must not be negative"))
+	}
+	if cfg.ClientHTTPVersion != 1 && cfg.ClientHTTPVersion != 2 {
+		errs = errors.Join(errs, errors.New("client_http_version must be 1 or 2"))
+	}
+	if len(cfg.HTTPVersions) == 0 {
+		errs = errors.Join(errs, errors.New("http_versions must not be empty"))
+	}
+	for _, v := range cfg.HTTPVersions {
+		if v != "1" && v != "2" && v != "3" {
+			errs = errors.Join(errs, fmt.Errorf("http_versions item %q must be 1, 2 or 3", v))
+		}
+	}
+	if cfg.ProxyClients && (cfg.ProxyHTTPVersion < 1 || cfg.ProxyHTTPVersion > 3) {
+		errs = errors.Join(errs, errors.New("proxy_upstream_http_version must be 1, 2 or 3"))
+	}
+	if cfg.WebSocketClients && cfg.WebSocketConns < 1 {
+		errs = errors.Join(errs, errors.New("websocket_connections must be at least 1"))
+	}
+	if len(cfg.Workers) > 0 && cfg.TargetEndpointURI == "" {
+		errs = errors.Join(errs, errors.New("target_endpoint_uri is required with workers"))
+	}
+	if len(cfg.Workers) > 0 && cfg.WorkerSecret == "" {
+		errs = errors.Join(errs, errors.New("workers require the WORKER_SECRET of the daemon"))
+	}
+	if cfg.ExternalTargetURI != "" {
+		if cfg.ExternalMaxRate <= 0 {
+			errs = errors.Join(errs, errors.New("external_max_rate is required with external_target_uri"))
+		}
+		if cfg.ExternalInFlight < 1 || cfg.ExternalInFlight > maxExternalInFlight {
+			errs = errors.Join(errs, fmt.Errorf("external_concurrency must be between 1 and %d", maxExternalInFlight))
+		}
+		if !cfg.ExternalConfirm {
+			errs = errors.Join(errs, errors.New("external_confirm must be true to send requests to external_target_uri"))
+		}
+		if len(cfg.Workers) > 0 {
+			errs = errors.Join(errs, errors.New("external_target_uri can not be combined with workers"))
+		}
+		if cfg.ExternalCAFile != "" && cfg.ExternalInsecure {
+			errs = errors.Join(errs, errors.New("external_tls_ca_file and external_tls_insecure can not be set together"))
+		}
+	}
+	return errs
+}
+
+// runProgress counts the requests finished by the clients of the run in dir
+// and the requests expected from them, according to its manifest.
+func runProgress(dir string) (completed, expected int) {
+	m, err := results.ReadManifest(dir)
+	if err != nil {
+		// The manifest is not written yet while the binaries are built.
+		return 0, 0
+	}
+	for _, c := range m.Containers {
+		if c.Role != results.RoleClient || c.DurationNano != 0 {
+			// Clients sending their requests for a duration have no amount to expect.
+			continue
+		}
+		if c.NumberOfRequests != 0 {
+			expected += c.NumberOfRequests
+		} else {
+			expected += m.NumberOfRequests
+		}
+		completed += countFinishedRequests(filepath.Join(dir, c.LogFile))
+	}
+	return completed, expected
+}
+
+// countFinishedRequests counts the completed and failed requests in a client log file.
+func countFinishedRequests(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	var n int
+	scn := bufio.NewScanner(f)
+	for scn.Scan() {
+		if bytes.Contains(scn.Bytes(), []byte(`"msg":"req completion"`)) ||
+			bytes.Contains(scn.Bytes(), []byte(`"msg":"req failed"`)) {
+			n++
+		}
+	}
+	return n
+}
+
+// writeJSON writes v as the JSON body of a response with the status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+
+	"github.com/moby/moby/client"
+	"github.com/pessolato/httpmicrobench/pkg/orchestration"
+)
+
+// resolverStep returns a RunStep that sets the addresses of the resolvers, populated
+// by an address step, as the DNS servers of the clients, which must not be created yet.
+//
+// Docker only takes DNS servers by address. The clients keep resolving through the embedded
+// DNS of Docker, which forwards the queries of names other than containers to the resolvers.
+func resolverStep(resolvers []*orchestration.Container, clients ...*orchestration.Container) orchestration.RunStep {
+	return func(ctx context.Context, c *client.Client) error {
+		if len(resolvers) == 0 {
+			return nil
+		}
+		var addrs []string
+		for _, r := range resolvers {
+			addrs = append(addrs, r.IPAddress)
+		}
+		for _, cli := range clients {
+			cli.HostConfig.DNS = addrs
+		}
+		return nil
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/orchestration"
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/results"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/client"
+)
+
+const (
+	// externalClient is the name of the client container of the external-target mode.
+	externalClient = clientRsrc + "-external"
+	// maxExternalInFlight is the most requests sent concurrently to an external target.
+	maxExternalInFlight = 64
+	// externalCAPath is where EXTERNAL_TLS_CA_FILE is mounted in the client container.
+	externalCAPath = "/etc/httpmicrobench/ca.pem"
+)
+
+// errExternalNotConfirmed is returned when the external-target mode is not confirmed.
+var errExternalNotConfirmed = errors.New("requests are only sent to EXTERNAL_TARGET_URI with EXTERNAL_CONFIRM=true, make sure the owners of the service expect the load")
+
+// checkExternal checks the options the external-target mode can not run without.
+func checkExternal(cfg benchConfig) error {
+	var errs error
+	if cfg.ExternalMaxRate == 0 {
+		errs = errors.Join(errs, &osutil.ErrMissingVar{Name: "EXTERNAL_MAX_RATE"})
+	}
+	if !cfg.ExternalConfirm {
+		errs = errors.Join(errs, errExternalNotConfirmed)
+	}
+	if len(cfg.Workers) > 0 {
+		errs = errors.Join(errs, errors.New("EXTERNAL_TARGET_URI can not be combined with WORKERS"))
+	}
+	if cfg.ExternalCAFile != "" && cfg.ExternalInsecure {
+		errs = errors.Join(errs, errors.New("EXTERNAL_TLS_CA_FILE and EXTERNAL_TLS_INSECURE can not be set together"))
+	}
+	if cfg.ExternalToken != "" && (cfg.ExternalUser != "" || cfg.ExternalPass != "") {
+		errs = errors.Join(errs, errors.New("EXTERNAL_AUTH_BEARER_TOKEN and EXTERNAL_AUTH_BASIC_USER can not be set together"))
+	}
+	return errs
+}
+
+// runExternal has a single client container send the requests of the run to the
+// external target of cfg, at most at its rate and concurrency, and writes its logs
+// and stats into outDir. No server, nor any other container, is created.
+//
+// The progress of the run is written to out.
+func runExternal(ctx context.Context, cfg benchConfig, buildOpts osutil.GoBuildOpts, buildCacheDir, outDir string, out io.Writer) error {
+	var clientBuild orchestration.GoBuild
+	var clientImgSpec orchestration.Image
+	var benchNetwork orchestration.Network
+	containers := make([]*orchestration.Container, 1)
+	orch, err := orchestration.NewDockerOrchestrator()
+	if err != nil {
+		return err
+	}
+	defer orch.Close()
+
+	return orch.WithPreRunStep(
+		func(ctx context.Context, c *client.Client) error {
+			clientImgSpec = orchestration.Image{
+				Tag:          cfg.ResourcePrefix + clientImg,
+				Rebuild:      cfg.ForceImageRebuild,
+				Platform:     buildOpts.GOOS + "/" + buildOpts.GOARCH,
+				OpenBuildCtx: clientBuild.Context,
+			}
+			benchNetwork = orchestration.Network{
+				Name: cfg.ResourcePrefix + netName,
+			}
+			clientBuild = orchestration.GoBuild{
+				PkgPath:       clientPkgPath,
+				Dest:          clientGoBuildDest,
+				Opts:          withToolchain(buildOpts, cfg.ClientGoToolchain),
+				BuildCtxSpecs: buildCtxSpecs(clientGoBuildDest),
+				CacheDir:      buildCacheDir,
+			}
+			return nil
+		},
+		orchestration.GoBuildStep(&clientBuild),
+		orchestration.EnsureImageStep(&clientImgSpec),
+		orchestration.EnsureNetworkStep(&benchNetwork),
+	).
+		WithRunStep(
+			func(ctx context.Context, c *client.Client) error {
+				if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+					return fmt.Errorf("error to create logs dir: %w", err)
+				}
+				mc := results.ManifestContainer{
+					Name:     externalClient,
+					Role:     results.RoleClient,
+					Target:   cfg.ExternalTargetURI,
+					LogFile:  externalClient + "-logs.jsonl",
+					StatFile: externalClient + "-stats.jsonl",
+				}
+				logF, err := os.Create(filepath.Join(outDir, mc.LogFile))
+				if err != nil {
+					return fmt.Errorf("error to create log file for %s container: %w", mc.Name, err)
+				}
+				statF, err := os.Create(filepath.Join(outDir, mc.StatFile))
+				if err != nil {
+					return errors.Join(fmt.Errorf("error to create stat file for %s container: %w", mc.Name, err), logF.Close())
+				}
+				containers[0] = &orchestration.Container{
+					Name: mc.Name,
+					Config: container.Config{
+						Image: clientImg,
+						Env: []string{
+							fmt.Sprintf("TARGET_ENDPOINT_URI=%s", cfg.ExternalTargetURI),
+							fmt.Sprintf("CLIENT_HTTP_VERSION=%d", cfg.ClientHTTPVersion),
+							fmt.Sprintf("MUST_DRAIN_AND_CLOSE=%t", cfg.MustDrainAndClose),
+							fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.NumberOfRequests),
+							fmt.Sprintf("CONCURRENCY=%d", cfg.ExternalInFlight),
+							"MAX_RATE=" + strconv.FormatFloat(cfg.ExternalMaxRate, 'f', -1, 64),
+							fmt.Sprintf("TLS_INSECURE=%t", cfg.ExternalInsecure),
+							fmt.Sprintf("REQUEST_TIMEOUT=%s", cfg.RequestTimeout),
+						},
+					},
+					Network: network.NetworkingConfig{
+						EndpointsConfig: endpointConfig(benchNetwork),
+					},
+					LogSink:  logF,
+					StatSink: statF,
+				}
+				if cfg.ExternalCAFile != "" {
+					caFile, err := filepath.Abs(cfg.ExternalCAFile)
+					if err != nil {
+						return errors.Join(fmt.Errorf("error to locate TLS CA file: %w", err), logF.Close(), statF.Close())
+					}
+					containers[0].Config.Env = append(containers[0].Config.Env, "TLS_CA_FILE="+externalCAPath)
+					containers[0].HostConfig.Binds = []string{caFile + ":" + externalCAPath + ":ro"}
+				}
+				if cfg.AbortErrorPercent > 0 {
+					containers[0].Config.Env = append(containers[0].Config.Env, abortEnv(cfg)...)
+				}
+				containers[0].Config.Env = append(containers[0].Config.Env, sloEnv(cfg)...)
+				// Left out of the scenarios and their runs, as they are secrets.
+				if cfg.ExternalToken != "" {
+					containers[0].Config.Env = append(containers[0].Config.Env, "AUTH_BEARER_TOKEN="+cfg.ExternalToken)
+				}
+				if cfg.ExternalUser != "" || cfg.ExternalPass != "" {
+					containers[0].Config.Env = append(containers[0].Config.Env,
+						"AUTH_BASIC_USER="+cfg.ExternalUser, "AUTH_BASIC_PASS="+cfg.ExternalPass)
+				}
+				fmt.Fprintf(out, "sending %d requests to %s, at most %g per second and %d at a time\n",
+					cfg.NumberOfRequests, cfg.ExternalTargetURI, cfg.ExternalMaxRate, cfg.ExternalInFlight)
+				return results.WriteManifest(outDir, results.Manifest{
+					CreatedAt:        time.Now(),
+					NumberOfRequests: cfg.NumberOfRequests,
+					Containers:       []results.ManifestContainer{mc},
+					Artifacts: []results.ManifestArtifact{{
+						Name:          clientRsrc,
+						GoVersion:     clientBuild.GoVersion,
+						BinarySHA256:  clientBuild.BinarySHA256,
+						ContextSHA256: clientBuild.ContextSHA256,
+					}},
+				})
+			},
+			orchestration.ContainerCreateStep(containers...),
+			orchestration.ContainerStreamStatStep(out, containers...),
+			orchestration.ContainerStartStep(containers...),
+			orchestration.ContainerLogStep(out, containers...),
+			orchestration.ContainerWaitStep(out, containers...),
+		).
+		WithPosRunStep(
+			orchestration.ContainerStopStep(containers...),
+			orchestration.ContainerRemoveStep(containers...),
+			orchestration.EnsureContainerSinkCloseStep(containers...),
+		).
+		Run(ctx)
+}
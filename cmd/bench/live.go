@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/client"
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// liveResults incrementally reads the results of a run while they are
+// written, for the dashboards to show the run as it goes on.
+//
+// It is safe for concurrent use.
+type liveResults struct {
+	dir string
+
+	mu       sync.Mutex
+	manifest *results.Manifest
+	tails    map[string]*resultTail
+}
+
+func newLiveResults(dir string) *liveResults {
+	return &liveResults{dir: dir, tails: make(map[string]*resultTail)}
+}
+
+// read reads the manifest, once written, and the results written since the last read.
+func (lr *liveResults) read() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if lr.manifest == nil {
+		m, err := results.ReadManifest(lr.dir)
+		if err != nil {
+			// The manifest is not written yet while the binaries are built.
+			return
+		}
+		lr.manifest = &m
+		for _, c := range m.Containers {
+			for _, name := range []string{c.LogFile, c.StatFile} {
+				if name != "" {
+					lr.tails[name] = &resultTail{path: filepath.Join(lr.dir, name)}
+				}
+			}
+		}
+	}
+	for _, t := range lr.tails {
+		t.read()
+	}
+}
+
+// view calls f with the manifest, nil until it is written, and the tails
+// of the result files, which must not be retained after f returns.
+func (lr *liveResults) view(f func(m *results.Manifest, tails map[string]*resultTail)) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	f(lr.manifest, lr.tails)
+}
+
+// expectedRequests returns the amount of requests the client c of the run described by m sends.
+func expectedRequests(m *results.Manifest, c results.ManifestContainer) int {
+	if c.NumberOfRequests != 0 {
+		return c.NumberOfRequests
+	}
+	return m.NumberOfRequests
+}
+
+// seriesPoint is the value of a time series at a given second.
+type seriesPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// maxSeriesPoints is the amount of latest points of a time series kept for the
+// dashboards, an hour of them at one per second, the older ones are dropped.
+const maxSeriesPoints = 3600
+
+// series is a ring buffer of the latest [maxSeriesPoints] points of a time series.
+type series struct {
+	points []seriesPoint
+	// next is the index of the oldest point, overwritten by the next one, once the buffer is full.
+	next int
+}
+
+// add appends p to the series, dropping the oldest point if the series is full.
+func (s *series) add(p seriesPoint) {
+	if len(s.points) < maxSeriesPoints {
+		s.points = append(s.points, p)
+		return
+	}
+	s.points[s.next] = p
+	s.next = (s.next + 1) % maxSeriesPoints
+}
+
+// last returns the latest point of the series, nil if it is empty.
+func (s *series) last() *seriesPoint {
+	if len(s.points) == 0 {
+		return nil
+	}
+	return &s.points[(s.next+len(s.points)-1)%len(s.points)]
+}
+
+// snapshot returns a copy of the points of the series, from the oldest to the latest.
+func (s *series) snapshot() []seriesPoint {
+	points := make([]seriesPoint, 0, len(s.points))
+	points = append(points, s.points[s.next:]...)
+	return append(points, s.points[:s.next]...)
+}
+
+// resultTail incrementally reads a result file while it is written,
+// accumulating what the dashboards show of it.
+type resultTail struct {
+	path    string
+	offset  int64
+	partial []byte
+
+	// Client logs, latencyMean holds the mean latency of each second in nanoseconds.
+	finished    int
+	latencies   client.Histogram
+	latencyMean series
+	secondSum   int64
+	secondCount int64
+	// Container stats, the latest CPU usage in percent, the online CPUs and the CPU usage of each read.
+	cpu      float64
+	cpus     int64
+	cpuUsage series
+	// Any other file.
+	lastLines []string
+}
+
+// read reads the complete lines written to the file since the last read.
+func (t *resultTail) read() {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return
+	}
+	t.offset += int64(len(b))
+
+	b = append(t.partial, b...)
+	lines := bytes.Split(b, []byte("\n"))
+	t.partial = slices.Clone(lines[len(lines)-1])
+	for _, l := range lines[:len(lines)-1] {
+		t.readLine(l)
+	}
+}
+
+func (t *resultTail) readLine(l []byte) {
+	switch {
+	case strings.HasSuffix(t.path, "logs.jsonl"):
+		var e struct {
+			Time        time.Time `json:"time"`
+			Msg         string    `json:"msg"`
+			MaxTimeNano int64     `json:"max_time_nano"`
+		}
+		if json.Unmarshal(l, &e) != nil {
+			return
+		}
+		switch e.Msg {
+		case "req completion":
+			t.finished++
+			t.latencies.Record(e.MaxTimeNano)
+			t.addLatency(e.Time.Truncate(time.Second), e.MaxTimeNano)
+		case "req failed":
+			t.finished++
+		}
+	case strings.HasSuffix(t.path, "stats.jsonl"):
+		var e statEntry
+		if json.Unmarshal(l, &e) != nil {
+			return
+		}
+		cpuDelta := e.CPUStats.CPUUsage.TotalUsage - e.PrecpuStats.CPUUsage.TotalUsage
+		sysCpuDelta := e.CPUStats.SystemCPUUsage - e.PrecpuStats.SystemCPUUsage
+		if sysCpuDelta == 0 || e.CPUStats.OnlineCpus == 0 {
+			return
+		}
+		t.cpus = e.CPUStats.OnlineCpus
+		t.cpu = float64(cpuDelta) / float64(sysCpuDelta) * float64(t.cpus) * 100
+		t.cpuUsage.add(seriesPoint{Time: e.Read, Value: t.cpu})
+	default:
+		t.lastLines = append(t.lastLines, string(l))
+		if len(t.lastLines) > 5 {
+			t.lastLines = t.lastLines[1:]
+		}
+	}
+}
+
+// addLatency accounts the latency of a request completed at the second sec.
+func (t *resultTail) addLatency(sec time.Time, nano int64) {
+	last := t.latencyMean.last()
+	if last == nil || !last.Time.Equal(sec) {
+		t.latencyMean.add(seriesPoint{Time: sec})
+		t.secondSum, t.secondCount = 0, 0
+		last = t.latencyMean.last()
+	}
+	t.secondSum += nano
+	t.secondCount++
+	last.Value = float64(t.secondSum) / float64(t.secondCount)
+}
+
+// percentiles returns the 50th and 99th percentiles of the request latencies read so far,
+// from a histogram of them, so they take as long to compute however long the run is.
+func (t *resultTail) percentiles() (p50, p99 int64) {
+	if t == nil {
+		return 0, 0
+	}
+	return t.latencies.Quantile(0.50), t.latencies.Quantile(0.99)
+}
+
+// statEntry holds the fields of a container stats line the dashboards use.
+type statEntry struct {
+	Read     time.Time `json:"read"`
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage int64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		SystemCPUUsage int64 `json:"system_cpu_usage"`
+		OnlineCpus     int64 `json:"online_cpus"`
+	} `json:"cpu_stats"`
+	PrecpuStats struct {
+		CPUUsage struct {
+			TotalUsage int64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		SystemCPUUsage int64 `json:"system_cpu_usage"`
+	} `json:"precpu_stats"`
+}
@@ -1,17 +1,27 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/pessolato/httpmicrobench/pkg/orchestration"
 	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/results"
+	"github.com/pessolato/httpmicrobench/pkg/runtimemetrics"
+	"github.com/pessolato/httpmicrobench/pkg/worker"
 
 	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/api/types/network"
@@ -19,12 +29,15 @@ import (
 )
 
 const (
-	netName     = "http-bench-network"
-	clientRsrc  = "client"
-	serverRsrc  = "server"
-	imgTag      = ":latest"
-	goBuildDest = "./build/bin/"
-	pkgBasePath = "./cmd/"
+	netName      = "http-bench-network"
+	clientRsrc   = "client"
+	serverRsrc   = "server"
+	proxyRsrc    = "proxy"
+	dnsRsrc      = "dns"
+	imgTag       = ":latest"
+	goBuildDest  = "./build/bin/"
+	goBuildCache = "./build/cache/"
+	pkgBasePath  = "./cmd/"
 
 	clientImg         = clientRsrc + imgTag
 	clientPkgPath     = pkgBasePath + clientRsrc + "/"
@@ -32,161 +45,1490 @@ const (
 	serverImg         = serverRsrc + imgTag
 	serverPkgPath     = pkgBasePath + serverRsrc + "/"
 	serverGoBuildDest = goBuildDest + serverRsrc
+	proxyImg          = proxyRsrc + imgTag
+	proxyPkgPath      = pkgBasePath + proxyRsrc + "/"
+	proxyGoBuildDest  = goBuildDest + proxyRsrc
+	dnsImg            = dnsRsrc + imgTag
+	dnsPkgPath        = pkgBasePath + dnsRsrc + "/"
+	dnsGoBuildDest    = goBuildDest + dnsRsrc
+	pluginImg         = pluginClient + imgTag
+	pluginGoBuildDest = goBuildDest + "plugin"
+	// pluginAppDest is where the client is built to for the workload plugin image.
+	pluginAppDest = goBuildDest + pluginClient
 
-	// totalContainers is the total containers the test will create.
-	//
-	// 4 clients for each combination of HTTP version and whether to drain
-	// the response body before closing it or not.
+	// httpServers is the amount of HTTP server containers the test will create.
 	//
 	// 2 servers to measure stats on the server when body is drained or not.
-	totalContainers = 6
+	httpServers = 2
+
+	// grpcServer is the name of the server container gRPC clients send their calls to.
+	grpcServer = serverRsrc + "-grpc"
+
+	// h3Port is the UDP port the HTTP/3 server of every server container listens at.
+	h3Port = "8443"
+	// metricsPort is the port the binaries serve their Go runtime metrics at.
+	metricsPort = "6060/tcp"
+	// quicBufferSize is the size of the UDP socket buffers quic-go tries to
+	// set, lower host limits reduce the HTTP/3 throughput and skew the results.
+	quicBufferSize = 7 << 20
+	// wsClient and wsServer are the names of the WebSocket client and server containers.
+	wsClient = clientRsrc + "-websocket"
+	wsServer = serverRsrc + "-ws"
+	// upstreamServer is the name of the server container the proxy forwards the requests to.
+	upstreamServer = serverRsrc + "-upstream"
+	// pluginClient and pluginServer are the names of the workload plugin client and server containers.
+	pluginClient = clientRsrc + "-plugin"
+	pluginServer = serverRsrc + "-plugin"
+	// poolClient and poolServer are the prefix of the names of the connection
+	// pool saturation client containers, and the name of their server container.
+	poolClient = clientRsrc + "-pool"
+	poolServer = serverRsrc + "-pool"
+	// downloadClient and downloadServer are the prefixes of the names of the
+	// large download client containers and of their server containers.
+	downloadClient = clientRsrc + "-download"
+	downloadServer = serverRsrc + "-download"
+	// uploadClient and uploadServer are the prefixes of the names of the
+	// upload client containers and of their server containers.
+	uploadClient = clientRsrc + "-upload"
+	uploadServer = serverRsrc + "-upload"
+	// partialClient and partialServer are the prefix of the names of the partial
+	// read client containers, and the name of their server container.
+	partialClient = clientRsrc + "-partial"
+	partialServer = serverRsrc + "-partial"
+	// dnsZone is the domain the DNS server answers the names of the servers in.
+	dnsZone = "bench.test"
+	// sloLatencyPattern is the form of the latency objectives of the clients, e.g. p99=20ms.
+	sloLatencyPattern = `^p[0-9.]+=.+$`
 )
 
+// poolVersions are the HTTP versions of the connection pool saturation client containers,
+// one for each, created with POOL_CLIENTS. HTTP/3 clients do not limit their connections.
+var poolVersions = []string{"1", "2"}
+
+// uploadModes are how the upload client containers, one for each, created with
+// UPLOAD_CLIENTS, send their request bodies, set by the environment variables of each.
+var uploadModes = []struct {
+	name string
+	path string // of the endpoint of the server the bodies are sent to
+	env  []string
+}{
+	{"length", "/sink/", nil},
+	{"chunked", "/sink/", []string{"UPLOAD_CHUNKED=true"}},
+	{"expect", "/sink/", []string{"EXPECT_CONTINUE=true"}},
+	{"multipart", "/multipart/", []string{"UPLOAD_MULTIPART=true"}},
+}
+
+// grpcModes are the modes of the gRPC client containers, one for each, created with GRPC_CLIENTS.
+var grpcModes = []string{"grpc-unary", "grpc-stream"}
+
+// benchConfig holds the options of a benchmark run.
+//
+// In the daemon mode it is also the scenario of a run request, whose
+// fields override the options the daemon was started with.
+type benchConfig struct {
+	ResourcePrefix    string        `json:"resource_prefix"`
+	NumberOfRequests  int           `json:"number_of_requests"`
+	ResponseLength    int           `json:"response_length"`
+	ForceImageRebuild bool          `json:"force_image_rebuild"`
+	BuildTags         []string      `json:"build_tags"`
+	BuildLDFlags      string        `json:"build_ldflags"`
+	TargetGOARCH      string        `json:"target_goarch"`
+	BuildTimeout      time.Duration `json:"-"`
+	ClientGoToolchain string        `json:"client_go_toolchain"`
+	ServerGoToolchain string        `json:"server_go_toolchain"`
+	DisableBuildCache bool          `json:"disable_build_cache"`
+	Workers           []string      `json:"workers"`
+	WorkerSecret      string        `json:"-"`
+	TargetEndpointURI string        `json:"target_endpoint_uri"`
+	ExternalTargetURI string        `json:"external_target_uri"`
+	ExternalMaxRate   float64       `json:"external_max_rate"`
+	ExternalInFlight  int           `json:"external_concurrency"`
+	ExternalConfirm   bool          `json:"external_confirm"`
+	ExternalCAFile    string        `json:"external_tls_ca_file"`
+	ExternalInsecure  bool          `json:"external_tls_insecure"`
+	ExternalToken     string        `json:"-"`
+	ExternalUser      string        `json:"-"`
+	ExternalPass      string        `json:"-"`
+	ClientHTTPVersion int           `json:"client_http_version"`
+	MustDrainAndClose bool          `json:"must_drain_and_close"`
+	HTTPVersions      []string      `json:"http_versions"`
+	ClientTargetRPS   float64       `json:"client_target_rps"`
+	BenchDuration     time.Duration `json:"bench_duration"`
+	RequestTimeout    time.Duration `json:"request_timeout"`
+	ValidateResponses bool          `json:"validate_responses"`
+	DisableKeepAlive  bool          `json:"disable_keepalive"`
+	AbortErrorPercent float64       `json:"abort_error_percent"`
+	AbortErrorWindow  int           `json:"abort_error_window"`
+	SLOLatencies      []string      `json:"slo_latencies"`
+	SLOErrorPercent   float64       `json:"slo_max_error_percent"`
+	AcceptEncoding    []string      `json:"accept_encoding"`
+	ThinkTime         time.Duration `json:"think_time"`
+	ThinkTimeDist     string        `json:"think_time_distribution"`
+	GRPCClients       bool          `json:"grpc_clients"`
+	WebSocketClients  bool          `json:"websocket_clients"`
+	WebSocketConns    int           `json:"websocket_connections"`
+	ProxyClients      bool          `json:"proxy_clients"`
+	ProxyHTTPVersion  int           `json:"proxy_upstream_http_version"`
+	ProxyFlush        time.Duration `json:"proxy_flush_interval"`
+	WorkloadPlugin    string        `json:"workload_plugin"`
+	PoolClients       bool          `json:"pool_clients"`
+	PoolConcurrency   int           `json:"pool_concurrency"`
+	PoolMaxConns      int           `json:"pool_max_conns_per_host"`
+	PoolMaxIdleConns  int           `json:"pool_max_idle_conns_per_host"`
+	PoolIdleTimeout   time.Duration `json:"pool_idle_conn_timeout"`
+	PoolMaxStreams    int           `json:"pool_max_concurrent_streams"`
+	DownloadClients   bool          `json:"download_clients"`
+	DownloadLength    int           `json:"download_length"`
+	DownloadRequests  int           `json:"download_requests"`
+	DownloadBuffers   []string      `json:"download_read_buffer_sizes"`
+	UploadClients     bool          `json:"upload_clients"`
+	UploadLength      int           `json:"upload_length"`
+	UploadRequests    int           `json:"upload_requests"`
+	PartialReadBytes  int           `json:"partial_read_bytes"`
+	PcapContainers    []string      `json:"pcap_containers"`
+	PcapImage         string        `json:"pcap_image"`
+	PcapFilter        string        `json:"pcap_filter"`
+	PerfContainers    []string      `json:"perf_containers"`
+	PerfImage         string        `json:"perf_image"`
+	PerfAttachDelay   time.Duration `json:"perf_attach_delay"`
+	RuntimeMetrics    time.Duration `json:"runtime_metrics_interval"`
+	Tracing           bool          `json:"tracing"`
+	TracingImage      string        `json:"tracing_image"`
+	TracingExport     string        `json:"tracing_export_endpoint"`
+	ChaosContainers   []string      `json:"chaos_containers"`
+	ChaosAction       string        `json:"chaos_action"`
+	ChaosAfter        time.Duration `json:"chaos_after"`
+	ChaosRestartAfter time.Duration `json:"chaos_restart_after"`
+	DNSServer         bool          `json:"dns_server"`
+	DNSTTL            time.Duration `json:"dns_ttl"`
+	DNSLatency        time.Duration `json:"dns_latency"`
+	DNSRotate         bool          `json:"dns_rotate"`
+	TLSSessionTickets bool          `json:"tls_session_tickets"`
+	TLS0RTT           bool          `json:"tls_0rtt"`
+}
+
 func main() {
-	resourcePrefix := ""
-	numOfReqs := 1000
-	responseLength := 1000
-	forceRebuild := false
+	cfg := benchConfig{
+		NumberOfRequests:  1000,
+		ResponseLength:    1000,
+		BuildTags:         []string{},
+		TargetGOARCH:      runtime.GOARCH,
+		BuildTimeout:      5 * time.Minute,
+		Workers:           []string{},
+		ClientHTTPVersion: 1,
+		MustDrainAndClose: true,
+		ExternalInFlight:  1,
+		WebSocketConns:    10,
+		HTTPVersions:      []string{"1", "2", "3"},
+		AcceptEncoding:    []string{},
+		AbortErrorWindow:  100,
+		SLOLatencies:      []string{},
+		SLOErrorPercent:   100,
+		ProxyHTTPVersion:  1,
+		ThinkTimeDist:     "fixed",
+		PoolConcurrency:   16,
+		PoolMaxConns:      2,
+		PoolMaxStreams:    4,
+		DownloadLength:    256 << 20,
+		DownloadRequests:  10,
+		DownloadBuffers:   []string{"4096", "65536", "1048576"},
+		UploadLength:      64 << 20,
+		UploadRequests:    10,
+		PcapContainers:    []string{},
+		PcapImage:         "nicolaka/netshoot:latest",
+		PerfContainers:    []string{},
+		PerfImage:         "quay.io/iovisor/bpftrace:latest",
+		PerfAttachDelay:   5 * time.Second,
+		TracingImage:      "otel/opentelemetry-collector-contrib:latest",
+		ChaosContainers:   []string{},
+		ChaosAction:       results.ChaosKill,
+		ChaosAfter:        5 * time.Second,
+		TLSSessionTickets: true,
+	}
 	outputDir := "benchresults"
+	daemonAddr := ""
+	daemonToken := ""
+	scheduleSpec := ""
+	scenariosPath := ""
+	tui := false
+	webUIAddr := ""
 
-	osutil.ExitOnErr(
-		osutil.Load(
-			osutil.NewEnvVar("RESOURCE_PREFIX", &resourcePrefix, false),
-			osutil.NewEnvVar("NUMBER_OF_REQUESTS", &numOfReqs, false),
-			osutil.NewEnvVar("RESPONSE_LENGTH", &responseLength, false),
-			osutil.NewEnvVar("FORCE_IMAGE_REBUILD", &forceRebuild, false),
-			osutil.NewEnvVar("OUTPUT_DIRECTORY", &outputDir, false),
-		))
+	osutil.ExitOnErr(withUsageHint(
+		osutil.Loader{Prefix: osutil.EnvPrefix}.LoadFlags(flag.CommandLine, os.Args[1:],
+			osutil.NewEnvVar("RESOURCE_PREFIX", &cfg.ResourcePrefix, false).
+				WithDescription("prefix added to the names of the Docker images and network").
+				WithValidators(osutil.Match(`^[a-z0-9][a-z0-9_.-]*$`)),
+			osutil.NewEnvVar("NUMBER_OF_REQUESTS", &cfg.NumberOfRequests, false).
+				WithDescription("number of requests each client sends").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("RESPONSE_LENGTH", &cfg.ResponseLength, false).
+				WithDescription("amount of random bytes the server responds with").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("FORCE_IMAGE_REBUILD", &cfg.ForceImageRebuild, false).
+				WithDescription("rebuild the Docker images even if they already exist"),
+			osutil.NewEnvVar("OUTPUT_DIRECTORY", &outputDir, false).
+				WithDescription("directory where the timestamped results directories are created"),
+			osutil.NewEnvVar("BUILD_TAGS", &cfg.BuildTags, false).
+				WithDescription("comma-separated build tags for the client and server binaries"),
+			osutil.NewEnvVar("BUILD_LDFLAGS", &cfg.BuildLDFlags, false).
+				WithDescription("ldflags for the client and server binaries, e.g. to embed version information"),
+			osutil.NewEnvVar("TARGET_GOARCH", &cfg.TargetGOARCH, false).
+				WithDescription("architecture the client and server binaries are built for").
+				WithValidators(osutil.Match(`^[a-z0-9]+$`)),
+			osutil.NewEnvVar("BUILD_TIMEOUT", &cfg.BuildTimeout, false).
+				WithDescription("maximum duration of each client and server build").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("CLIENT_GO_TOOLCHAIN", &cfg.ClientGoToolchain, false).
+				WithDescription("go toolchain the client is built with, e.g. go1.25.1, or the go command of golang.org/dl wrappers when prefixed with bin:").
+				WithValidators(osutil.Match(`^(go[0-9][0-9a-z.]*|local|bin:.+)$`)),
+			osutil.NewEnvVar("SERVER_GO_TOOLCHAIN", &cfg.ServerGoToolchain, false).
+				WithDescription("go toolchain the server is built with, e.g. go1.25.1, or the go command of golang.org/dl wrappers when prefixed with bin:").
+				WithValidators(osutil.Match(`^(go[0-9][0-9a-z.]*|local|bin:.+)$`)),
+			osutil.NewEnvVar("DISABLE_BUILD_CACHE", &cfg.DisableBuildCache, false).
+				WithDescription("always rebuild the client and server binaries, even if their sources did not change"),
+			osutil.NewEnvVar("WORKERS", &cfg.Workers, false).
+				WithDescription("comma-separated host:port addresses of worker agents, enables the distributed mode where the requests are split across them"),
+			osutil.NewEnvVar("WORKER_SECRET", &cfg.WorkerSecret, false).
+				WithDescription("secret shared with the worker agents, required with WORKERS"),
+			osutil.NewEnvVar("TARGET_ENDPOINT_URI", &cfg.TargetEndpointURI, false).
+				WithDescription("URI the workers send their requests to in the distributed mode").
+				WithValidators(osutil.URL()),
+			osutil.NewEnvVar("CLIENT_HTTP_VERSION", &cfg.ClientHTTPVersion, false).
+				WithDescription("HTTP protocol version used by the workers in the distributed mode, and the client in the external-target mode, 1 or 2").
+				WithValidators(osutil.OneOf(1, 2)),
+			osutil.NewEnvVar("MUST_DRAIN_AND_CLOSE", &cfg.MustDrainAndClose, false).
+				WithDescription("whether the workers in the distributed mode, and the client in the external-target mode, drain the response body before closing it"),
+			osutil.NewEnvVar("EXTERNAL_TARGET_URI", &cfg.ExternalTargetURI, false).
+				WithDescription("URI of an external service, e.g. a staging one, a single client container sends its requests to instead of the servers, none of which are created").
+				WithValidators(osutil.URL()),
+			osutil.NewEnvVar("EXTERNAL_MAX_RATE", &cfg.ExternalMaxRate, false).
+				WithDescription("maximum rate, in requests per second, requests are sent to the external target at, required in the external-target mode").
+				WithValidators(osutil.Min(0.0)),
+			osutil.NewEnvVar("EXTERNAL_CONCURRENCY", &cfg.ExternalInFlight, false).
+				WithDescription("number of requests sent concurrently to the external target").
+				WithValidators(osutil.Min(1), osutil.Max(maxExternalInFlight)),
+			osutil.NewEnvVar("EXTERNAL_CONFIRM", &cfg.ExternalConfirm, false).
+				WithDescription("confirm the requests can be sent to the external target, required in the external-target mode"),
+			osutil.NewEnvVar("EXTERNAL_TLS_CA_FILE", &cfg.ExternalCAFile, false).
+				WithDescription("PEM file, on the Docker host, of the certificate authorities the certificate of an https external target is verified against, instead of those of the system"),
+			osutil.NewEnvVar("EXTERNAL_TLS_INSECURE", &cfg.ExternalInsecure, false).
+				WithDescription("skip the verification of the certificate of an https external target"),
+			osutil.NewEnvVar("EXTERNAL_AUTH_BEARER_TOKEN", &cfg.ExternalToken, false).
+				WithDescription("token the requests to the external target are authenticated with, as a bearer token"),
+			osutil.NewEnvVar("EXTERNAL_AUTH_BASIC_USER", &cfg.ExternalUser, false).
+				WithDescription("user name the requests to the external target are authenticated with, with the basic scheme"),
+			osutil.NewEnvVar("EXTERNAL_AUTH_BASIC_PASS", &cfg.ExternalPass, false).
+				WithDescription("password the requests to the external target are authenticated with, along with EXTERNAL_AUTH_BASIC_USER"),
+			osutil.NewEnvVar("HTTP_VERSIONS", &cfg.HTTPVersions, false).
+				WithDescription("comma-separated HTTP versions compared side by side, each with a client draining the response body and another not").
+				WithValidators(osutil.Each(osutil.OneOf("1", "2", "3"))),
+			osutil.NewEnvVar("CLIENT_TARGET_RPS", &cfg.ClientTargetRPS, false).
+				WithDescription("rate, in requests per second, each client of the HTTP versions paces its requests at, 0 sends them as fast as possible").
+				WithValidators(osutil.Min(0.0)),
+			osutil.NewEnvVar("BENCH_DURATION", &cfg.BenchDuration, false).
+				WithDescription("how long each client of the HTTP versions sends its requests for, instead of NUMBER_OF_REQUESTS of them, to compare their throughput, 0 sends NUMBER_OF_REQUESTS").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("REQUEST_TIMEOUT", &cfg.RequestTimeout, false).
+				WithDescription("how long each request of the clients of the HTTP versions, and of the client in the external-target mode, may take before it fails as timed out, 0 does not bound them").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("VALIDATE_RESPONSES", &cfg.ValidateResponses, false).
+				WithDescription("have the clients of the HTTP versions check the responses have the status code 200 and bodies of RESPONSE_LENGTH bytes, and log those which do not as invalid"),
+			osutil.NewEnvVar("DISABLE_KEEPALIVE", &cfg.DisableKeepAlive, false).
+				WithDescription("have the clients of the HTTP versions dial a new connection for every request, to measure the latency of cold connections, except over HTTP/3"),
+			osutil.NewEnvVar("ABORT_ERROR_PERCENT", &cfg.AbortErrorPercent, false).
+				WithDescription("percent of the last ABORT_ERROR_WINDOW requests of a client of the HTTP versions, or of the client in the external-target mode, which may fail before the run is aborted, 0 never aborts it").
+				WithValidators(osutil.Min(0.0), osutil.Max(100.0)),
+			osutil.NewEnvVar("ABORT_ERROR_WINDOW", &cfg.AbortErrorWindow, false).
+				WithDescription("number of the last requests of a client whose failures are counted against ABORT_ERROR_PERCENT").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("SLO_LATENCIES", &cfg.SLOLatencies, false).
+				WithDescription("comma-separated percentiles of the request times of the clients of the HTTP versions, and of the client in the external-target mode, and the time they must be at most, e.g. p99=20ms, the run failing once a client is done if they are not").
+				WithValidators(osutil.Each(osutil.Match(sloLatencyPattern))),
+			osutil.NewEnvVar("SLO_MAX_ERROR_PERCENT", &cfg.SLOErrorPercent, false).
+				WithDescription("percent of the requests of a client of the HTTP versions, or of the client in the external-target mode, which may fail, the run failing once a client is done if more did, 100 to not check it").
+				WithValidators(osutil.Min(0.0), osutil.Max(100.0)),
+			osutil.NewEnvVar("ACCEPT_ENCODING", &cfg.AcceptEncoding, false).
+				WithDescription("comma-separated encodings, gzip or br, the clients of the HTTP versions request their responses compressed in, from the compressing endpoint of the servers, empty to not compress them").
+				WithValidators(osutil.Each(osutil.OneOf("gzip", "br"))),
+			osutil.NewEnvVar("THINK_TIME", &cfg.ThinkTime, false).
+				WithDescription("mean time the clients of the HTTP versions wait between their requests, 0 sends them back to back").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("THINK_TIME_DISTRIBUTION", &cfg.ThinkTimeDist, false).
+				WithDescription("distribution of the think times around THINK_TIME, fixed, uniform between 0 and twice it, or exponential").
+				WithValidators(osutil.OneOf("fixed", "uniform", "exponential")),
+			osutil.NewEnvVar("GRPC_CLIENTS", &cfg.GRPCClients, false).
+				WithDescription("also benchmark unary and streaming gRPC calls against a dedicated gRPC echo server"),
+			osutil.NewEnvVar("WEBSOCKET_CLIENTS", &cfg.WebSocketClients, false).
+				WithDescription("also benchmark WebSocket message round trips against a dedicated server"),
+			osutil.NewEnvVar("WEBSOCKET_CONNECTIONS", &cfg.WebSocketConns, false).
+				WithDescription("number of concurrent connections of the WebSocket client").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("PROXY_CLIENTS", &cfg.ProxyClients, false).
+				WithDescription("also benchmark HTTP clients sending their requests through a reverse proxy to a dedicated server"),
+			osutil.NewEnvVar("PROXY_UPSTREAM_HTTP_VERSION", &cfg.ProxyHTTPVersion, false).
+				WithDescription("HTTP protocol version the proxy uses toward its upstream server, 1, 2 or 3").
+				WithValidators(osutil.OneOf(1, 2, 3)),
+			osutil.NewEnvVar("PROXY_FLUSH_INTERVAL", &cfg.ProxyFlush, false).
+				WithDescription("how often the proxy flushes responses while copying them, 0 buffers them and -1ns flushes every write"),
+			osutil.NewEnvVar("WORKLOAD_PLUGIN", &cfg.WorkloadPlugin, false).
+				WithDescription("Go package, or prebuilt linux executable, of a workload plugin to also benchmark against a dedicated server"),
+			osutil.NewEnvVar("POOL_CLIENTS", &cfg.PoolClients, false).
+				WithDescription("also benchmark HTTP/1 and HTTP/2 clients sending more concurrent requests than their connections, or the streams of the server, can carry, against a dedicated server"),
+			osutil.NewEnvVar("POOL_CONCURRENCY", &cfg.PoolConcurrency, false).
+				WithDescription("number of concurrent requests of the pool saturation clients").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("POOL_MAX_CONNS_PER_HOST", &cfg.PoolMaxConns, false).
+				WithDescription("connections the pool saturation clients open to their server").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("POOL_MAX_IDLE_CONNS_PER_HOST", &cfg.PoolMaxIdleConns, false).
+				WithDescription("idle connections the pool saturation clients keep open to their server, 0 keeps the default of net/http, 2").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("POOL_IDLE_CONN_TIMEOUT", &cfg.PoolIdleTimeout, false).
+				WithDescription("how long the pool saturation clients keep idle connections open, 0 keeps them open").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("POOL_MAX_CONCURRENT_STREAMS", &cfg.PoolMaxStreams, false).
+				WithDescription("concurrent streams the server of the pool saturation clients allows on each HTTP/2 connection").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("PARTIAL_READ_BYTES", &cfg.PartialReadBytes, false).
+				WithDescription("also benchmark a client for each of HTTP_VERSIONS reading only this amount of bytes of the response bodies before closing them, against a dedicated server, 0 disables them").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("DOWNLOAD_CLIENTS", &cfg.DownloadClients, false).
+				WithDescription("also benchmark the throughput of HTTP/1 clients downloading large responses, one for each read buffer size, each from a dedicated server"),
+			osutil.NewEnvVar("DOWNLOAD_LENGTH", &cfg.DownloadLength, false).
+				WithDescription("amount of random bytes the servers of the download clients respond with").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("DOWNLOAD_REQUESTS", &cfg.DownloadRequests, false).
+				WithDescription("number of requests each download client sends").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("DOWNLOAD_READ_BUFFER_SIZES", &cfg.DownloadBuffers, false).
+				WithDescription("comma-separated sizes in bytes of the buffers the download clients read the responses through, one client for each").
+				WithValidators(osutil.Each(osutil.Match(`^[1-9][0-9]*$`))),
+			osutil.NewEnvVar("UPLOAD_CLIENTS", &cfg.UploadClients, false).
+				WithDescription("also benchmark the throughput of HTTP/1 clients uploading large request bodies, with a Content-Length, chunked or after a 100 Continue, each to a dedicated server"),
+			osutil.NewEnvVar("UPLOAD_LENGTH", &cfg.UploadLength, false).
+				WithDescription("amount of random bytes the upload clients send as the body of every request").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("UPLOAD_REQUESTS", &cfg.UploadRequests, false).
+				WithDescription("number of requests each upload client sends").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("PCAP_CONTAINERS", &cfg.PcapContainers, false).
+				WithDescription("comma-separated names of server or proxy containers, e.g. server-0, whose traffic is captured into pcap files"),
+			osutil.NewEnvVar("PCAP_IMAGE", &cfg.PcapImage, false).
+				WithDescription("image with tcpdump run as the capture sidecar of the containers"),
+			osutil.NewEnvVar("PCAP_FILTER", &cfg.PcapFilter, false).
+				WithDescription("tcpdump filter expression of the captures, e.g. tcp port 8080"),
+			osutil.NewEnvVar("PERF_CONTAINERS", &cfg.PerfContainers, false).
+				WithDescription("comma-separated names of containers, e.g. server-0, whose syscall counts, context switches and run-queue latency are recorded with bpftrace"),
+			osutil.NewEnvVar("PERF_IMAGE", &cfg.PerfImage, false).
+				WithDescription("image with bpftrace run as the sidecar recording the syscall and scheduling stats of the containers"),
+			osutil.NewEnvVar("PERF_ATTACH_DELAY", &cfg.PerfAttachDelay, false).
+				WithDescription("how long the clients wait before their first request for bpftrace to attach its probes"),
+			osutil.NewEnvVar("RUNTIME_METRICS_INTERVAL", &cfg.RuntimeMetrics, false).
+				WithDescription("interval the Go runtime metrics of the containers are sampled at, e.g. 1s, 0 disables the sampling").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("TRACING", &cfg.Tracing, false).
+				WithDescription("record a trace of every request of the HTTP clients, exported to an OpenTelemetry collector container and written to traces.jsonl"),
+			osutil.NewEnvVar("TRACING_IMAGE", &cfg.TracingImage, false).
+				WithDescription("OpenTelemetry collector image, with the file exporter, run as the collector of the traces"),
+			osutil.NewEnvVar("TRACING_EXPORT_ENDPOINT", &cfg.TracingExport, false).
+				WithDescription("URL of an OTLP/HTTP endpoint, e.g. of Jaeger or Tempo, the collector also exports the traces to").
+				WithValidators(osutil.URL()),
+			osutil.NewEnvVar("CHAOS_CONTAINERS", &cfg.ChaosContainers, false).
+				WithDescription("comma-separated names of server or proxy containers, e.g. server-0, taken down while the clients send their requests"),
+			osutil.NewEnvVar("CHAOS_ACTION", &cfg.ChaosAction, false).
+				WithDescription("how the chaos containers are taken down, kill or stop, which lets them exit gracefully").
+				WithValidators(osutil.OneOf(results.ChaosKill, results.ChaosStop)),
+			osutil.NewEnvVar("CHAOS_AFTER", &cfg.ChaosAfter, false).
+				WithDescription("how long after the clients start the chaos containers are taken down").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("CHAOS_RESTART_AFTER", &cfg.ChaosRestartAfter, false).
+				WithDescription("how long after being taken down the chaos containers are started again, 0 leaves them down").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("DNS_SERVER", &cfg.DNSServer, false).
+				WithDescription("resolve the names of the servers for the clients with a dedicated DNS server container, instead of the embedded DNS of Docker"),
+			osutil.NewEnvVar("DNS_TTL", &cfg.DNSTTL, false).
+				WithDescription("time to live of the records answered by the DNS server, in whole seconds").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("DNS_LATENCY", &cfg.DNSLatency, false).
+				WithDescription("how long the DNS server waits before responding to each query").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("DNS_ROTATE", &cfg.DNSRotate, false).
+				WithDescription("have the DNS server rotate the order of the records of names with several addresses across responses"),
+			osutil.NewEnvVar("TLS_SESSION_TICKETS", &cfg.TLSSessionTickets, false).
+				WithDescription("have the HTTP/3 clients resume the TLS sessions of their new connections with the session tickets of the servers"),
+			osutil.NewEnvVar("TLS_0RTT", &cfg.TLS0RTT, false).
+				WithDescription("have the HTTP/3 clients send their requests as TLS 1.3 early data (0-RTT) on connections resuming a session, accepted by the servers"),
+			osutil.NewEnvVar("DAEMON_ADDRESS", &daemonAddr, false).
+				WithDescription("address, e.g. :8090, of the HTTP control API, enables the daemon mode where runs are started through the API, served at the loopback interface only unless its host is set, e.g. 0.0.0.0:8090"),
+			osutil.NewEnvVar("DAEMON_TOKEN", &daemonToken, false).
+				WithDescription("bearer token the requests to the HTTP control API must be authenticated with, required with DAEMON_ADDRESS"),
+			osutil.NewEnvVar("SCHEDULE", &scheduleSpec, false).
+				WithDescription("schedule, @every <duration> or a cron expression e.g. \"0 */6 * * *\", on which the daemon mode runs the scheduled scenarios"),
+			osutil.NewEnvVar("SCHEDULE_SCENARIOS", &scenariosPath, false).
+				WithDescription("JSON file of the array of named scenarios run on the schedule, whose fields override the options, by default the options alone"),
+			osutil.NewEnvVar("TUI", &tui, false).
+				WithDescription("show a live dashboard of the run in the terminal, the output of the containers is written to bench.log in the results directory"),
+			osutil.NewEnvVar("WEB_UI_ADDRESS", &webUIAddr, false).
+				WithDescription("address, e.g. :8091, to serve live charts of the run and its report at, kept serving after the run until interrupted"),
+		)))
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	var sched *schedule
+	var scenarios []scheduledScenario
+	if scheduleSpec != "" {
+		if daemonAddr == "" {
+			osutil.ExitOnErr(withUsageHint(&osutil.ErrMissingVar{Name: "DAEMON_ADDRESS"}))
+		}
+		s, err := parseSchedule(scheduleSpec)
+		osutil.ExitOnErr(withUsageHint(err))
+		sched = &s
+		scenarios, err = readScenarios(scenariosPath, cfg)
+		osutil.ExitOnErr(err)
+	}
+	if daemonAddr != "" {
+		if daemonToken == "" {
+			osutil.ExitOnErr(withUsageHint(&osutil.ErrMissingVar{Name: "DAEMON_TOKEN"}))
+		}
+		osutil.ExitOnErr(serveDaemon(ctx, daemonAddr, daemonToken, cfg, outputDir, sched, scenarios))
+		return
+	}
+	if len(cfg.Workers) > 0 && cfg.TargetEndpointURI == "" {
+		osutil.ExitOnErr(withUsageHint(&osutil.ErrMissingVar{Name: "TARGET_ENDPOINT_URI"}))
+	}
+	if len(cfg.Workers) > 0 && cfg.WorkerSecret == "" {
+		osutil.ExitOnErr(withUsageHint(&osutil.ErrMissingVar{Name: "WORKER_SECRET"}))
+	}
+	if cfg.ExternalTargetURI != "" {
+		osutil.ExitOnErr(withUsageHint(checkExternal(cfg)))
+	}
+
 	testRunTs := time.Now().Format("20060102150405")
+	outDir := filepath.Join(outputDir, testRunTs)
+	var ui *webUI
+	if webUIAddr != "" {
+		var err error
+		ui, err = startWebUI(webUIAddr, outDir)
+		osutil.ExitOnErr(err)
+		osutil.RegisterCleanup(ui.Close)
+		fmt.Fprintf(os.Stderr, "serving web UI at %s\n", webUIAddr)
+	}
+
+	var err error
+	if tui {
+		err = runTUI(ctx, cfg, outDir)
+	} else {
+		err = runBench(ctx, cfg, outDir, os.Stderr)
+	}
+	if ui != nil {
+		ui.finish(err)
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "run done, serving its report at %s until interrupted\n", webUIAddr)
+			<-ctx.Done()
+		}
+	}
+	osutil.ExitOnErr(err)
+	osutil.ExitOnErr(osutil.RunCleanups())
+}
+
+// runBench runs a benchmark with the options of cfg and writes its results into outDir.
+//
+// The progress of the run and the output of the containers is written to out.
+func runBench(ctx context.Context, cfg benchConfig, outDir string, out io.Writer) error {
+	if len(cfg.Workers) > 0 {
+		return runCoordinator(ctx, cfg.Workers, cfg.WorkerSecret, worker.Job{
+			TargetEndpointURI: cfg.TargetEndpointURI,
+			NumberOfRequests:  cfg.NumberOfRequests,
+			Duration:          cfg.BenchDuration,
+			TargetRPS:         cfg.ClientTargetRPS,
+			HTTPVersion:       cfg.ClientHTTPVersion,
+			DrainClose:        cfg.MustDrainAndClose,
+		}, outDir, out)
+	}
 
-	var clientBuildCtxBuf, serverBuildCtxBuf bytes.Buffer
-	var clientImgSpec, serverImgSpec orchestration.Image
+	// Binaries always run in Linux containers and are built
+	// without file system paths for reproducibility.
+	buildOpts := osutil.GoBuildOpts{
+		Tags:     cfg.BuildTags,
+		LDFlags:  cfg.BuildLDFlags,
+		GOOS:     "linux",
+		GOARCH:   cfg.TargetGOARCH,
+		TrimPath: true,
+		Timeout:  cfg.BuildTimeout,
+	}
+	buildCacheDir := goBuildCache
+	if cfg.DisableBuildCache {
+		buildCacheDir = ""
+	}
+	if cfg.ExternalTargetURI != "" {
+		return runExternal(ctx, cfg, buildOpts, buildCacheDir, outDir, out)
+	}
+
+	var clientBuild, serverBuild, proxyBuild, dnsBuild orchestration.GoBuild
+	var clientImgSpec, serverImgSpec, proxyImgSpec, dnsImgSpec, pluginImgSpec, pcapImgSpec, perfImgSpec, tracingImgSpec orchestration.Image
+	var pluginBuild, pluginAppBuild orchestration.GoBuild
+	// The proxy is only built when there are clients to send requests through it.
+	artifacts := []string{clientRsrc, serverRsrc}
+	builds := []*orchestration.GoBuild{&clientBuild, &serverBuild}
+	images := []*orchestration.Image{&clientImgSpec, &serverImgSpec}
+	if cfg.ProxyClients {
+		artifacts = append(artifacts, proxyRsrc)
+		builds = append(builds, &proxyBuild)
+		images = append(images, &proxyImgSpec)
+	}
+	if cfg.DNSServer {
+		artifacts = append(artifacts, dnsRsrc)
+		builds = append(builds, &dnsBuild)
+		images = append(images, &dnsImgSpec)
+	}
+	// Plugins given as a Go package are built, executables are copied as is.
+	//
+	// The plugin image takes binaries from the build destinations, which are left
+	// untouched by cached builds, so the plugin and the client are built uncached.
+	pluginBin := cfg.WorkloadPlugin
+	if cfg.WorkloadPlugin != "" {
+		artifacts = append(artifacts, pluginClient)
+		builds = append(builds, &pluginAppBuild)
+		if fi, err := os.Stat(cfg.WorkloadPlugin); err != nil || fi.IsDir() {
+			pluginBin = pluginGoBuildDest
+			artifacts = append(artifacts, "plugin")
+			builds = append(builds, &pluginBuild)
+		}
+		images = append(images, &pluginImgSpec)
+	}
+	if len(cfg.PcapContainers) > 0 {
+		images = append(images, &pcapImgSpec)
+	}
+	if len(cfg.PerfContainers) > 0 {
+		images = append(images, &perfImgSpec)
+	}
+	if cfg.Tracing {
+		images = append(images, &tracingImgSpec)
+	}
 	var benchNetwork orchestration.Network
-	containers := make([]*orchestration.Container, totalContainers)
+	if slices.Contains(cfg.HTTPVersions, "3") {
+		warnUDPBuffers(out)
+	}
+
+	// One client for each combination of HTTP version and whether
+	// to drain the response body before closing it or not.
+	httpClients := 2 * len(cfg.HTTPVersions)
+	numClients, numServers := httpClients, httpServers
+	if cfg.GRPCClients {
+		numClients += len(grpcModes)
+		numServers++
+	}
+	if cfg.WebSocketClients {
+		numClients++
+		numServers++
+	}
+	if cfg.ProxyClients {
+		// The proxy is started, as the servers, before the clients.
+		numClients += 2
+		numServers += 2
+	}
+	if cfg.WorkloadPlugin != "" {
+		numClients++
+		numServers++
+	}
+	if cfg.PoolClients {
+		numClients += len(poolVersions)
+		numServers++
+	}
+	if cfg.PartialReadBytes > 0 {
+		numClients += len(cfg.HTTPVersions)
+		numServers++
+	}
+	if cfg.DownloadClients {
+		numClients += len(cfg.DownloadBuffers)
+		numServers += len(cfg.DownloadBuffers)
+	}
+	if cfg.UploadClients {
+		numClients += len(uploadModes)
+		numServers += len(uploadModes)
+	}
+	// resolvers holds the DNS server, when DNS_SERVER is set, which is
+	// started, as the servers, before the clients are created.
+	numResolvers := 0
+	if cfg.DNSServer {
+		numResolvers = 1
+		numServers++
+	}
+	resolvers := make([]*orchestration.Container, numResolvers)
+	// host returns the name the clients send their requests to the container name at.
+	host := func(name string) string {
+		if cfg.DNSServer {
+			return name + "." + dnsZone
+		}
+		return name
+	}
+	containers := make([]*orchestration.Container, numClients+numServers)
+	// pcaps are the capture sidecars of the containers in PCAP_CONTAINERS.
+	pcaps := make([]*orchestration.Container, len(cfg.PcapContainers))
+	// perfs are the bpftrace sidecars of the containers in PERF_CONTAINERS.
+	perfs := make([]*orchestration.Container, len(cfg.PerfContainers))
+	// collectors holds the trace collector, when TRACING is set.
+	numCollectors := 0
+	if cfg.Tracing {
+		numCollectors = 1
+	}
+	collectors := make([]*orchestration.Container, numCollectors)
+	// chaosTargets are the containers in CHAOS_CONTAINERS, taken down during the run.
+	chaosTargets := make([]*orchestration.Container, len(cfg.ChaosContainers))
+	chaos := &chaosRecorder{}
+	startChaos, stopChaos := orchestration.DelayedStep(out, cfg.ChaosAfter,
+		chaos.steps(cfg.ChaosAction, cfg.ChaosRestartAfter, chaosTargets...)...)
 	orch, err := orchestration.NewDockerOrchestrator()
-	osutil.ExitOnErr(err)
+	if err != nil {
+		return err
+	}
+	defer orch.Close()
 
-	osutil.ExitOnErr(
-		orch.WithPreRunStep(
-			// Define required pre-run artifacts.
-			func(ctx context.Context, c *client.Client) error {
-				// HTTP Client Image Specification
-				clientImgSpec = orchestration.Image{
-					Tag:      resourcePrefix + clientImg,
-					Rebuild:  forceRebuild,
-					BuildCtx: &clientBuildCtxBuf,
-				}
-				// HTTP Server Image Specification
-				serverImgSpec = orchestration.Image{
-					Tag:      resourcePrefix + serverImg,
-					Rebuild:  forceRebuild,
-					BuildCtx: &serverBuildCtxBuf,
-				}
-				// Docker Network Specification
-				benchNetwork = orchestration.Network{
-					Name: resourcePrefix + netName,
-				}
-				return nil
-			},
-			orchestration.GoBuildStep(
-				// Build client binary
-				&orchestration.GoBuild{
-					PkgPath:       clientPkgPath,
-					Dest:          clientGoBuildDest,
-					BuildCtxSpecs: buildCtxSpecs(clientGoBuildDest),
-					ArtifactStore: &clientBuildCtxBuf,
-				},
-				// Build server binary
-				&orchestration.GoBuild{
-					PkgPath:       serverPkgPath,
-					Dest:          serverGoBuildDest,
-					BuildCtxSpecs: buildCtxSpecs(serverGoBuildDest),
-					ArtifactStore: &serverBuildCtxBuf,
+	return orch.WithPreRunStep(
+		// Define required pre-run artifacts.
+		func(ctx context.Context, c *client.Client) error {
+			// HTTP Client Image Specification
+			clientImgSpec = orchestration.Image{
+				Tag:          cfg.ResourcePrefix + clientImg,
+				Rebuild:      cfg.ForceImageRebuild,
+				Platform:     buildOpts.GOOS + "/" + buildOpts.GOARCH,
+				OpenBuildCtx: clientBuild.Context,
+			}
+			// HTTP Server Image Specification
+			serverImgSpec = orchestration.Image{
+				Tag:          cfg.ResourcePrefix + serverImg,
+				Rebuild:      cfg.ForceImageRebuild,
+				Platform:     buildOpts.GOOS + "/" + buildOpts.GOARCH,
+				OpenBuildCtx: serverBuild.Context,
+			}
+			// Reverse Proxy Image Specification
+			proxyImgSpec = orchestration.Image{
+				Tag:          cfg.ResourcePrefix + proxyImg,
+				Rebuild:      cfg.ForceImageRebuild,
+				Platform:     buildOpts.GOOS + "/" + buildOpts.GOARCH,
+				OpenBuildCtx: proxyBuild.Context,
+			}
+			// DNS Server Image Specification
+			dnsImgSpec = orchestration.Image{
+				Tag:          cfg.ResourcePrefix + dnsImg,
+				Rebuild:      cfg.ForceImageRebuild,
+				Platform:     buildOpts.GOOS + "/" + buildOpts.GOARCH,
+				OpenBuildCtx: dnsBuild.Context,
+			}
+			// Workload Plugin Image Specification, always rebuilt as
+			// the plugin can change between runs under the same tag.
+			pluginImgSpec = orchestration.Image{
+				Tag:      cfg.ResourcePrefix + pluginImg,
+				Rebuild:  true,
+				Platform: buildOpts.GOOS + "/" + buildOpts.GOARCH,
+				OpenBuildCtx: func() (io.ReadCloser, error) {
+					return osutil.BuildCtx(
+						osutil.BuildCtxSpec{FineName: "app", PathTo: pluginAppDest, Mode: 0555},
+						osutil.BuildCtxSpec{FineName: "plugin", PathTo: pluginBin, Mode: 0555},
+						osutil.BuildCtxSpec{FineName: "Dockerfile", PathTo: "./build/plugin.Dockerfile", Mode: 0444},
+					)
 				},
-			),
-			orchestration.EnsureImageStep(&clientImgSpec, &serverImgSpec),
-			orchestration.EnsureNetworkStep(&benchNetwork),
-		).
-			WithRunStep(
-				// Define run artifacts
-				func(ctx context.Context, c *client.Client) error {
-					outDir := filepath.Join(outputDir, testRunTs)
-					err := os.MkdirAll(outDir, os.ModePerm)
-					if err != nil {
-						return fmt.Errorf("error to create logs dir: %w", err)
-					}
-					// Must create one container for each option
-					// HTTP version + drain response body or not.
-					httpVersions := []int{1, 2, 1, 2}
-					drainSettings := []int{1, 1, 0, 0}
-					for i := range totalContainers - 2 {
-						name := fmt.Sprintf("%s-http-%d-drain-%d", clientRsrc, httpVersions[i], drainSettings[i])
-						logF, err := os.Create(filepath.Join(outDir, name+"-logs.jsonl"))
-						if err != nil {
-							return fmt.Errorf("error to create log file for %s container: %w", name, err)
+			}
+			// Packet Capture Image Specification
+			pcapImgSpec = orchestration.Image{
+				Tag:      cfg.PcapImage,
+				Pull:     true,
+				Platform: buildOpts.GOOS + "/" + buildOpts.GOARCH,
+			}
+			// Syscall and Scheduling Stats Image Specification
+			perfImgSpec = orchestration.Image{
+				Tag:      cfg.PerfImage,
+				Pull:     true,
+				Platform: buildOpts.GOOS + "/" + buildOpts.GOARCH,
+			}
+			// Trace Collector Image Specification
+			tracingImgSpec = orchestration.Image{
+				Tag:      cfg.TracingImage,
+				Pull:     true,
+				Platform: buildOpts.GOOS + "/" + buildOpts.GOARCH,
+			}
+			// Docker Network Specification
+			benchNetwork = orchestration.Network{
+				Name: cfg.ResourcePrefix + netName,
+			}
+			// Client binary build Specification
+			clientBuild = orchestration.GoBuild{
+				PkgPath:       clientPkgPath,
+				Dest:          clientGoBuildDest,
+				Opts:          withToolchain(buildOpts, cfg.ClientGoToolchain),
+				BuildCtxSpecs: buildCtxSpecs(clientGoBuildDest),
+				CacheDir:      buildCacheDir,
+			}
+			// Server binary build Specification
+			serverBuild = orchestration.GoBuild{
+				PkgPath:       serverPkgPath,
+				Dest:          serverGoBuildDest,
+				Opts:          withToolchain(buildOpts, cfg.ServerGoToolchain),
+				BuildCtxSpecs: buildCtxSpecs(serverGoBuildDest),
+				CacheDir:      buildCacheDir,
+			}
+			// Reverse proxy binary build Specification, built with the server toolchain.
+			proxyBuild = orchestration.GoBuild{
+				PkgPath:       proxyPkgPath,
+				Dest:          proxyGoBuildDest,
+				Opts:          withToolchain(buildOpts, cfg.ServerGoToolchain),
+				BuildCtxSpecs: buildCtxSpecs(proxyGoBuildDest),
+				CacheDir:      buildCacheDir,
+			}
+			// DNS server binary build Specification, built with the server toolchain.
+			dnsBuild = orchestration.GoBuild{
+				PkgPath:       dnsPkgPath,
+				Dest:          dnsGoBuildDest,
+				Opts:          withToolchain(buildOpts, cfg.ServerGoToolchain),
+				BuildCtxSpecs: buildCtxSpecs(dnsGoBuildDest),
+				CacheDir:      buildCacheDir,
+			}
+			// Workload plugin binary build Specification, built with the client toolchain.
+			pluginBuild = orchestration.GoBuild{
+				PkgPath:       cfg.WorkloadPlugin,
+				Dest:          pluginGoBuildDest,
+				Opts:          withToolchain(buildOpts, cfg.ClientGoToolchain),
+				BuildCtxSpecs: buildCtxSpecs(pluginGoBuildDest),
+			}
+			// Client binary build Specification for the workload plugin image.
+			pluginAppBuild = orchestration.GoBuild{
+				PkgPath:       clientPkgPath,
+				Dest:          pluginAppDest,
+				Opts:          withToolchain(buildOpts, cfg.ClientGoToolchain),
+				BuildCtxSpecs: buildCtxSpecs(pluginAppDest),
+			}
+			return nil
+		},
+		orchestration.GoBuildStep(builds...),
+		orchestration.EnsureImageStep(images...),
+		orchestration.EnsureNetworkStep(&benchNetwork),
+	).
+		WithRunStep(
+			// Define run artifacts
+			func(ctx context.Context, c *client.Client) error {
+				err := os.MkdirAll(outDir, os.ModePerm)
+				if err != nil {
+					return fmt.Errorf("error to create logs dir: %w", err)
+				}
+				manifest := results.Manifest{
+					CreatedAt:        time.Now(),
+					NumberOfRequests: cfg.NumberOfRequests,
+					ResponseLength:   cfg.ResponseLength,
+				}
+				for i, b := range builds {
+					manifest.Artifacts = append(manifest.Artifacts, results.ManifestArtifact{
+						Name:          artifacts[i],
+						GoVersion:     b.GoVersion,
+						BinarySHA256:  b.BinarySHA256,
+						ContextSHA256: b.ContextSHA256,
+					})
+				}
+				// addContainer creates the result files of a container, sets it
+				// at the index i of containers and adds it to the manifest.
+				addContainer := func(i int, mc results.ManifestContainer, config container.Config) error {
+					logF, err := os.Create(filepath.Join(outDir, mc.LogFile))
+					if err != nil {
+						return fmt.Errorf("error to create log file for %s container: %w", mc.Name, err)
+					}
+					statF, err := os.Create(filepath.Join(outDir, mc.StatFile))
+					if err != nil {
+						return errors.Join(fmt.Errorf("error to create stat file for %s container: %w", mc.Name, err), logF.Close())
+					}
+					// Sidecars joining the clients once started
+					// need time to attach before the first request.
+					if mc.Role == results.RoleClient && len(perfs) > 0 {
+						config.Env = append(config.Env, "START_DELAY="+cfg.PerfAttachDelay.String())
+					}
+					// Spans are recorded by the clients, under their container names.
+					if mc.Role == results.RoleClient && len(collectors) > 0 {
+						config.Env = append(config.Env,
+							fmt.Sprintf("TRACES_ENDPOINT=http://%s:%s", traceCollector, otlpPort),
+							"TRACES_SERVICE_NAME="+mc.Name,
+						)
+					}
+					containers[i] = &orchestration.Container{
+						Name:   mc.Name,
+						Config: config,
+						Network: network.NetworkingConfig{
+							EndpointsConfig: endpointConfig(benchNetwork),
+						},
+						LogSink:  logF,
+						StatSink: statF,
+					}
+					if cfg.RuntimeMetrics > 0 {
+						mc.RuntimeFile = mc.Name + "-runtime.jsonl"
+						if err := withRuntimeMetrics(containers[i], filepath.Join(outDir, mc.RuntimeFile)); err != nil {
+							return errors.Join(err, logF.Close(), statF.Close())
 						}
-						statF, err := os.Create(filepath.Join(outDir, name+"-stats.jsonl"))
-						if err != nil {
-							return fmt.Errorf("error to create log file for %s container: %w", name, err)
+					}
+					manifest.Containers = append(manifest.Containers, mc)
+					return nil
+				}
+
+				// Must create one container for each option
+				// HTTP version + drain response body or not.
+				for i := range httpClients {
+					version, drain := cfg.HTTPVersions[i%len(cfg.HTTPVersions)], 1-i/len(cfg.HTTPVersions)
+					name := fmt.Sprintf("%s-http-%s-drain-%d", clientRsrc, version, drain)
+					// HTTP/3 runs over QUIC, at the UDP port of the servers.
+					srv := host(fmt.Sprintf("%s-%d", serverRsrc, drain))
+					// Compressed responses are served by the compressing endpoint of the servers.
+					path := fmt.Sprintf("/%d", cfg.ResponseLength)
+					if len(cfg.AcceptEncoding) > 0 {
+						path = "/compress" + path
+					}
+					target := fmt.Sprintf("http://%s:8080%s", srv, path)
+					// Only HTTP/3 runs over TLS, whose sessions can be resumed.
+					var extraEnv []string
+					if version == "3" {
+						target = fmt.Sprintf("https://%s:%s%s", srv, h3Port, path)
+						extraEnv = []string{
+							"TLS_INSECURE=true",
+							fmt.Sprintf("TLS_SESSION_TICKETS=%t", cfg.TLSSessionTickets),
+							fmt.Sprintf("TLS_0RTT=%t", cfg.TLS0RTT),
 						}
-						containers[i] = &orchestration.Container{
-							Name: name,
-							Config: container.Config{
-								Image: clientImg,
-								Env: []string{
-									fmt.Sprintf("TARGET_ENDPOINT_URI=http://%s-%d:8080/%d", serverRsrc, drainSettings[i], responseLength),
-									fmt.Sprintf("CLIENT_HTTP_VERSION=%d", httpVersions[i]),
-									fmt.Sprintf("MUST_DRAIN_AND_CLOSE=%d", drainSettings[i]),
-									fmt.Sprintf("NUMBER_OF_REQUESTS=%d", numOfReqs),
-								},
+					}
+					if cfg.ClientTargetRPS > 0 {
+						extraEnv = append(extraEnv, "CLIENT_TARGET_RPS="+strconv.FormatFloat(cfg.ClientTargetRPS, 'f', -1, 64))
+					}
+					if cfg.BenchDuration > 0 {
+						extraEnv = append(extraEnv, fmt.Sprintf("BENCH_DURATION=%s", cfg.BenchDuration))
+					}
+					if cfg.RequestTimeout > 0 {
+						extraEnv = append(extraEnv, fmt.Sprintf("REQUEST_TIMEOUT=%s", cfg.RequestTimeout))
+					}
+					if cfg.ThinkTime > 0 {
+						extraEnv = append(extraEnv, fmt.Sprintf("THINK_TIME=%s", cfg.ThinkTime), "THINK_TIME_DISTRIBUTION="+cfg.ThinkTimeDist)
+					}
+					if cfg.ValidateResponses {
+						extraEnv = append(extraEnv, "EXPECT_STATUS=200", fmt.Sprintf("EXPECT_BODY_LENGTH=%d", cfg.ResponseLength))
+					}
+					if cfg.DisableKeepAlive {
+						extraEnv = append(extraEnv, "DISABLE_KEEPALIVE=true")
+					}
+					if len(cfg.AcceptEncoding) > 0 {
+						extraEnv = append(extraEnv, "ACCEPT_ENCODING="+strings.Join(cfg.AcceptEncoding, ","))
+					}
+					if cfg.AbortErrorPercent > 0 {
+						extraEnv = append(extraEnv, abortEnv(cfg)...)
+					}
+					extraEnv = append(extraEnv, sloEnv(cfg)...)
+					err := addContainer(i, results.ManifestContainer{
+						Name:         name,
+						Role:         results.RoleClient,
+						Target:       fmt.Sprintf("%s-%d", serverRsrc, drain),
+						LogFile:      name + "-logs.jsonl",
+						StatFile:     name + "-stats.jsonl",
+						DurationNano: cfg.BenchDuration.Nanoseconds(),
+					}, container.Config{
+						Image: clientImg,
+						Env: append([]string{
+							fmt.Sprintf("TARGET_ENDPOINT_URI=%s", target),
+							fmt.Sprintf("CLIENT_HTTP_VERSION=%s", version),
+							fmt.Sprintf("MUST_DRAIN_AND_CLOSE=%d", drain),
+							fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.NumberOfRequests),
+						}, extraEnv...),
+					})
+					if err != nil {
+						return err
+					}
+				}
+				// Must create 1 server for handling requests from clients that will not
+				// drain the response body, and another for clinets that will.
+				for i := range httpServers {
+					err := addContainer(numClients+i, results.ManifestContainer{
+						Name:     fmt.Sprintf("%s-%d", serverRsrc, i),
+						Role:     results.RoleServer,
+						LogFile:  fmt.Sprintf("server-drain-%d-logs.jsonl", i),
+						StatFile: fmt.Sprintf("server-drain-%d-stats.jsonl", i),
+					}, serverConfig(cfg))
+					if err != nil {
+						return err
+					}
+				}
+
+				// The clients of other protocols have servers of their
+				// own, so they do not skew the stats of the HTTP servers.
+				nextClient, nextServer := httpClients, numClients+httpServers
+				if cfg.GRPCClients {
+					for _, mode := range grpcModes {
+						name := fmt.Sprintf("%s-%s", clientRsrc, mode)
+						err := addContainer(nextClient, results.ManifestContainer{
+							Name:     name,
+							Role:     results.RoleClient,
+							Target:   grpcServer,
+							LogFile:  name + "-logs.jsonl",
+							StatFile: name + "-stats.jsonl",
+						}, container.Config{
+							Image: clientImg,
+							Env: []string{
+								fmt.Sprintf("TARGET_ENDPOINT_URI=http://%s:9090/%d", host(grpcServer), cfg.ResponseLength),
+								fmt.Sprintf("CLIENT_MODE=%s", mode),
+								fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.NumberOfRequests),
 							},
-							Network: network.NetworkingConfig{
-								EndpointsConfig: endpointConfig(benchNetwork),
+						})
+						if err != nil {
+							return err
+						}
+						nextClient++
+					}
+					err := addContainer(nextServer, results.ManifestContainer{
+						Name:     grpcServer,
+						Role:     results.RoleServer,
+						LogFile:  grpcServer + "-logs.jsonl",
+						StatFile: grpcServer + "-stats.jsonl",
+					}, serverConfig(cfg))
+					if err != nil {
+						return err
+					}
+					nextServer++
+				}
+				if cfg.WebSocketClients {
+					err := addContainer(nextClient, results.ManifestContainer{
+						Name:     wsClient,
+						Role:     results.RoleClient,
+						Target:   wsServer,
+						LogFile:  wsClient + "-logs.jsonl",
+						StatFile: wsClient + "-stats.jsonl",
+					}, container.Config{
+						Image: clientImg,
+						Env: []string{
+							fmt.Sprintf("TARGET_ENDPOINT_URI=http://%s:8080/%d", host(wsServer), cfg.ResponseLength),
+							"CLIENT_MODE=websocket",
+							fmt.Sprintf("WEBSOCKET_CONNECTIONS=%d", cfg.WebSocketConns),
+							fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.NumberOfRequests),
+						},
+					})
+					if err != nil {
+						return err
+					}
+					err = addContainer(nextServer, results.ManifestContainer{
+						Name:     wsServer,
+						Role:     results.RoleServer,
+						LogFile:  wsServer + "-logs.jsonl",
+						StatFile: wsServer + "-stats.jsonl",
+					}, serverConfig(cfg))
+					if err != nil {
+						return err
+					}
+					nextClient++
+					nextServer++
+				}
+				if cfg.ProxyClients {
+					// Clients of both drain settings share the proxy, the latency it
+					// adds is compared with the HTTP/1 clients sending directly to a server.
+					for drain := range 2 {
+						name := fmt.Sprintf("%s-proxy-drain-%d", clientRsrc, drain)
+						err := addContainer(nextClient, results.ManifestContainer{
+							Name:     name,
+							Role:     results.RoleClient,
+							Target:   proxyRsrc,
+							LogFile:  name + "-logs.jsonl",
+							StatFile: name + "-stats.jsonl",
+						}, container.Config{
+							Image: clientImg,
+							Env: []string{
+								fmt.Sprintf("TARGET_ENDPOINT_URI=http://%s:8080/%d", host(proxyRsrc), cfg.ResponseLength),
+								"CLIENT_HTTP_VERSION=1",
+								fmt.Sprintf("MUST_DRAIN_AND_CLOSE=%d", drain),
+								fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.NumberOfRequests),
 							},
-							LogSink:  logF,
-							StatSink: statF,
+						})
+						if err != nil {
+							return err
 						}
-
+						nextClient++
+					}
+					upstream := fmt.Sprintf("http://%s:8080", upstreamServer)
+					if cfg.ProxyHTTPVersion == 3 {
+						upstream = fmt.Sprintf("https://%s:%s", upstreamServer, h3Port)
+					}
+					err := addContainer(nextServer, results.ManifestContainer{
+						Name:     proxyRsrc,
+						Role:     results.RoleProxy,
+						Target:   upstreamServer,
+						LogFile:  proxyRsrc + "-logs.jsonl",
+						StatFile: proxyRsrc + "-stats.jsonl",
+					}, container.Config{
+						Image: proxyImg,
+						Env: []string{
+							fmt.Sprintf("UPSTREAM_URI=%s", upstream),
+							fmt.Sprintf("UPSTREAM_HTTP_VERSION=%d", cfg.ProxyHTTPVersion),
+							fmt.Sprintf("FLUSH_INTERVAL=%s", cfg.ProxyFlush),
+						},
+					})
+					if err != nil {
+						return err
+					}
+					err = addContainer(nextServer+1, results.ManifestContainer{
+						Name:     upstreamServer,
+						Role:     results.RoleServer,
+						LogFile:  upstreamServer + "-logs.jsonl",
+						StatFile: upstreamServer + "-stats.jsonl",
+					}, serverConfig(cfg))
+					if err != nil {
+						return err
+					}
+					nextServer += 2
+				}
+				if cfg.WorkloadPlugin != "" {
+					err := addContainer(nextClient, results.ManifestContainer{
+						Name:     pluginClient,
+						Role:     results.RoleClient,
+						Target:   pluginServer,
+						LogFile:  pluginClient + "-logs.jsonl",
+						StatFile: pluginClient + "-stats.jsonl",
+					}, container.Config{
+						Image: pluginImg,
+						Env: []string{
+							fmt.Sprintf("TARGET_ENDPOINT_URI=http://%s:8080/%d", host(pluginServer), cfg.ResponseLength),
+							"CLIENT_MODE=plugin",
+							"WORKLOAD_PLUGIN=/plugin",
+							fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.NumberOfRequests),
+						},
+					})
+					if err != nil {
+						return err
+					}
+					err = addContainer(nextServer, results.ManifestContainer{
+						Name:     pluginServer,
+						Role:     results.RoleServer,
+						LogFile:  pluginServer + "-logs.jsonl",
+						StatFile: pluginServer + "-stats.jsonl",
+					}, serverConfig(cfg))
+					if err != nil {
+						return err
 					}
-					// Must create 1 server for handling requests from clients that will not
-					// drain the response body, and another for clinets that will.
-					for i := range 2 {
-						statF, err := os.Create(filepath.Join(outDir, fmt.Sprintf("server-drain-%d-stats.jsonl", i)))
+					nextClient++
+					nextServer++
+				}
+				if cfg.PoolClients {
+					for _, version := range poolVersions {
+						name := fmt.Sprintf("%s-http-%s", poolClient, version)
+						err := addContainer(nextClient, results.ManifestContainer{
+							Name:     name,
+							Role:     results.RoleClient,
+							Target:   poolServer,
+							LogFile:  name + "-logs.jsonl",
+							StatFile: name + "-stats.jsonl",
+						}, container.Config{
+							Image: clientImg,
+							Env: []string{
+								fmt.Sprintf("TARGET_ENDPOINT_URI=http://%s:8080/%d", host(poolServer), cfg.ResponseLength),
+								fmt.Sprintf("CLIENT_HTTP_VERSION=%s", version),
+								"MUST_DRAIN_AND_CLOSE=1",
+								fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.NumberOfRequests),
+								fmt.Sprintf("CONCURRENCY=%d", cfg.PoolConcurrency),
+								fmt.Sprintf("MAX_CONNS_PER_HOST=%d", cfg.PoolMaxConns),
+								fmt.Sprintf("MAX_IDLE_CONNS_PER_HOST=%d", cfg.PoolMaxIdleConns),
+								fmt.Sprintf("IDLE_CONN_TIMEOUT=%s", cfg.PoolIdleTimeout),
+							},
+						})
 						if err != nil {
-							return fmt.Errorf("error to create stat file for server container: %w", err)
+							return err
 						}
-						containers[totalContainers-1-i] = &orchestration.Container{
-							Name: fmt.Sprintf("%s-%d", serverRsrc, i),
-							Config: container.Config{
-								Image: serverImg,
+						nextClient++
+					}
+					config := serverConfig(cfg)
+					config.Env = append(config.Env, fmt.Sprintf("H2_MAX_CONCURRENT_STREAMS=%d", cfg.PoolMaxStreams))
+					err := addContainer(nextServer, results.ManifestContainer{
+						Name:     poolServer,
+						Role:     results.RoleServer,
+						LogFile:  poolServer + "-logs.jsonl",
+						StatFile: poolServer + "-stats.jsonl",
+					}, config)
+					if err != nil {
+						return err
+					}
+					nextServer++
+				}
+				if cfg.PartialReadBytes > 0 {
+					for _, version := range cfg.HTTPVersions {
+						name := fmt.Sprintf("%s-http-%s", partialClient, version)
+						target := fmt.Sprintf("http://%s:8080/%d", host(partialServer), cfg.ResponseLength)
+						if version == "3" {
+							target = fmt.Sprintf("https://%s:%s/%d", host(partialServer), h3Port, cfg.ResponseLength)
+						}
+						err := addContainer(nextClient, results.ManifestContainer{
+							Name:     name,
+							Role:     results.RoleClient,
+							Target:   partialServer,
+							LogFile:  name + "-logs.jsonl",
+							StatFile: name + "-stats.jsonl",
+						}, container.Config{
+							Image: clientImg,
+							Env: []string{
+								fmt.Sprintf("TARGET_ENDPOINT_URI=%s", target),
+								fmt.Sprintf("CLIENT_HTTP_VERSION=%s", version),
+								fmt.Sprintf("PARTIAL_READ_BYTES=%d", cfg.PartialReadBytes),
+								// The certificates of the HTTP/3 servers are self-signed.
+								fmt.Sprintf("TLS_INSECURE=%t", version == "3"),
+								fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.NumberOfRequests),
 							},
-							Network: network.NetworkingConfig{
-								EndpointsConfig: endpointConfig(benchNetwork),
+						})
+						if err != nil {
+							return err
+						}
+						nextClient++
+					}
+					err := addContainer(nextServer, results.ManifestContainer{
+						Name:     partialServer,
+						Role:     results.RoleServer,
+						LogFile:  partialServer + "-logs.jsonl",
+						StatFile: partialServer + "-stats.jsonl",
+					}, serverConfig(cfg))
+					if err != nil {
+						return err
+					}
+					nextServer++
+				}
+				if cfg.DownloadClients {
+					// Each client has a server of its own, so the CPU time
+					// the server spends per GiB can be told for each buffer size.
+					for _, size := range cfg.DownloadBuffers {
+						name := fmt.Sprintf("%s-buf-%s", downloadClient, size)
+						srvName := fmt.Sprintf("%s-buf-%s", downloadServer, size)
+						err := addContainer(nextClient, results.ManifestContainer{
+							Name:             name,
+							Role:             results.RoleClient,
+							Target:           srvName,
+							LogFile:          name + "-logs.jsonl",
+							StatFile:         name + "-stats.jsonl",
+							NumberOfRequests: cfg.DownloadRequests,
+							ResponseLength:   cfg.DownloadLength,
+						}, container.Config{
+							Image: clientImg,
+							Env: []string{
+								fmt.Sprintf("TARGET_ENDPOINT_URI=http://%s:8080/%d", host(srvName), cfg.DownloadLength),
+								"CLIENT_HTTP_VERSION=1",
+								"MUST_DRAIN_AND_CLOSE=1",
+								fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.DownloadRequests),
+								fmt.Sprintf("READ_BUFFER_SIZE=%s", size),
 							},
-							StatSink: statF,
+						})
+						if err != nil {
+							return err
 						}
+						err = addContainer(nextServer, results.ManifestContainer{
+							Name:     srvName,
+							Role:     results.RoleServer,
+							LogFile:  srvName + "-logs.jsonl",
+							StatFile: srvName + "-stats.jsonl",
+						}, serverConfig(cfg))
+						if err != nil {
+							return err
+						}
+						nextClient++
+						nextServer++
 					}
-					return nil
-				},
-				orchestration.ContainerCreateStep(containers...),
-				orchestration.ContainerStreamStatStep(os.Stderr, containers...),
-				orchestration.ContainerStartStep(containers...),
-				orchestration.ContainerLogStep(os.Stderr, containers...),
-				// Wait only for the client containers.
-				orchestration.ContainerWaitStep(os.Stderr, containers[:totalContainers-2]...),
-			).
-			WithPosRunStep(
-				orchestration.ContainerStopStep(containers...),
-				orchestration.ContainerRemoveStep(containers...),
-				orchestration.EnsureContainerSinkCloseStep(containers...),
-			).
-			Run(ctx),
-	)
+				}
+				if cfg.UploadClients {
+					for _, mode := range uploadModes {
+						name := fmt.Sprintf("%s-%s", uploadClient, mode.name)
+						srvName := fmt.Sprintf("%s-%s", uploadServer, mode.name)
+						err := addContainer(nextClient, results.ManifestContainer{
+							Name:             name,
+							Role:             results.RoleClient,
+							Target:           srvName,
+							LogFile:          name + "-logs.jsonl",
+							StatFile:         name + "-stats.jsonl",
+							NumberOfRequests: cfg.UploadRequests,
+						}, container.Config{
+							Image: clientImg,
+							Env: append([]string{
+								fmt.Sprintf("TARGET_ENDPOINT_URI=http://%s:8080%s%d", host(srvName), mode.path, cfg.ResponseLength),
+								"CLIENT_HTTP_VERSION=1",
+								"MUST_DRAIN_AND_CLOSE=1",
+								fmt.Sprintf("NUMBER_OF_REQUESTS=%d", cfg.UploadRequests),
+								fmt.Sprintf("UPLOAD_LENGTH=%d", cfg.UploadLength),
+							}, mode.env...),
+						})
+						if err != nil {
+							return err
+						}
+						err = addContainer(nextServer, results.ManifestContainer{
+							Name:     srvName,
+							Role:     results.RoleServer,
+							LogFile:  srvName + "-logs.jsonl",
+							StatFile: srvName + "-stats.jsonl",
+						}, serverConfig(cfg))
+						if err != nil {
+							return err
+						}
+						nextClient++
+						nextServer++
+					}
+				}
+				if len(resolvers) > 0 {
+					err := addContainer(nextServer, results.ManifestContainer{
+						Name:     dnsRsrc,
+						Role:     results.RoleDNS,
+						LogFile:  dnsRsrc + "-queries.jsonl",
+						StatFile: dnsRsrc + "-stats.jsonl",
+					}, container.Config{
+						Image: dnsImg,
+						Env: []string{
+							"DNS_ZONE=" + dnsZone,
+							fmt.Sprintf("DNS_TTL=%s", cfg.DNSTTL),
+							fmt.Sprintf("DNS_LATENCY=%s", cfg.DNSLatency),
+							fmt.Sprintf("DNS_ROTATE=%t", cfg.DNSRotate),
+						},
+					})
+					if err != nil {
+						return err
+					}
+					resolvers[0] = containers[nextServer]
+				}
+				if len(collectors) > 0 {
+					manifest.TracesFile = "traces.jsonl"
+					collectors[0] = traceCollectorContainer(cfg.TracingImage, cfg.TracingExport,
+						filepath.Join(outDir, manifest.TracesFile), endpointConfig(benchNetwork))
+				}
+				for i, name := range cfg.PcapContainers {
+					j := slices.IndexFunc(manifest.Containers, func(mc results.ManifestContainer) bool {
+						return mc.Name == name
+					})
+					// Clients are started last, so their sidecars would miss their first requests.
+					if j < 0 || manifest.Containers[j].Role == results.RoleClient {
+						return fmt.Errorf("unable to capture the traffic of %s, it is not a server or proxy container of the run", name)
+					}
+					manifest.Containers[j].PcapFile = name + ".pcap"
+					pcaps[i] = pcapSidecar(name, cfg.PcapImage, cfg.PcapFilter, filepath.Join(outDir, manifest.Containers[j].PcapFile))
+				}
+				for i, name := range cfg.PerfContainers {
+					j := slices.IndexFunc(manifest.Containers, func(mc results.ManifestContainer) bool {
+						return mc.Name == name
+					})
+					if j < 0 {
+						return fmt.Errorf("unable to record the stats of %s, it is not a container of the run", name)
+					}
+					manifest.Containers[j].PerfFile = name + "-perf.jsonl"
+					sink, err := os.Create(filepath.Join(outDir, manifest.Containers[j].PerfFile))
+					if err != nil {
+						return fmt.Errorf("error to create perf file for %s container: %w", name, err)
+					}
+					perfs[i] = perfSidecar(name, cfg.PerfImage, sink)
+				}
+				for i, name := range cfg.ChaosContainers {
+					j := slices.IndexFunc(manifest.Containers, func(mc results.ManifestContainer) bool {
+						return mc.Name == name
+					})
+					if j < 0 || manifest.Containers[j].Role == results.RoleClient {
+						return fmt.Errorf("unable to take %s down, it is not a server or proxy container of the run", name)
+					}
+					chaosTargets[i] = containers[slices.IndexFunc(containers, func(c *orchestration.Container) bool {
+						return c.Name == name
+					})]
+				}
+				if len(chaosTargets) > 0 {
+					manifest.ChaosFile = "chaos.jsonl"
+					sink, err := os.Create(filepath.Join(outDir, manifest.ChaosFile))
+					if err != nil {
+						return fmt.Errorf("error to create chaos file: %w", err)
+					}
+					chaos.sink = sink
+				}
+				return results.WriteManifest(outDir, manifest)
+			},
+			// Clients are only created once the servers, and the trace collector,
+			// are ready, so they can be pointed at the address of the DNS server.
+			orchestration.ContainerCreateStep(collectors...),
+			orchestration.ContainerCreateStep(containers[numClients:]...),
+			orchestration.ContainerCreateStep(pcaps...),
+			orchestration.ContainerStartStep(collectors...),
+			orchestration.ContainerStartStep(containers[numClients:]...),
+			orchestration.ContainerStartStep(pcaps...),
+			orchestration.ContainerHealthyStep(time.Minute, time.Second, containers[numClients:]...),
+			orchestration.ContainerAddressStep(cfg.ResourcePrefix+netName, resolvers...),
+			resolverStep(resolvers, containers[:numClients]...),
+			orchestration.ContainerCreateStep(containers[:numClients]...),
+			orchestration.ContainerCreateStep(perfs...),
+			orchestration.ContainerStreamStatStep(out, containers...),
+			orchestration.ContainerStartStep(containers[:numClients]...),
+			// The clients wait for the perf sidecars, which join running containers, to attach.
+			orchestration.ContainerStartStep(perfs...),
+			orchestration.ContainerLogStep(out, containers...),
+			orchestration.ContainerLogStep(out, perfs...),
+			orchestration.ContainerMetricsStep(out, cfg.RuntimeMetrics, containers...),
+			startChaos,
+			// Wait only for the client containers.
+			orchestration.ContainerWaitStep(out, containers[:numClients]...),
+		).
+		WithPosRunStep(
+			// Chaos steps not taken yet are canceled once the clients are done.
+			stopChaos,
+			chaos.closeStep(),
+			// Sidecars are removed first, as they share the namespaces of the containers.
+			orchestration.ContainerStopStep(pcaps...),
+			orchestration.ContainerCopyOutStep(pcaps...),
+			orchestration.ContainerRemoveStep(pcaps...),
+			orchestration.ContainerStopStep(perfs...),
+			orchestration.ContainerRemoveStep(perfs...),
+			orchestration.EnsureContainerSinkCloseStep(perfs...),
+			orchestration.ContainerStopStep(containers...),
+			orchestration.ContainerRemoveStep(containers...),
+			orchestration.EnsureContainerSinkCloseStep(containers...),
+			// The clients flushed their spans before exiting.
+			orchestration.ContainerStopStep(collectors...),
+			orchestration.ContainerCopyOutStep(collectors...),
+			orchestration.ContainerRemoveStep(collectors...),
+		).
+		Run(ctx)
+}
 
+// abortEnv returns the environment of a client aborting its requests, and so
+// the run, once too many of them failed, as set by cfg.
+func abortEnv(cfg benchConfig) []string {
+	return []string{
+		"ABORT_ERROR_PERCENT=" + strconv.FormatFloat(cfg.AbortErrorPercent, 'f', -1, 64),
+		fmt.Sprintf("ABORT_ERROR_WINDOW=%d", cfg.AbortErrorWindow),
+	}
+}
+
+// sloEnv returns the environment variables of the objectives of the clients, if any.
+func sloEnv(cfg benchConfig) []string {
+	var env []string
+	if len(cfg.SLOLatencies) > 0 {
+		env = append(env, "SLO_LATENCIES="+strings.Join(cfg.SLOLatencies, ","))
+	}
+	if cfg.SLOErrorPercent < 100 {
+		env = append(env, "SLO_MAX_ERROR_PERCENT="+strconv.FormatFloat(cfg.SLOErrorPercent, 'f', -1, 64))
+	}
+	return env
+}
+
+// serverConfig returns the configuration of a server container.
+//
+// The server is healthy once its HTTP/3 server responds, which
+// also means its TCP servers, started before it, are listening.
+func serverConfig(cfg benchConfig) container.Config {
+	return container.Config{
+		Image: serverImg,
+		Env: []string{
+			fmt.Sprintf("TLS_SESSION_TICKETS=%t", cfg.TLSSessionTickets),
+			fmt.Sprintf("TLS_0RTT=%t", cfg.TLS0RTT),
+		},
+		ExposedPorts: container.PortSet{
+			"8080/tcp":      {},
+			"9090/tcp":      {},
+			h3Port + "/udp": {},
+		},
+		Healthcheck: &container.HealthConfig{
+			Test:     []string{"CMD", "/app", "-health-check-uri", "https://localhost:" + h3Port + "/0"},
+			Interval: time.Second,
+			Timeout:  3 * time.Second,
+			Retries:  30,
+		},
+	}
+}
+
+// withRuntimeMetrics has the container c serve its Go runtime metrics,
+// at a port published to the local host, and sample them into the file at path.
+func withRuntimeMetrics(c *orchestration.Container, path string) error {
+	sink, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error to create runtime metrics file for %s container: %w", c.Name, err)
+	}
+	port, _, _ := strings.Cut(metricsPort, "/")
+	c.Config.Env = append(c.Config.Env, "METRICS_PORT="+port)
+	c.Config.ExposedPorts = maps.Clone(c.Config.ExposedPorts)
+	if c.Config.ExposedPorts == nil {
+		c.Config.ExposedPorts = container.PortSet{}
+	}
+	c.Config.ExposedPorts[metricsPort] = struct{}{}
+	// The host port is picked by Docker, so runs do not collide.
+	c.HostConfig.PortBindings = container.PortMap{
+		metricsPort: {{HostIP: "127.0.0.1"}},
+	}
+	c.MetricsPort = metricsPort
+	c.MetricsPath = runtimemetrics.Path
+	c.MetricsSink = sink
+	return nil
+}
+
+// pcapSidecar returns a container capturing the traffic of the container target
+// with tcpdump, from its network namespace, into a file copied to dest once stopped.
+func pcapSidecar(target, image, filter, dest string) *orchestration.Container {
+	const capture = "/tmp/capture.pcap"
+	// Packets are written as captured, so they are kept when tcpdump is stopped.
+	cmd := []string{"-i", "any", "-U", "-w", capture}
+	if filter != "" {
+		cmd = append(cmd, filter)
+	}
+	return &orchestration.Container{
+		Name: target + "-pcap",
+		Config: container.Config{
+			Image:      image,
+			Entrypoint: []string{"tcpdump"},
+			Cmd:        cmd,
+			User:       "root",
+		},
+		HostConfig: container.HostConfig{
+			NetworkMode: container.NetworkMode("container:" + target),
+			CapAdd:      []string{"NET_ADMIN", "NET_RAW"},
+		},
+		CopyOut: map[string]string{capture: dest},
+	}
+}
+
+// warnUDPBuffers warns when the UDP socket buffer limits of the host are
+// lower than what quic-go needs, as they can not be raised from containers.
+//
+// The limits are read from the local host, which is the Docker host unless DOCKER_HOST points elsewhere.
+func warnUDPBuffers(out io.Writer) {
+	for _, name := range []string{"rmem_max", "wmem_max"} {
+		b, err := os.ReadFile("/proc/sys/net/core/" + name)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil || limit >= quicBufferSize {
+			continue
+		}
+		fmt.Fprintf(out, "WARNING: net.core.%s is %d, lower than the %d bytes HTTP/3 needs, raise it with: sysctl -w net.core.%s=%d\n",
+			name, limit, quicBufferSize, name, quicBufferSize)
+	}
+}
+
+// withUsageHint appends to err hints on how to set the
+// missing variables and fix the malformed ones, if any.
+func withUsageHint(err error) error {
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+
+	var hints []string
+	for _, e := range errs {
+		var missing *osutil.ErrMissingVar
+		var parse *osutil.ErrParse
+		switch {
+		case errors.As(e, &missing):
+			hints = append(hints, fmt.Sprintf("set %s with the %s%s environment variable or the -%s flag",
+				missing.Name, osutil.EnvPrefix, missing.Name, strings.ReplaceAll(strings.ToLower(missing.Name), "_", "-")))
+		case errors.As(e, &parse):
+			hints = append(hints, fmt.Sprintf("%s must be a valid %s, got %q", parse.Name, parse.Type, parse.Value))
+		}
+	}
+	if len(hints) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\n\nhints:\n- %s\nrun with -help to list all the options", err, strings.Join(hints, "\n- "))
+}
+
+// withToolchain returns the build options using the given toolchain,
+// which is a GOTOOLCHAIN value or a go command prefixed with bin:.
+func withToolchain(opts osutil.GoBuildOpts, toolchain string) osutil.GoBuildOpts {
+	if bin, ok := strings.CutPrefix(toolchain, "bin:"); ok {
+		opts.GoBin = bin
+		return opts
+	}
+	opts.Toolchain = toolchain
+	return opts
 }
 
 func buildCtxSpecs(binPath string) []osutil.BuildCtxSpec {
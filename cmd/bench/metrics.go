@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// writeMetrics writes, in the Prometheus text exposition format, the
+// summaries of the latest run of each scenario, so the scheduled runs
+// can be scraped and tracked as a continuous performance monitor.
+func (d *daemon) writeMetrics(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	latest := make([]results.RunSummary, 0, len(d.latest))
+	for _, name := range slices.Sorted(maps.Keys(d.latest)) {
+		latest = append(latest, d.latest[name])
+	}
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	runMetric := func(name, help string, value func(results.RunSummary) float64) {
+		writeMetricHeader(w, name, help)
+		for _, s := range latest {
+			writeMetric(w, name, value(s), "scenario", s.Scenario)
+		}
+	}
+	runMetric("httpmicrobench_run_success", "Whether the latest run of the scenario succeeded.",
+		func(s results.RunSummary) float64 {
+			if s.Status == runSucceeded {
+				return 1
+			}
+			return 0
+		})
+	runMetric("httpmicrobench_run_duration_seconds", "Duration of the latest run of the scenario.",
+		func(s results.RunSummary) float64 { return s.FinishedAt.Sub(s.StartedAt).Seconds() })
+	runMetric("httpmicrobench_run_finished_timestamp_seconds", "Unix time the latest run of the scenario finished at.",
+		func(s results.RunSummary) float64 { return float64(s.FinishedAt.UnixNano()) / float64(time.Second) })
+
+	clientMetric := func(name, help string, value func(results.ClientSummary) float64) {
+		writeMetricHeader(w, name, help)
+		for _, s := range latest {
+			for _, c := range s.Clients {
+				writeMetric(w, name, value(c), "scenario", s.Scenario, "client", c.Name)
+			}
+		}
+	}
+	clientMetric("httpmicrobench_client_requests_completed", "Requests completed by the client in the latest run of the scenario.",
+		func(c results.ClientSummary) float64 { return float64(c.Completed) })
+	clientMetric("httpmicrobench_client_requests_failed", "Requests failed by the client in the latest run of the scenario.",
+		func(c results.ClientSummary) float64 { return float64(c.Failed) })
+	clientMetric("httpmicrobench_client_request_mean_seconds", "Mean request time of the client in the latest run of the scenario.",
+		func(c results.ClientSummary) float64 { return time.Duration(c.MeanNano).Seconds() })
+	clientMetric("httpmicrobench_client_request_median_seconds", "Median request time of the client in the latest run of the scenario.",
+		func(c results.ClientSummary) float64 { return time.Duration(c.MedianNano).Seconds() })
+	clientMetric("httpmicrobench_client_request_p99_seconds", "99th percentile request time of the client in the latest run of the scenario.",
+		func(c results.ClientSummary) float64 { return time.Duration(c.P99Nano).Seconds() })
+}
+
+// writeMetricHeader writes the HELP and TYPE lines of the gauge name.
+func writeMetricHeader(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+// writeMetric writes a sample of the metric name, labeled by the label name and value pairs.
+func writeMetric(w io.Writer, name string, value float64, labels ...string) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+"="+strconv.Quote(labels[i+1]))
+	}
+	fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), strconv.FormatFloat(value, 'g', -1, 64))
+}
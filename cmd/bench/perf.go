@@ -0,0 +1,44 @@
+package main
+
+import (
+	_ "embed"
+	"io"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/pessolato/httpmicrobench/pkg/orchestration"
+)
+
+// perfScript is the bpftrace script run by the perf sidecars.
+//
+//go:embed perf.bt
+var perfScript string
+
+// perfSidecar returns a container recording the syscall and scheduling stats of
+// the container target with bpftrace, writing them as JSON lines to sink.
+//
+// The sidecar shares the PID namespace of the target and the cgroup namespace
+// of the host, so it finds the cgroup of the target in /proc/1/cgroup and
+// filters the probes by it before running the script.
+func perfSidecar(target, image string, sink io.WriteCloser) *orchestration.Container {
+	const run = `cg=/sys/fs/cgroup$(sed -n 's/^0:://p' /proc/1/cgroup); ` +
+		`exec bpftrace -f json -e "$(printf '%s' "$0" | sed "s|@CGROUP@|$cg|g")"`
+	return &orchestration.Container{
+		Name: target + "-perf",
+		Config: container.Config{
+			Image:      image,
+			Entrypoint: []string{"sh", "-c"},
+			Cmd:        []string{run, perfScript},
+			User:       "root",
+		},
+		HostConfig: container.HostConfig{
+			PidMode:      container.PidMode("container:" + target),
+			CgroupnsMode: container.CgroupnsModeHost,
+			Privileged:   true,
+			Binds: []string{
+				"/sys/kernel/debug:/sys/kernel/debug:rw",
+				"/sys/kernel/tracing:/sys/kernel/tracing:rw",
+			},
+		},
+		LogSink: sink,
+	}
+}
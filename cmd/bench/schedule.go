@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is when the daemon starts its scheduled runs, either
+// every fixed interval or at the minutes matching a cron expression.
+type schedule struct {
+	every time.Duration
+	// fields are the values matched by the minute, hour, day of month,
+	// month and day of week fields of the cron expression, as bit sets.
+	fields [5]uint64
+	// anyDay is set when neither day field is restricted, or only one is.
+	// Otherwise, as in cron, days matching either of them match.
+	anyDay bool
+}
+
+// cronFields are the bounds of the fields of cron expressions.
+var cronFields = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// parseSchedule parses s, either "@every <duration>", e.g. @every 6h, or a cron
+// expression of 5 fields: minute, hour, day of month, month and day of week, e.g.
+// "0 */6 * * *". Fields are *, values, ranges as a-b, steps as */n or a-b/n, and lists of them.
+func parseSchedule(s string) (schedule, error) {
+	if d, ok := strings.CutPrefix(s, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || every < time.Minute {
+			return schedule{}, fmt.Errorf("invalid schedule %q, the interval must be a duration of at least 1m", s)
+		}
+		return schedule{every: every}, nil
+	}
+
+	parts := strings.Fields(s)
+	if len(parts) != len(cronFields) {
+		return schedule{}, fmt.Errorf("invalid schedule %q, must be @every <duration> or a cron expression of 5 fields", s)
+	}
+	var sched schedule
+	for i, p := range parts {
+		bits, err := parseCronField(p, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return schedule{}, fmt.Errorf("invalid %s field of schedule %q: %w", cronFields[i].name, s, err)
+		}
+		sched.fields[i] = bits
+	}
+	sched.anyDay = parts[2] == "*" || parts[4] == "*"
+	return sched, nil
+}
+
+// parseCronField returns the bit set of the values between min and max matched by the field f.
+func parseCronField(f string, min, max int) (uint64, error) {
+	var bits uint64
+	for item := range strings.SplitSeq(f, ",") {
+		rng, step, hasStep := strings.Cut(item, "/")
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", a)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid value %q", b)
+				}
+			}
+		}
+		n := 1
+		if hasStep {
+			var err error
+			if n, err = strconv.Atoi(step); err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", step)
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", rng, min, max)
+		}
+		for v := lo; v <= hi; v += n {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time after t the schedule is due.
+func (s schedule) next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	// Cron expressions match, at most, every minute of 4 years, leap days included.
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for range 4 * 366 * 24 * 60 {
+		if s.matches(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// matches reports whether the minute of t matches the cron expression.
+func (s schedule) matches(t time.Time) bool {
+	has := func(field, v int) bool { return s.fields[field]&(1<<v) != 0 }
+	dom, dow := has(2, t.Day()), has(4, int(t.Weekday()))
+	day := dom && dow
+	if !s.anyDay {
+		day = dom || dow
+	}
+	return has(0, t.Minute()) && has(1, t.Hour()) && has(3, int(t.Month())) && day
+}
+
+// scheduledScenario is a scenario of the set run by the daemon on its schedule.
+type scheduledScenario struct {
+	Name string `json:"name"`
+	benchConfig
+}
+
+// readScenarios reads the scenario set in the JSON file at path, an array of
+// scenarios whose fields override cfg, each with a unique name. An empty path
+// is a set of the single scenario cfg, named default.
+func readScenarios(path string, cfg benchConfig) ([]scheduledScenario, error) {
+	if path == "" {
+		return []scheduledScenario{{Name: "default", benchConfig: cfg}}, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error to read scenarios: %w", err)
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("error to decode scenarios of %s: %w", path, err)
+	}
+	scenarios := make([]scheduledScenario, len(raw))
+	names := make(map[string]bool)
+	for i, r := range raw {
+		scenarios[i].benchConfig = cfg
+		if err := json.Unmarshal(r, &scenarios[i]); err != nil {
+			return nil, fmt.Errorf("error to decode scenario %d of %s: %w", i, path, err)
+		}
+		name := scenarios[i].Name
+		if name == "" || names[name] {
+			return nil, fmt.Errorf("scenario %d of %s must have a unique name", i, path)
+		}
+		names[name] = true
+		if err := validateScenario(scenarios[i].benchConfig); err != nil {
+			return nil, fmt.Errorf("invalid scenario %s: %w", name, err)
+		}
+	}
+	return scenarios, nil
+}
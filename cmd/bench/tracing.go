@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+	"github.com/pessolato/httpmicrobench/pkg/orchestration"
+)
+
+const (
+	// traceCollector is the name of the OpenTelemetry collector container the clients export their spans to.
+	traceCollector = "trace-collector"
+	// otlpPort is the port the collector receives OTLP/HTTP exports at.
+	otlpPort = "4318"
+)
+
+// traceCollectorContainer returns a container of the OpenTelemetry collector image
+// receiving the spans of the clients over OTLP/HTTP, writing them as OTLP JSON
+// lines to a file copied to dest once stopped and, if forward is set, also
+// exporting them over OTLP/HTTP to the endpoint forward, e.g. a Jaeger or Tempo.
+//
+// The configuration is passed in an environment variable, as the
+// image has neither a shell nor a writable configuration directory.
+func traceCollectorContainer(image, forward, dest string, nw map[string]*network.EndpointSettings) *orchestration.Container {
+	const traces = "/traces.jsonl"
+	exporters := "[file]"
+	forwardExporter := ""
+	if forward != "" {
+		exporters = "[file, otlphttp]"
+		forwardExporter = fmt.Sprintf("\n  otlphttp:\n    endpoint: %q", forward)
+	}
+	config := fmt.Sprintf(`receivers:
+  otlp:
+    protocols:
+      http:
+        endpoint: 0.0.0.0:%s
+processors:
+  batch: {}
+exporters:
+  file:
+    path: %s%s
+service:
+  pipelines:
+    traces:
+      receivers: [otlp]
+      processors: [batch]
+      exporters: %s
+`, otlpPort, traces, forwardExporter, exporters)
+
+	return &orchestration.Container{
+		Name: traceCollector,
+		Config: container.Config{
+			Image: image,
+			Cmd:   []string{"--config=env:COLLECTOR_CONFIG"},
+			Env:   []string{"COLLECTOR_CONFIG=" + config},
+			// The image runs as an unprivileged user, which can not write to its root.
+			User:         "root",
+			ExposedPorts: container.PortSet{otlpPort + "/tcp": {}},
+		},
+		Network: network.NetworkingConfig{EndpointsConfig: nw},
+		CopyOut: map[string]string{traces: dest},
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+const (
+	// tuiLogFile is the file in the results directory the output of the run is written to in the TUI mode.
+	tuiLogFile = "bench.log"
+	// tuiRefresh is how often the dashboard reads the results written by the run.
+	tuiRefresh = 500 * time.Millisecond
+	// barWidth is the width, in cells, of the progress bars and CPU gauges.
+	barWidth = 30
+)
+
+// runTUI runs the benchmark like [runBench] while showing a live dashboard
+// of its progress, latency percentiles and container CPU usage.
+//
+// Pressing q or ctrl+c cancels the run, the dashboard is closed once it is cleaned up.
+func runTUI(ctx context.Context, cfg benchConfig, outDir string) error {
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return fmt.Errorf("error to create logs dir: %w", err)
+	}
+	logF, err := os.Create(filepath.Join(outDir, tuiLogFile))
+	if err != nil {
+		return fmt.Errorf("error to create log file for the run: %w", err)
+	}
+	defer logF.Close()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The dashboard outlives the run context, so it keeps
+	// showing the run while it is canceled and cleaned up.
+	p := tea.NewProgram(&dashboard{
+		live:   newLiveResults(outDir),
+		cancel: cancel,
+		log:    &resultTail{path: filepath.Join(outDir, tuiLogFile)},
+	}, tea.WithContext(ctx), tea.WithoutSignalHandler())
+
+	runErr := make(chan error, 1)
+	go func() {
+		err := runBench(runCtx, cfg, outDir, logF)
+		runErr <- err
+		p.Send(runDoneMsg{err})
+	}()
+
+	if _, err := p.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		cancel()
+		return errors.Join(err, <-runErr)
+	}
+	return <-runErr
+}
+
+// runDoneMsg is sent to the dashboard when the run is done.
+type runDoneMsg struct{ err error }
+
+// refreshMsg makes the dashboard read the results written since the last refresh.
+type refreshMsg struct{}
+
+// dashboard is the [tea.Model] of the TUI mode.
+type dashboard struct {
+	live     *liveResults
+	cancel   context.CancelFunc
+	log      *resultTail
+	canceled bool
+	done     bool
+	err      error
+}
+
+func (d *dashboard) Init() tea.Cmd {
+	return refresh()
+}
+
+func (d *dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			d.canceled = true
+			d.cancel()
+		}
+	case refreshMsg:
+		d.live.read()
+		d.log.read()
+		return d, refresh()
+	case runDoneMsg:
+		d.live.read()
+		d.log.read()
+		d.done, d.err = true, msg.err
+		return d, tea.Quit
+	}
+	return d, nil
+}
+
+func (d *dashboard) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Benchmark run %s\n\n", d.live.dir)
+
+	d.live.view(func(m *results.Manifest, tails map[string]*resultTail) {
+		if m == nil {
+			b.WriteString("Preparing the run...\n")
+			return
+		}
+		for _, c := range m.Containers {
+			if c.Role != results.RoleClient {
+				continue
+			}
+			t, ok := tails[c.LogFile]
+			if !ok {
+				continue
+			}
+			want := expectedRequests(m, c)
+			p50, p99 := t.percentiles()
+			fmt.Fprintf(&b, "%-26s %s %7d/%-7d p50 %-10s p99 %-10s\n",
+				c.Name, bar(float64(t.finished)/float64(want)), t.finished, want,
+				time.Duration(p50), time.Duration(p99))
+		}
+		b.WriteString("\nCPU\n")
+		for _, c := range m.Containers {
+			if c.StatFile == "" {
+				continue
+			}
+			// Gauges are relative to all the CPUs of the host.
+			t := tails[c.StatFile]
+			fmt.Fprintf(&b, "%-26s %s %6.1f%%\n", c.Name, bar(t.cpu/float64(max(t.cpus, 1))/100), t.cpu)
+		}
+	})
+
+	b.WriteString("\n")
+	for _, l := range d.log.lastLines {
+		fmt.Fprintf(&b, "  %s\n", l)
+	}
+	switch {
+	case d.done && d.err != nil:
+		fmt.Fprintf(&b, "\nRun failed: %s\n", d.err)
+	case d.done:
+		b.WriteString("\nRun done.\n")
+	case d.canceled:
+		b.WriteString("\nCanceling the run...\n")
+	default:
+		b.WriteString("\nPress q to cancel the run.\n")
+	}
+	return b.String()
+}
+
+func refresh() tea.Cmd {
+	return tea.Tick(tuiRefresh, func(time.Time) tea.Msg { return refreshMsg{} })
+}
+
+// bar draws a bar filled to the fraction f, clamped between 0 and 1.
+func bar(f float64) string {
+	filled := int(min(max(f, 0), 1) * barWidth)
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled) + "]"
+}
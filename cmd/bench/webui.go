@@ -0,0 +1,138 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// webUIPage is the page of the web UI, which polls the data endpoint and draws the charts.
+//
+//go:embed webui.html
+var webUIPage []byte
+
+// webUI serves live charts of a run and, once it is done, its final report.
+type webUI struct {
+	live *liveResults
+	srv  *http.Server
+	stop chan struct{}
+
+	mu     sync.Mutex
+	done   bool
+	runErr error
+}
+
+// webUIClient is the data of a client shown by the web UI.
+type webUIClient struct {
+	Name     string        `json:"name"`
+	Finished int           `json:"finished"`
+	Expected int           `json:"expected"`
+	P50Nano  int64         `json:"p50_nano"`
+	P99Nano  int64         `json:"p99_nano"`
+	Latency  []seriesPoint `json:"latency"`
+}
+
+// webUIContainer is the data of a container shown by the web UI.
+type webUIContainer struct {
+	Name string        `json:"name"`
+	CPU  []seriesPoint `json:"cpu"`
+}
+
+// startWebUI serves the web UI of the run writing its results into outDir at addr.
+//
+// The results are read every second until [webUI.finish] is called.
+func startWebUI(addr, outDir string) (*webUI, error) {
+	ui := &webUI{live: newLiveResults(outDir), stop: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(webUIPage)
+	})
+	mux.HandleFunc("GET /data", ui.data)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error to listen for the web UI: %w", err)
+	}
+	ui.srv = &http.Server{Handler: mux}
+	go ui.srv.Serve(ln)
+	go func() {
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ui.stop:
+				return
+			case <-tick.C:
+				ui.live.read()
+			}
+		}
+	}()
+	return ui, nil
+}
+
+// finish reads the last results of the run and marks it done with err.
+func (ui *webUI) finish(err error) {
+	close(ui.stop)
+	ui.live.read()
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.done, ui.runErr = true, err
+}
+
+// Close stops serving the web UI.
+func (ui *webUI) Close() error {
+	if err := ui.srv.Close(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// data writes the current data of the run as JSON.
+func (ui *webUI) data(w http.ResponseWriter, r *http.Request) {
+	resp := struct {
+		Dir        string           `json:"dir"`
+		Done       bool             `json:"done"`
+		Error      string           `json:"error,omitempty"`
+		Clients    []webUIClient    `json:"clients"`
+		Containers []webUIContainer `json:"containers"`
+	}{Dir: ui.live.dir, Clients: []webUIClient{}, Containers: []webUIContainer{}}
+
+	ui.mu.Lock()
+	resp.Done = ui.done
+	if ui.runErr != nil {
+		resp.Error = ui.runErr.Error()
+	}
+	ui.mu.Unlock()
+
+	ui.live.view(func(m *results.Manifest, tails map[string]*resultTail) {
+		if m != nil {
+			for _, c := range m.Containers {
+				if t, ok := tails[c.LogFile]; ok && c.Role == results.RoleClient {
+					p50, p99 := t.percentiles()
+					resp.Clients = append(resp.Clients, webUIClient{
+						Name:     c.Name,
+						Finished: t.finished,
+						Expected: expectedRequests(m, c),
+						P50Nano:  p50,
+						P99Nano:  p99,
+						Latency:  t.latencyMean.snapshot(),
+					})
+				}
+				if t, ok := tails[c.StatFile]; ok {
+					resp.Containers = append(resp.Containers, webUIContainer{Name: c.Name, CPU: t.cpuUsage.snapshot()})
+				}
+			}
+		}
+	})
+	// The series are copied, so slow clients do not hold up the reads of the results.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
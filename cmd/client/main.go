@@ -2,48 +2,724 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/pessolato/httpmicrobench/pkg/client"
 	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/runtimemetrics"
+	"github.com/pessolato/httpmicrobench/pkg/schema"
+	"github.com/pessolato/httpmicrobench/pkg/server"
+	"github.com/pessolato/httpmicrobench/pkg/workload"
+)
+
+// Protocols the client can benchmark.
+const (
+	modeHTTP       = "http"
+	modeGRPCUnary  = "grpc-unary"
+	modeGRPCStream = "grpc-stream"
+	modeWebSocket  = "websocket"
+	modePlugin     = "plugin"
+)
+
+// Libraries the HTTP requests can be sent with.
+const (
+	libraryNetHTTP  = "net/http"
+	libraryFastHTTP = "fasthttp"
 )
 
 func main() {
 	endpointUrl := ""
+	targetUrls := []string{}
+	targetWeights := []string{}
+	targetSelection := client.SelectRoundRobin
 	numOfReqs := 1000
+	numOfReqsSet := false
+	duration := time.Duration(0)
+	reqTimeout := time.Duration(0)
+	drainTimeout := 5 * time.Second
+	ramp := client.Ramp{}
+	sweep := client.IdleSweep{Batch: 10}
+	openLoop := false
+	aggregate := false
+	abortPercent := 0.0
+	abortWindow := 100
+	sloLatencies := []string{}
+	sloErrorPercent := 100.0
+	cookieJar := false
+	acceptEncoding := []string{}
+	decompress := true
+	noKeepAlive := false
+	proxyUrl := ""
+	staticHosts := []string{}
+	dnsResolver := ""
+	dnsCacheTTL := time.Duration(0)
+	ipFamily := ""
+	sockOpts := client.SocketOptions{}
+	tcpNoDelay := true
+	noHappyEyeballs := false
+	proxyFromEnv := false
+	bearerToken := ""
+	basicUser := ""
+	basicPass := ""
+	thinkTime := time.Duration(0)
+	thinkDist := client.ThinkFixed
+	expectStatus := 0
+	expectBodyLen := -1
+	retry := client.RetryPolicy{
+		MaxAttempts: 1,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+		On:          []string{client.RetryOnError, client.RetryOnTimeout},
+	}
 	drainClose := false
+	partialRead := 0
+	readRate := 0
 	httpVersion := 1
+	library := libraryNetHTTP
+	mode := modeHTTP
+	wsConns := 10
+	pluginPath := ""
+	startDelay := time.Duration(0)
+	metricsPort := ""
+	runtimeInterval := time.Duration(0)
+	asyncLog := false
+	tracesEndpoint := ""
+	tracesService := "client"
+	concurrency := 1
+	perWorker := false
+	maxRate := 0.0
+	targetRPS := 0.0
+	transport := client.TransportOptions{}
+	readBufSize := 0
+	uploadLen := 0
+	harFile := ""
+	maxRedirects := -1
+	method := ""
+	mixFile := ""
+	uploadChunked := false
+	uploadMultipart := false
+	expectContinue := false
+	sessionTickets := false
+	alternateTickets := false
+	zeroRTT := false
+	caFile := ""
+	insecure := false
 	osutil.ExitOnErr(
-		osutil.Load(
-			osutil.NewEnvVar("TARGET_ENDPOINT_URI", &endpointUrl, true),
-			osutil.NewEnvVar("NUMBER_OF_REQUESTS", &numOfReqs, false),
-			osutil.NewEnvVar("MUST_DRAIN_AND_CLOSE", &drainClose, false),
-			osutil.NewEnvVar("CLIENT_HTTP_VERSION", &httpVersion, false),
+		osutil.Loader{Prefix: osutil.EnvPrefix}.LoadFlags(flag.CommandLine, os.Args[1:],
+			osutil.NewEnvVar("TARGET_ENDPOINT_URI", &endpointUrl, false).
+				WithDescription("URI the client sends its requests to, required unless TARGET_ENDPOINT_URIS or HAR_FILE is set").
+				WithValidators(osutil.URL()),
+			osutil.NewEnvVar("TARGET_ENDPOINT_URIS", &targetUrls, false).
+				WithDescription("comma-separated URIs the HTTP requests are sent to instead of TARGET_ENDPOINT_URI, picked in proportion to TARGET_WEIGHTS").
+				WithValidators(osutil.Each(osutil.URL())),
+			osutil.NewEnvVar("TARGET_WEIGHTS", &targetWeights, false).
+				WithDescription("comma-separated weights of the TARGET_ENDPOINT_URIS, in their order, 1 each if not set").
+				WithValidators(osutil.Each(osutil.Match(`^[0-9]+$`))),
+			osutil.NewEnvVar("TARGET_SELECTION", &targetSelection, false).
+				WithDescription("how the TARGET_ENDPOINT_URIS are picked, round-robin, each as many times in a row as its weight, or random").
+				WithValidators(osutil.OneOf(client.SelectRoundRobin, client.SelectRandom)),
+			osutil.NewEnvVar("HAR_FILE", &harFile, false).
+				WithDescription("HTTP Archive (HAR) file whose requests are replayed, with their method, URL, headers and body, instead of a GET of TARGET_ENDPOINT_URI, picked as TARGET_SELECTION"),
+			osutil.NewEnvVar("REQUEST_MIX_FILE", &mixFile, false).
+				WithDescription("YAML, or JSON with a .json extension, file of the weighted requests sent instead of a GET of TARGET_ENDPOINT_URI, with their method, path resolved against it, body size and headers, picked as TARGET_SELECTION"),
+			osutil.NewEnvVar("HTTP_METHOD", &method, false).
+				WithDescription("method of the HTTP requests, if not set GET, or POST with UPLOAD_LENGTH, HEAD transferring no response body, so EXPECT_BODY_LENGTH can not be set with it").
+				WithValidators(osutil.OneOf("", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete)),
+			osutil.NewEnvVar("MAX_REDIRECTS", &maxRedirects, false).
+				WithDescription("number of redirects of every HTTP request followed at most, logging every one of them, 0 follows none, -1 follows up to 10 without logging them, as net/http").
+				WithValidators(osutil.Min(-1)),
+			osutil.NewEnvVar("NUMBER_OF_REQUESTS", &numOfReqs, false).
+				WithDescription("number of requests each client sends").
+				WithValidators(osutil.Min(1)).
+				WasSet(&numOfReqsSet),
+			osutil.NewEnvVar("BENCH_DURATION", &duration, false).
+				WithDescription("how long the HTTP requests are sent for, instead of NUMBER_OF_REQUESTS of them, 0 sends NUMBER_OF_REQUESTS").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("REQUEST_TIMEOUT", &reqTimeout, false).
+				WithDescription("how long each HTTP request, its response body included, may take before it fails as timed out, 0 does not bound them").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("DRAIN_TIMEOUT", &drainTimeout, false).
+				WithDescription("how long the HTTP requests in flight once the client is interrupted, e.g. by SIGTERM, are awaited before they are canceled, so the run is still summarized, 0 cancels them right away").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("RETRY_MAX_ATTEMPTS", &retry.MaxAttempts, false).
+				WithDescription("times each HTTP request is attempted at most, retrying its failures of the RETRY_ON classes, 1 does not retry them").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("RETRY_BACKOFF", &retry.Backoff, false).
+				WithDescription("how long to wait before the first retry of an HTTP request, doubled before every next one").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("RETRY_MAX_BACKOFF", &retry.MaxBackoff, false).
+				WithDescription("longest wait before a retry of an HTTP request, 0 does not bound it").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("RETRY_ON", &retry.On, false).
+				WithDescription("comma-separated classes of failures HTTP requests are retried on, error, timeout or 5xx").
+				WithValidators(osutil.Each(osutil.OneOf(client.RetryOnError, client.RetryOnTimeout, client.RetryOn5xx))),
+			osutil.NewEnvVar("RETRY_NON_IDEMPOTENT", &retry.NonIdempotent, false).
+				WithDescription("retry the HTTP requests which are not idempotent too, e.g. POST requests without an Idempotency-Key header, which the server may have processed already"),
+			osutil.NewEnvVar("ABORT_ERROR_PERCENT", &abortPercent, false).
+				WithDescription("percent of the last ABORT_ERROR_WINDOW HTTP requests which may fail before the client aborts, 0 never aborts").
+				WithValidators(osutil.Min(0.0), osutil.Max(100.0)),
+			osutil.NewEnvVar("ABORT_ERROR_WINDOW", &abortWindow, false).
+				WithDescription("number of the last HTTP requests whose failures are counted against ABORT_ERROR_PERCENT").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("SLO_LATENCIES", &sloLatencies, false).
+				WithDescription("comma-separated percentiles of the times of the HTTP requests and the time they must be at most, e.g. p99=20ms, the client exiting with a non-zero status once done if they are not").
+				WithValidators(osutil.Each(osutil.Match(`^p[0-9.]+=.+$`))),
+			osutil.NewEnvVar("SLO_MAX_ERROR_PERCENT", &sloErrorPercent, false).
+				WithDescription("percent of the HTTP requests which may fail, the client exiting with a non-zero status once done if more did, 100 to not check it").
+				WithValidators(osutil.Min(0.0), osutil.Max(100.0)),
+			osutil.NewEnvVar("AGGREGATE_LATENCIES", &aggregate, false).
+				WithDescription("record the times of the HTTP requests into a histogram in memory and log a summary of them once done, instead of a line for each of them"),
+			osutil.NewEnvVar("EXPECT_STATUS", &expectStatus, false).
+				WithDescription("status code the HTTP responses are expected to have, responses with another are logged as invalid, 0 does not check it").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("EXPECT_BODY_LENGTH", &expectBodyLen, false).
+				WithDescription("length in bytes the bodies of the HTTP responses are expected to have, responses with another are logged as invalid, negative does not check it"),
+			osutil.NewEnvVar("AUTH_BEARER_TOKEN", &bearerToken, false).
+				WithDescription("token the HTTP requests are authenticated with, in their Authorization header, as a bearer token"),
+			osutil.NewEnvVar("AUTH_BASIC_USER", &basicUser, false).
+				WithDescription("user name the HTTP requests are authenticated with, in their Authorization header, with the basic scheme"),
+			osutil.NewEnvVar("AUTH_BASIC_PASS", &basicPass, false).
+				WithDescription("password the HTTP requests are authenticated with, along with AUTH_BASIC_USER"),
+			osutil.NewEnvVar("DISABLE_KEEPALIVE", &noKeepAlive, false).
+				WithDescription("dial a new connection for every HTTP request and close it once done, instead of reusing connections, except over HTTP/3"),
+			osutil.NewEnvVar("ACCEPT_ENCODING", &acceptEncoding, false).
+				WithDescription("comma-separated encodings the HTTP responses are requested compressed in, gzip or br, in order of preference, empty leaves it to the transport").
+				WithValidators(osutil.Each(osutil.OneOf(client.EncodingGzip, client.EncodingBrotli))),
+			osutil.NewEnvVar("DECOMPRESS_RESPONSES", &decompress, false).
+				WithDescription("decompress the HTTP responses requested with ACCEPT_ENCODING before handling them, instead of draining them compressed"),
+			osutil.NewEnvVar("COOKIE_JAR", &cookieJar, false).
+				WithDescription("keep the cookies set by the HTTP responses and send them back with the next requests, as a single session"),
+			osutil.NewEnvVar("STATIC_HOSTS", &staticHosts, false).
+				WithDescription("comma-separated name=address pairs, e.g. server-0=10.0.0.3, of host names the HTTP requests connect to the address of without resolving them").
+				WithValidators(osutil.Each(osutil.Match(`^[^=]+=[0-9a-fA-F.:]+$`))),
+			osutil.NewEnvVar("DNS_RESOLVER", &dnsResolver, false).
+				WithDescription("address, e.g. 10.0.0.2:53, of the DNS server the host names of the HTTP requests are resolved with, instead of the resolver of the system").
+				WithValidators(osutil.Match(`^.+:[0-9]+$`)),
+			osutil.NewEnvVar("DNS_CACHE_TTL", &dnsCacheTTL, false).
+				WithDescription("how long the addresses the names of the targets resolved to are cached for, instead of resolving them for every connection dialed, 0 to not cache them").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("IP_FAMILY", &ipFamily, false).
+				WithDescription("address family the HTTP/1 and HTTP/2 connections are dialed over only, 4 or 6, empty for either").
+				WithValidators(osutil.OneOf("", "4", "6")),
+			osutil.NewEnvVar("DISABLE_HAPPY_EYEBALLS", &noHappyEyeballs, false).
+				WithDescription("dial the addresses of the targets one after the other, instead of racing a connection to an address of the other family when the first one is slow to connect"),
+			osutil.NewEnvVar("TCP_NODELAY", &tcpNoDelay, false).
+				WithDescription("send small segments of the HTTP/1 and HTTP/2 connections right away, false delays them until the segments sent before are acknowledged (Nagle's algorithm)"),
+			osutil.NewEnvVar("SOCKET_SEND_BUFFER", &sockOpts.SendBuffer, false).
+				WithDescription("size in bytes of the send buffers of the sockets of the HTTP/1 and HTTP/2 connections, 0 keeps the default of the system").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("SOCKET_RECEIVE_BUFFER", &sockOpts.ReceiveBuffer, false).
+				WithDescription("size in bytes of the receive buffers of the sockets of the HTTP/1 and HTTP/2 connections, 0 keeps the default of the system").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("PROXY_URL", &proxyUrl, false).
+				WithDescription("URL of the forward proxy, e.g. http://proxy:3128 or socks5://proxy:1080, the HTTP requests are sent through, empty to send them directly").
+				WithValidators(osutil.URL(), osutil.Match(`^(http|https|socks5)://`)),
+			osutil.NewEnvVar("PROXY_FROM_ENVIRONMENT", &proxyFromEnv, false).
+				WithDescription("send the HTTP requests through the forward proxies set by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables"),
+			osutil.NewEnvVar("THINK_TIME", &thinkTime, false).
+				WithDescription("mean time each of the CONCURRENCY senders of HTTP requests waits between its requests, 0 sends them back to back").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("THINK_TIME_DISTRIBUTION", &thinkDist, false).
+				WithDescription("distribution of the think times around THINK_TIME, fixed, uniform between 0 and twice it, or exponential").
+				WithValidators(osutil.OneOf(client.ThinkFixed, client.ThinkUniform, client.ThinkExponential)),
+			osutil.NewEnvVar("MUST_DRAIN_AND_CLOSE", &drainClose, false).
+				WithDescription("drain the response body before closing it"),
+			osutil.NewEnvVar("PARTIAL_READ_BYTES", &partialRead, false).
+				WithDescription("read only up to this amount of bytes of the response body before closing it, 0 reads none, or all of it with MUST_DRAIN_AND_CLOSE").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("READ_RATE_BYTES", &readRate, false).
+				WithDescription("drain the response body at most this amount of bytes per second, as slow clients do, 0 to read it as fast as it arrives").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("CLIENT_HTTP_VERSION", &httpVersion, false).
+				WithDescription("HTTP protocol version used by the client, 1, 2 or 3").
+				WithValidators(osutil.OneOf(1, 2, 3)),
+			osutil.NewEnvVar("CLIENT_LIBRARY", &library, false).
+				WithDescription("library the HTTP requests are sent with, net/http, or fasthttp, over HTTP/1.1 only").
+				WithValidators(osutil.OneOf(libraryNetHTTP, libraryFastHTTP)),
+			osutil.NewEnvVar("CLIENT_MODE", &mode, false).
+				WithDescription("protocol the client benchmarks, one of http, grpc-unary, grpc-stream, websocket or plugin").
+				WithValidators(osutil.OneOf(modeHTTP, modeGRPCUnary, modeGRPCStream, modeWebSocket, modePlugin)),
+			osutil.NewEnvVar("WEBSOCKET_CONNECTIONS", &wsConns, false).
+				WithDescription("number of concurrent WebSocket connections the requests are split across in the websocket mode").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("WORKLOAD_PLUGIN", &pluginPath, false).
+				WithDescription("path of the workload plugin executable sending the requests in the plugin mode"),
+			osutil.NewEnvVar("CONCURRENCY", &concurrency, false).
+				WithDescription("number of HTTP requests sent concurrently, the requests are split across them").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("TRANSPORT_PER_WORKER", &perWorker, false).
+				WithDescription("send the HTTP requests of each of the CONCURRENCY senders over a transport and connection pool of its own, instead of sharing those of the client, except over HTTP/3"),
+			osutil.NewEnvVar("RAMP_INTERVAL", &ramp.Every, false).
+				WithDescription("how long the HTTP requests are sent at each concurrency while ramping up, from CONCURRENCY to RAMP_MAX_CONCURRENCY, instead of NUMBER_OF_REQUESTS of them, 0 does not ramp up").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("RAMP_STEP", &ramp.Step, false).
+				WithDescription("number of concurrent HTTP requests added every RAMP_INTERVAL while ramping up").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("RAMP_MAX_CONCURRENCY", &ramp.Max, false).
+				WithDescription("number of concurrent HTTP requests the ramp up ends at").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("IDLE_SWEEP_STEP", &sweep.Step, false).
+				WithDescription("idle gap added between every batch of IDLE_SWEEP_BATCH HTTP requests to the one before, from this gap up to IDLE_SWEEP_MAX, instead of sending NUMBER_OF_REQUESTS of them, 0 does not sweep idle gaps").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("IDLE_SWEEP_MAX", &sweep.Max, false).
+				WithDescription("idle gap the idle gap sweep ends at, e.g. beyond the keep-alive timeout of the server").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("IDLE_SWEEP_BATCH", &sweep.Batch, false).
+				WithDescription("number of HTTP requests sent one after the other between the idle gaps of the sweep").
+				WithValidators(osutil.Min(1)),
+			osutil.NewEnvVar("OPEN_LOOP", &openLoop, false).
+				WithDescription("send the HTTP requests at CLIENT_TARGET_RPS on a fixed schedule, whether or not the requests before them were responded to, and log how late they started"),
+			osutil.NewEnvVar("MAX_RATE", &maxRate, false).
+				WithDescription("maximum rate, in requests per second, the HTTP requests are sent at across all of them, 0 is unlimited").
+				WithValidators(osutil.Min(0.0)),
+			osutil.NewEnvVar("CLIENT_TARGET_RPS", &targetRPS, false).
+				WithDescription("rate, in requests per second, the HTTP requests are paced at across all of them, making up for the requests delayed by slow responses, 0 sends them as fast as possible").
+				WithValidators(osutil.Min(0.0)),
+			osutil.NewEnvVar("MAX_CONNS_PER_HOST", &transport.MaxConnsPerHost, false).
+				WithDescription("connections the HTTP client opens to the server, concurrent requests beyond it wait for a connection, 0 is unlimited").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("MAX_IDLE_CONNS", &transport.MaxIdleConns, false).
+				WithDescription("idle connections the HTTP/1 and HTTP/2 client keeps open to all the servers, 0 is unlimited").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("MAX_IDLE_CONNS_PER_HOST", &transport.MaxIdleConnsPerHost, false).
+				WithDescription("idle connections the HTTP/1 and HTTP/2 client keeps open to the server, the connections beyond it are closed once idle, 0 keeps the default of 2").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("IDLE_CONN_TIMEOUT", &transport.IdleConnTimeout, false).
+				WithDescription("how long the HTTP/1 and HTTP/2 client keeps idle connections open, 0 keeps them open").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("READ_BUFFER_SIZE", &readBufSize, false).
+				WithDescription("size in bytes of the buffer response bodies are drained through, and HTTP/1 responses read from their connections through, 0 uses the defaults of io.Copy and net/http").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("UPLOAD_LENGTH", &uploadLen, false).
+				WithDescription("amount of random bytes sent as the body of every HTTP request, which are then sent as POST requests, 0 sends GET requests without a body").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("UPLOAD_CHUNKED", &uploadChunked, false).
+				WithDescription("stream the request bodies without a Content-Length, chunked in HTTP/1"),
+			osutil.NewEnvVar("UPLOAD_MULTIPART", &uploadMultipart, false).
+				WithDescription("send the random bytes of UPLOAD_LENGTH as the file of a multipart/form-data form, as browsers upload files"),
+			osutil.NewEnvVar("EXPECT_CONTINUE", &expectContinue, false).
+				WithDescription("send the request bodies only once the server responds 100 Continue to the Expect header of the requests"),
+			osutil.NewEnvVar("TLS_SESSION_TICKETS", &sessionTickets, false).
+				WithDescription("resume the TLS sessions of new connections with the session tickets of the server, instead of a full handshake"),
+			osutil.NewEnvVar("TLS_RESUMPTION_ALTERNATE", &alternateTickets, false).
+				WithDescription("resume the TLS sessions of every other new connection only, with a full handshake on the others, to compare them, requires TLS_SESSION_TICKETS"),
+			osutil.NewEnvVar("TLS_0RTT", &zeroRTT, false).
+				WithDescription("send HTTP/3 requests as TLS 1.3 early data (0-RTT) on connections resuming a session, requires TLS_SESSION_TICKETS"),
+			osutil.NewEnvVar("TLS_CA_FILE", &caFile, false).
+				WithDescription("PEM file of the certificate authorities the certificates of https servers are verified against, instead of those of the system"),
+			osutil.NewEnvVar("TLS_INSECURE", &insecure, false).
+				WithDescription("skip the verification of the certificates of https servers, HTTP/3 ones included, e.g. self-signed ones"),
+			osutil.NewEnvVar("START_DELAY", &startDelay, false).
+				WithDescription("how long to wait before sending the first request, e.g. for collectors to attach"),
+			osutil.NewEnvVar("METRICS_PORT", &metricsPort, false).
+				WithDescription("port Go runtime metrics, and Prometheus metrics of the HTTP requests at /metrics, are served at, empty to disable them").
+				WithValidators(osutil.Match(`^[0-9]*$`)),
+			osutil.NewEnvVar("ASYNC_LOG", &asyncLog, false).
+				WithDescription("buffer the log in memory and write it to stdout in the background, moving the stdout write off the path of the requests, which still take turns to encode and buffer their log entries"),
+			osutil.NewEnvVar("RUNTIME_SAMPLE_INTERVAL", &runtimeInterval, false).
+				WithDescription("interval the Go runtime metrics are sampled at into the log, along with the requests, 0 to not sample them").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("TRACES_ENDPOINT", &tracesEndpoint, false).
+				WithDescription("URL of the OTLP/HTTP collector, e.g. http://collector:4318, the spans of the HTTP requests are exported to, empty to disable tracing").
+				WithValidators(osutil.URL()),
+			osutil.NewEnvVar("TRACES_SERVICE_NAME", &tracesService, false).
+				WithDescription("service name the spans of the HTTP requests are recorded with"),
 		))
-	_, err := url.Parse(endpointUrl)
+	// Run by the modes other than http, which return without running the cleanups.
+	defer osutil.RunCleanups()
+	var logW io.Writer = os.Stdout
+	if asyncLog {
+		aw := osutil.NewAsyncWriter(os.Stdout, 1<<20, 100*time.Millisecond)
+		osutil.RegisterCleanup(aw.Close)
+		logW = aw
+	}
+	logger := schema.NewJSONLogger(logW)
+
+	targets, err := parseTargets(targetUrls, targetWeights)
 	osutil.ExitOnErr(err)
+	var replay []*http.Request
+	if harFile != "" {
+		if len(targets) > 0 || uploadLen > 0 {
+			osutil.ExitOnErr(errors.New("HAR_FILE can not be set together with TARGET_ENDPOINT_URIS or UPLOAD_LENGTH"))
+		}
+		replay, err = client.LoadHAR(context.Background(), harFile)
+		osutil.ExitOnErr(err)
+		if endpointUrl == "" {
+			endpointUrl = replay[0].URL.String()
+		}
+	}
+	var mix []client.MixRequest
+	if mixFile != "" {
+		if len(targets) > 0 || uploadLen > 0 || harFile != "" {
+			osutil.ExitOnErr(errors.New("REQUEST_MIX_FILE can not be set together with TARGET_ENDPOINT_URIS, UPLOAD_LENGTH or HAR_FILE"))
+		}
+		mix, err = client.LoadMix(mixFile)
+		osutil.ExitOnErr(err)
+	}
+	if endpointUrl == "" {
+		if len(targets) == 0 {
+			osutil.ExitOnErr(&osutil.ErrMissingVar{Name: "TARGET_ENDPOINT_URI"})
+		}
+		endpointUrl = targetUrls[0]
+	}
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	// The metrics of the HTTP requests are served along with the runtime metrics once the client is created.
+	metricsMux := http.NewServeMux()
+	if metricsPort != "" {
+		metricsMux.Handle(runtimemetrics.Path, runtimemetrics.Handler())
+		go func() {
+			osutil.ExitOnErr(http.ListenAndServe(":"+metricsPort, metricsMux))
+		}()
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpointUrl, nil)
-	osutil.ExitOnErr(err)
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(startDelay):
+	}
+
+	if runtimeInterval > 0 {
+		stopSampling := runtimemetrics.LogEvery(ctx, logger, runtimeInterval)
+		osutil.RegisterCleanup(func() error {
+			stopSampling()
+			return nil
+		})
+	}
 
-	c, err := client.NewDoTimeRepeatClient(req, logger, client.HttpVersion(httpVersion))
+	switch mode {
+	case modeGRPCUnary, modeGRPCStream:
+		osutil.ExitOnErr(runEcho(ctx, mode, endpointUrl, numOfReqs, logger))
+		return
+	case modeWebSocket:
+		osutil.ExitOnErr(runWebSocket(ctx, endpointUrl, numOfReqs, wsConns, logger))
+		return
+	case modePlugin:
+		osutil.ExitOnErr(runPlugin(ctx, pluginPath, endpointUrl, numOfReqs, logger))
+		return
+	}
+
+	switch {
+	case method != "":
+	case uploadLen > 0:
+		method = http.MethodPost
+	default:
+		method = http.MethodGet
+	}
+	if method == http.MethodHead && expectBodyLen >= 0 {
+		osutil.ExitOnErr(errors.New("EXPECT_BODY_LENGTH can not be set with HTTP_METHOD=HEAD, the responses have no body"))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpointUrl, nil)
 	osutil.ExitOnErr(err)
+	switch {
+	case bearerToken != "" && (basicUser != "" || basicPass != ""):
+		osutil.ExitOnErr(errors.New("AUTH_BEARER_TOKEN and AUTH_BASIC_USER can not be set together"))
+	case bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	case basicUser != "" || basicPass != "":
+		req.SetBasicAuth(basicUser, basicPass)
+	}
+
+	var c *client.DoTimeRepeatClient
+	switch {
+	case library == libraryFastHTTP && httpVersion != 1:
+		osutil.ExitOnErr(errors.New("CLIENT_LIBRARY=fasthttp requires CLIENT_HTTP_VERSION=1"))
+	case library == libraryFastHTTP:
+		c = client.NewDoTimeRepeatClientWithTransport(req, logger, client.NewFastHTTPTransport())
+	default:
+		c, err = client.NewDoTimeRepeatClient(req, logger, client.HttpVersion(httpVersion))
+		osutil.ExitOnErr(err)
+	}
+	if len(targets) > 0 {
+		c.WithTargets(targets, targetSelection)
+	}
+	if len(replay) > 0 {
+		c.WithReplay(replay, targetSelection)
+	}
+	if len(mix) > 0 {
+		c.WithMix(mix, targetSelection)
+	}
+	c.WithTransportOptions(transport)
+	if perWorker {
+		c.WithTransportPerWorker()
+	}
+	if noKeepAlive {
+		c.WithoutKeepAlive()
+	}
+	if maxRedirects >= 0 {
+		c.WithRedirects(maxRedirects)
+	}
+	if reqTimeout > 0 {
+		c.WithRequestTimeout(reqTimeout)
+	}
+	c.WithDrainTimeout(drainTimeout)
+	if metricsPort != "" {
+		c.WithMetrics()
+		metricsMux.Handle(client.MetricsPath, c.MetricsHandler())
+	}
+	if retry.MaxAttempts > 1 {
+		c.WithRetries(retry)
+	}
+	if aggregate {
+		c.WithAggregation()
+	}
+	if abortPercent > 0 {
+		c.WithAbortOnErrorRate(abortPercent, abortWindow)
+	}
+	if len(sloLatencies) > 0 || sloErrorPercent < 100 {
+		latencies := make([]client.LatencySLO, len(sloLatencies))
+		for i, s := range sloLatencies {
+			latencies[i], err = client.ParseLatencySLO(s)
+			osutil.ExitOnErr(err)
+		}
+		c.WithSLOs(latencies, sloErrorPercent)
+	}
+	if thinkTime > 0 {
+		c.WithThinkTime(thinkTime, thinkDist)
+	}
+	if cookieJar {
+		c.WithCookieJar()
+	}
+	if len(acceptEncoding) > 0 {
+		c.WithCompression(acceptEncoding, decompress)
+	}
+	if len(staticHosts) > 0 {
+		hosts, err := parseStaticHosts(staticHosts)
+		osutil.ExitOnErr(err)
+		c.WithStaticHosts(hosts)
+	}
+	if dnsResolver != "" {
+		c.WithResolver(dnsResolver)
+	}
+	if dnsCacheTTL > 0 {
+		c.WithDNSCache(dnsCacheTTL)
+	}
+	switch ipFamily {
+	case "4":
+		c.WithNetwork(client.NetworkIPv4)
+	case "6":
+		c.WithNetwork(client.NetworkIPv6)
+	}
+	if noHappyEyeballs {
+		c.WithoutHappyEyeballs()
+	}
+	sockOpts.EnableNagle = !tcpNoDelay
+	if sockOpts != (client.SocketOptions{}) {
+		c.WithSocketOptions(sockOpts)
+	}
+	switch {
+	case proxyUrl != "" && proxyFromEnv:
+		osutil.ExitOnErr(errors.New("PROXY_URL and PROXY_FROM_ENVIRONMENT can not be set together"))
+	case proxyUrl != "":
+		u, err := url.Parse(proxyUrl)
+		osutil.ExitOnErr(err)
+		c.WithProxy(u)
+	case proxyFromEnv:
+		c.WithProxyFromEnvironment()
+	}
+	switch {
+	case maxRate > 0 && targetRPS > 0:
+		osutil.ExitOnErr(errors.New("MAX_RATE and CLIENT_TARGET_RPS can not be set together"))
+	case openLoop && targetRPS == 0:
+		osutil.ExitOnErr(errors.New("OPEN_LOOP requires CLIENT_TARGET_RPS"))
+	case maxRate > 0:
+		c.WithMaxRate(maxRate)
+	case targetRPS > 0 && !openLoop:
+		c.WithTargetRate(targetRPS)
+	}
+	switch {
+	case sessionTickets && alternateTickets:
+		c.WithAlternatingTLSResumption(zeroRTT)
+	case sessionTickets:
+		c.WithTLSResumption(zeroRTT)
+	}
+	switch {
+	case caFile != "" && insecure:
+		osutil.ExitOnErr(errors.New("TLS_CA_FILE and TLS_INSECURE can not be set together"))
+	case caFile != "":
+		roots, err := client.LoadCertPool(caFile)
+		osutil.ExitOnErr(err)
+		c.WithRootCAs(roots)
+	case insecure:
+		c.WithInsecureSkipVerify()
+	}
+	if tracesEndpoint != "" {
+		tp, err := client.NewTracerProvider(ctx, tracesEndpoint, tracesService)
+		osutil.ExitOnErr(err)
+		// Flushes the queued spans, even when the run was interrupted.
+		osutil.RegisterCleanup(func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return tp.Shutdown(ctx)
+		})
+		c.WithTracer(tp.Tracer(client.TracerName))
+	}
+
+	if readBufSize > 0 {
+		c.WithReadBufferSize(readBufSize)
+	}
+	switch {
+	case uploadLen > 0 && uploadMultipart:
+		c.WithMultipartUploadBody(int64(uploadLen), uploadChunked)
+	case uploadLen > 0:
+		c.WithUploadBody(int64(uploadLen), uploadChunked)
+	}
+	if expectContinue {
+		c.WithExpectContinue(time.Second)
+	}
 
 	respHandler := client.CloseBody
-	if drainClose {
+	switch {
+	case drainClose && partialRead > 0:
+		osutil.ExitOnErr(errors.New("MUST_DRAIN_AND_CLOSE and PARTIAL_READ_BYTES can not be set together"))
+	case partialRead > 0 && readRate > 0:
+		osutil.ExitOnErr(errors.New("PARTIAL_READ_BYTES and READ_RATE_BYTES can not be set together"))
+	case readRate > 0:
+		respHandler = client.ThrottledReadCloseBody(int64(readRate))
+	case partialRead > 0:
+		respHandler = client.ReadCloseBody(int64(partialRead))
+	case drainClose && readBufSize > 0:
+		respHandler = client.DrainCloseBodyBuffered(readBufSize)
+	case drainClose:
 		respHandler = client.DrainCloseBody
 	}
+	if expectStatus != 0 || expectBodyLen >= 0 {
+		respHandler = client.ValidateResponse(respHandler, client.ResponseExpectations{
+			StatusCode: expectStatus,
+			BodyLength: int64(expectBodyLen),
+		})
+	}
 
-	err = c.DoTimeRepeat(ctx, numOfReqs, respHandler, c.LogErr)
+	switch {
+	case ramp.Every > 0 && duration > 0:
+		osutil.ExitOnErr(errors.New("RAMP_INTERVAL and BENCH_DURATION can not be set together"))
+	case ramp.Every > 0 && numOfReqsSet:
+		osutil.ExitOnErr(errors.New("RAMP_INTERVAL and NUMBER_OF_REQUESTS can not be set together, the ramp up sends requests for RAMP_INTERVAL at every concurrency"))
+	case openLoop && (ramp.Every > 0 || duration > 0):
+		osutil.ExitOnErr(errors.New("OPEN_LOOP can not be set together with RAMP_INTERVAL or BENCH_DURATION"))
+	case sweep.Step > 0 && (openLoop || ramp.Every > 0 || duration > 0):
+		osutil.ExitOnErr(errors.New("IDLE_SWEEP_STEP can not be set together with OPEN_LOOP, RAMP_INTERVAL or BENCH_DURATION"))
+	case sweep.Step > 0:
+		err = c.DoTimeRepeatIdleSweep(ctx, sweep, respHandler, c.LogErr)
+	case openLoop:
+		err = c.DoTimeRepeatOpenLoop(ctx, numOfReqs, targetRPS, respHandler, c.LogErr)
+	case ramp.Every > 0:
+		ramp.Start = concurrency
+		err = c.DoTimeRepeatRamp(ctx, ramp, respHandler, c.LogErr)
+	case duration > 0:
+		err = c.DoTimeRepeatFor(ctx, duration, concurrency, respHandler, c.LogErr)
+	default:
+		err = c.DoTimeRepeatConcurrently(ctx, numOfReqs, concurrency, respHandler, c.LogErr)
+	}
 	osutil.ExitOnErr(err)
+	osutil.ExitOnErr(osutil.RunCleanups())
+}
+
+// parseStaticHosts maps the host names of the name=address pairs to their addresses.
+func parseStaticHosts(pairs []string) (map[string]string, error) {
+	hosts := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, addr, _ := strings.Cut(pair, "=")
+		if net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("STATIC_HOSTS address %s of %s is not an IP address", addr, name)
+		}
+		hosts[name] = addr
+	}
+	return hosts, nil
+}
+
+// parseTargets pairs the URLs of the targets with their weights, 1 each if weights is empty.
+func parseTargets(urls, weights []string) ([]client.Target, error) {
+	if len(weights) > 0 && len(weights) != len(urls) {
+		return nil, fmt.Errorf("TARGET_WEIGHTS has %d weights for %d TARGET_ENDPOINT_URIS", len(weights), len(urls))
+	}
+	targets := make([]client.Target, len(urls))
+	for i, rawUrl := range urls {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			return nil, err
+		}
+		targets[i] = client.Target{URL: u, Weight: 1}
+		if len(weights) > 0 {
+			// Weights are validated to be numbers.
+			targets[i].Weight, _ = strconv.Atoi(weights[i])
+		}
+	}
+	return targets, nil
+}
+
+// runEcho sends n calls to the gRPC echo server at the host of endpointUrl.
+func runEcho(ctx context.Context, mode, endpointUrl string, n int, logger *slog.Logger) error {
+	u, payloadLen, err := parseEchoURL(endpointUrl)
+	if err != nil {
+		return err
+	}
+
+	c, err := client.NewEchoClient(u.Host, payloadLen, logger)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if mode == modeGRPCStream {
+		return c.StreamRepeat(ctx, n)
+	}
+	return c.UnaryRepeat(ctx, n)
+}
+
+// runWebSocket sends n messages split across conns connections
+// to the WebSocket echo endpoint of the server at the host of endpointUrl.
+func runWebSocket(ctx context.Context, endpointUrl string, n, conns int, logger *slog.Logger) error {
+	u, payloadLen, err := parseEchoURL(endpointUrl)
+	if err != nil {
+		return err
+	}
+	wsUrl := url.URL{Scheme: "ws", Host: u.Host, Path: server.WebSocketPath}
+	if u.Scheme == "https" {
+		wsUrl.Scheme = "wss"
+	}
+	return client.NewWebSocketClient(wsUrl.String(), conns, payloadLen, logger).RoundTripRepeat(ctx, n)
+}
+
+// runPlugin has the workload plugin at path send n requests to endpointUrl.
+func runPlugin(ctx context.Context, path, endpointUrl string, n int, logger *slog.Logger) error {
+	if path == "" {
+		return &osutil.ErrMissingVar{Name: "WORKLOAD_PLUGIN"}
+	}
+	p, err := workload.Start(ctx, path, logger)
+	if err != nil {
+		return err
+	}
+	return errors.Join(p.Repeat(ctx, n, endpointUrl), p.Close())
+}
+
+// parseEchoURL parses the URL of an echo server.
+//
+// Like the path of HTTP requests sets the size of the response, the
+// path of endpointUrl sets the size of the messages echoed.
+func parseEchoURL(endpointUrl string) (*url.URL, int, error) {
+	u, err := url.Parse(endpointUrl)
+	if err != nil {
+		return nil, 0, err
+	}
+	payloadLen, err := strconv.Atoi(strings.Trim(u.Path, "/"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to convert path %s of %s into a valid amount of bytes", u.Path, endpointUrl)
+	}
+	return u, payloadLen, nil
 }
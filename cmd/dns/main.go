@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/dnsserver"
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/schema"
+)
+
+func main() {
+	port := "53"
+	queryLog := true
+	opts := dnsserver.Options{
+		Zone: "bench.test",
+	}
+	osutil.ExitOnErr(
+		osutil.Loader{Prefix: osutil.EnvPrefix}.LoadFlags(flag.CommandLine, os.Args[1:],
+			osutil.NewEnvVar("DNS_PORT", &port, false).
+				WithDescription("UDP port the DNS server listens at").
+				WithValidators(osutil.Match(`^[0-9]+$`)),
+			osutil.NewEnvVar("DNS_ZONE", &opts.Zone, false).
+				WithDescription("domain whose names are answered with the addresses of the names without it, e.g. server-0.bench.test with those of server-0").
+				WithValidators(osutil.Match(`^[a-z0-9][a-z0-9.-]*$`)),
+			osutil.NewEnvVar("DNS_TTL", &opts.TTL, false).
+				WithDescription("time to live of the records answered, in whole seconds").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("DNS_LATENCY", &opts.Latency, false).
+				WithDescription("how long the server waits before responding to each query").
+				WithValidators(osutil.Min(time.Duration(0))),
+			osutil.NewEnvVar("DNS_ROTATE", &opts.Rotate, false).
+				WithDescription("rotate the order of the records of names with several addresses across responses"),
+			osutil.NewEnvVar("QUERY_LOG", &queryLog, false).
+				WithDescription("write a log entry to stdout for every query answered"),
+		))
+
+	var logger *slog.Logger
+	if queryLog {
+		logger = schema.NewJSONLogger(os.Stdout)
+	}
+
+	srv, err := dnsserver.New(opts, logger)
+	osutil.ExitOnErr(err)
+
+	log.Printf("starting DNS server for %s at UDP port %s ...", opts.Zone, port)
+	osutil.ExitOnErr(srv.ListenAndServe(":" + port))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/results"
+	"github.com/pessolato/httpmicrobench/pkg/schema"
+)
+
+func main() {
+	benchResDir := ""
+	osutil.ExitOnErr(
+		osutil.Loader{Prefix: osutil.EnvPrefix}.LoadFlags(flag.CommandLine, os.Args[1:],
+			osutil.NewEnvVar("BENCH_RESULTS_DIRECTORY", &benchResDir, true).
+				WithDescription("directory containing the results of a benchmark run, or the directories of several runs, to upgrade to the current schema version"),
+		))
+
+	dirs := []string{benchResDir}
+	if _, err := os.Stat(filepath.Join(benchResDir, results.ManifestFileName)); errors.Is(err, os.ErrNotExist) {
+		runs, err := filepath.Glob(filepath.Join(benchResDir, "*", results.ManifestFileName))
+		osutil.ExitOnErr(err)
+		if len(runs) == 0 {
+			osutil.ExitOnErr(fmt.Errorf("no run manifest found in %s or its directories", benchResDir))
+		}
+		dirs = dirs[:0]
+		for _, r := range runs {
+			dirs = append(dirs, filepath.Dir(r))
+		}
+	}
+
+	var errs error
+	for _, dir := range dirs {
+		from, err := results.Migrate(dir)
+		switch {
+		case err != nil:
+			errs = errors.Join(errs, err)
+		case from == schema.Version:
+			log.Printf("%s is already of schema version %d", dir, from)
+		default:
+			log.Printf("migrated %s from schema version %d to %d", dir, from, schema.Version)
+		}
+	}
+	osutil.ExitOnErr(errs)
+}
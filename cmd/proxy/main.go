@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/client"
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/proxy"
+	"github.com/pessolato/httpmicrobench/pkg/runtimemetrics"
+	"github.com/pessolato/httpmicrobench/pkg/schema"
+	"github.com/pessolato/httpmicrobench/pkg/server"
+)
+
+func main() {
+	port := "8080"
+	upstreamURI := ""
+	upstreamHTTPVersion := 1
+	accessLog := true
+	readTimeout := time.Duration(0)
+	writeTimeout := time.Duration(0)
+	metricsPort := ""
+	opts := proxy.Options{
+		DialTimeout:     30 * time.Second,
+		IdleConnTimeout: 90 * time.Second,
+	}
+	osutil.ExitOnErr(
+		osutil.Loader{Prefix: osutil.EnvPrefix}.LoadFlags(flag.CommandLine, os.Args[1:],
+			osutil.NewEnvVar("PROXY_PORT", &port, false).
+				WithDescription("port the proxy listens at").
+				WithValidators(osutil.Match(`^[0-9]+$`)),
+			osutil.NewEnvVar("UPSTREAM_URI", &upstreamURI, true).
+				WithDescription("URI of the upstream server the requests are forwarded to").
+				WithValidators(osutil.URL()),
+			osutil.NewEnvVar("UPSTREAM_HTTP_VERSION", &upstreamHTTPVersion, false).
+				WithDescription("HTTP protocol version used toward the upstream, 1, 2 or 3").
+				WithValidators(osutil.OneOf(1, 2, 3)),
+			osutil.NewEnvVar("FLUSH_INTERVAL", &opts.FlushInterval, false).
+				WithDescription("how often responses are flushed to the client while copied, 0 buffers them and -1ns flushes every write"),
+			osutil.NewEnvVar("BUFFER_SIZE", &opts.BufferSize, false).
+				WithDescription("size in bytes of the buffers responses are copied through, 0 for the default").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("DIAL_TIMEOUT", &opts.DialTimeout, false).
+				WithDescription("timeout of the connections to the upstream"),
+			osutil.NewEnvVar("RESPONSE_HEADER_TIMEOUT", &opts.ResponseHeaderTimeout, false).
+				WithDescription("timeout waiting for the response headers of the upstream, 0 for none"),
+			osutil.NewEnvVar("IDLE_CONN_TIMEOUT", &opts.IdleConnTimeout, false).
+				WithDescription("how long idle upstream connections are kept open"),
+			osutil.NewEnvVar("MAX_IDLE_CONNS_PER_HOST", &opts.MaxIdleConnsPerHost, false).
+				WithDescription("amount of idle upstream connections kept open, 0 for the default").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("READ_TIMEOUT", &readTimeout, false).
+				WithDescription("timeout reading client requests, 0 for none"),
+			osutil.NewEnvVar("WRITE_TIMEOUT", &writeTimeout, false).
+				WithDescription("timeout writing client responses, 0 for none"),
+			osutil.NewEnvVar("ACCESS_LOG", &accessLog, false).
+				WithDescription("write an access log entry to stdout for every request proxied"),
+			osutil.NewEnvVar("METRICS_PORT", &metricsPort, false).
+				WithDescription("port Go runtime metrics are served at, empty to disable them").
+				WithValidators(osutil.Match(`^[0-9]*$`)),
+		))
+	opts.UpstreamHTTPVersion = client.HttpVersion(upstreamHTTPVersion)
+
+	upstream, err := url.Parse(upstreamURI)
+	osutil.ExitOnErr(err)
+
+	var logger *slog.Logger
+	if accessLog {
+		logger = schema.NewJSONLogger(os.Stdout)
+	}
+
+	h, err := proxy.New(upstream, opts, logger)
+	osutil.ExitOnErr(err)
+	if logger != nil {
+		h = server.AccessLog(logger, h)
+	}
+
+	if metricsPort != "" {
+		go func() {
+			log.Printf("serving runtime metrics at port %s ...", metricsPort)
+			osutil.ExitOnErr(runtimemetrics.ListenAndServe(":" + metricsPort))
+		}()
+	}
+
+	// Clients can reach the proxy over HTTP/1.1 or unencrypted HTTP/2,
+	// as they reach the server.
+	protos := &http.Protocols{}
+	protos.SetHTTP1(true)
+	protos.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Addr:         ":" + port,
+		Handler:      h,
+		Protocols:    protos,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+	}
+
+	log.Printf("starting proxy at port %s to %s ...", port, upstream)
+	osutil.ExitOnErr(srv.ListenAndServe())
+}
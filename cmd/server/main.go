@@ -1,19 +1,137 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
 
+	"github.com/pessolato/httpmicrobench/pkg/client"
 	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/runtimemetrics"
+	"github.com/pessolato/httpmicrobench/pkg/schema"
 	"github.com/pessolato/httpmicrobench/pkg/server"
+	"github.com/quic-go/quic-go/http3"
 )
 
 func main() {
 	port := "8080"
+	accessLog := true
+	grpcPort := "9090"
+	h3Port := "8443"
+	tlsPort := "8444"
+	certFile := ""
+	keyFile := ""
+	healthCheckURI := ""
+	metricsPort := ""
+	sessionTickets := true
+	h2MaxStreams := 0
+	zeroRTT := true
 	osutil.ExitOnErr(
-		osutil.Load(
-			osutil.NewEnvVar("TEST_SERVER_PORT", &port, false),
+		osutil.Loader{Prefix: osutil.EnvPrefix}.LoadFlags(flag.CommandLine, os.Args[1:],
+			osutil.NewEnvVar("TEST_SERVER_PORT", &port, false).
+				WithDescription("port the server listens at").
+				WithValidators(osutil.Match(`^[0-9]+$`)),
+			osutil.NewEnvVar("ACCESS_LOG", &accessLog, false).
+				WithDescription("write an access log entry to stdout for every request served"),
+			osutil.NewEnvVar("GRPC_PORT", &grpcPort, false).
+				WithDescription("port the gRPC echo server listens at, 0 to disable it").
+				WithValidators(osutil.Match(`^[0-9]+$`)),
+			osutil.NewEnvVar("H3_PORT", &h3Port, false).
+				WithDescription("UDP port the HTTP/3 server listens at, 0 to disable it").
+				WithValidators(osutil.Match(`^[0-9]+$`)),
+			osutil.NewEnvVar("TLS_PORT", &tlsPort, false).
+				WithDescription("TCP port the HTTPS server, serving HTTP/1.1 and HTTP/2 over TLS, listens at, 0 to disable it").
+				WithValidators(osutil.Match(`^[0-9]+$`)),
+			osutil.NewEnvVar("TLS_CERT_FILE", &certFile, false).
+				WithDescription("PEM file of the certificate of the HTTPS and HTTP/3 servers, a self-signed one is generated if not set"),
+			osutil.NewEnvVar("TLS_KEY_FILE", &keyFile, false).
+				WithDescription("PEM file of the private key of the TLS_CERT_FILE certificate"),
+			osutil.NewEnvVar("H2_MAX_CONCURRENT_STREAMS", &h2MaxStreams, false).
+				WithDescription("concurrent streams each HTTP/2 connection of a client is limited to, 0 uses the default of net/http").
+				WithValidators(osutil.Min(0)),
+			osutil.NewEnvVar("TLS_SESSION_TICKETS", &sessionTickets, false).
+				WithDescription("issue TLS session tickets, so HTTP/3 and HTTPS clients can resume their sessions instead of a full handshake"),
+			osutil.NewEnvVar("TLS_0RTT", &zeroRTT, false).
+				WithDescription("accept HTTP/3 requests sent as TLS 1.3 early data (0-RTT) by clients resuming their sessions"),
+			osutil.NewEnvVar("HEALTH_CHECK_URI", &healthCheckURI, false).
+				WithDescription("check the HTTP/3 server responds at the URI and exit instead of serving, used as container health check").
+				WithValidators(osutil.URL()),
+			osutil.NewEnvVar("METRICS_PORT", &metricsPort, false).
+				WithDescription("port Go runtime metrics are served at, empty to disable them").
+				WithValidators(osutil.Match(`^[0-9]*$`)),
 		))
+	if (certFile == "") != (keyFile == "") {
+		osutil.ExitOnErr(errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if healthCheckURI != "" {
+		osutil.ExitOnErr(healthCheck(healthCheckURI))
+		return
+	}
+
+	var logger *slog.Logger
+	if accessLog {
+		logger = schema.NewJSONLogger(os.Stdout)
+	}
+
+	if metricsPort != "" {
+		go func() {
+			log.Printf("serving runtime metrics at port %s ...", metricsPort)
+			osutil.ExitOnErr(runtimemetrics.ListenAndServe(":" + metricsPort))
+		}()
+	}
+
+	if grpcPort != "0" {
+		go func() {
+			log.Printf("starting gRPC server at port %s ...", grpcPort)
+			osutil.ExitOnErr(server.ListenAndServeEcho(":"+grpcPort, logger))
+		}()
+	}
+
+	if h3Port != "0" {
+		go func() {
+			log.Printf("starting HTTP/3 server at UDP port %s ...", h3Port)
+			osutil.ExitOnErr(server.ListenAndServeRandH3(":"+h3Port, certFile, keyFile, server.TLSResumption{
+				DisableSessionTickets: !sessionTickets,
+				Disable0RTT:           !zeroRTT,
+			}, logger))
+		}()
+	}
+
+	h2 := http.HTTP2Config{MaxConcurrentStreams: h2MaxStreams}
+	if tlsPort != "0" {
+		go func() {
+			log.Printf("starting HTTPS server at port %s ...", tlsPort)
+			osutil.ExitOnErr(server.ListenAndServeRandTLS(":"+tlsPort, certFile, keyFile, h2, server.TLSResumption{
+				DisableSessionTickets: !sessionTickets,
+			}, logger))
+		}()
+	}
 
 	log.Printf("starting server at port %s ...", port)
-	osutil.ExitOnErr(server.ListenAndServeRand(":" + port))
+	osutil.ExitOnErr(server.ListenAndServeRand(":"+port, h2, logger))
+}
+
+// healthCheck sends a single HTTP/3 request to uri, failing if it does not succeed within 2 seconds.
+func healthCheck(uri string) error {
+	c, err := client.NewHTTPClient(client.HTTP3)
+	if err != nil {
+		return err
+	}
+	// The server checks itself, its certificate may be self-signed.
+	c.Transport.(*http3.Transport).TLSClientConfig.InsecureSkipVerify = true
+	c.Timeout = 2 * time.Second
+	resp, err := c.Get(uri)
+	if err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check failed with status %s", resp.Status)
+	}
+	return nil
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// levelShiftWindow is the amount of seconds compared
+// before and after a point to detect level shifts.
+const levelShiftWindow = 5
+
+// secondBucket aggregates the requests completed within the same second.
+type secondBucket struct {
+	start    time.Time
+	mean     float64
+	count    int
+	newConns int
+	failures int
+}
+
+// latencyAnomaly is a time window where the latency deviates from the rest of the run.
+type latencyAnomaly struct {
+	bucket      secondBucket
+	kind        string
+	score       float64
+	before, now float64
+}
+
+// bucketBySecond builds the per-second time series of the mean request latency.
+func bucketBySecond(recs []results.RequestRecord) []secondBucket {
+	idx := make(map[int64]int)
+	var buckets []secondBucket
+	for _, r := range recs {
+		sec := r.Time.Truncate(time.Second)
+		i, ok := idx[sec.Unix()]
+		if !ok {
+			i = len(buckets)
+			idx[sec.Unix()] = i
+			buckets = append(buckets, secondBucket{start: sec})
+		}
+
+		b := &buckets[i]
+		if !r.Reused {
+			b.newConns++
+		}
+		if r.Failed {
+			b.failures++
+		}
+		if r.MaxTimeNano == 0 {
+			continue
+		}
+		b.mean += (float64(r.MaxTimeNano) - b.mean) / float64(b.count+1)
+		b.count++
+	}
+
+	slices.SortFunc(buckets, func(a, b secondBucket) int { return a.start.Compare(b.start) })
+	return buckets
+}
+
+// detectAnomalies flags latency level shifts and spikes in the per-second series.
+//
+// Level shifts are seconds where the median of the following window moves away
+// from the median of the preceding window by more than threshold MADs (median
+// absolute deviation). The series is then split at the level shifts and, within
+// each segment, seconds whose modified z-score exceeds threshold are flagged as spikes.
+func detectAnomalies(buckets []secondBucket, threshold float64) []latencyAnomaly {
+	var series []float64
+	var withData []secondBucket
+	for _, b := range buckets {
+		if b.count > 0 {
+			series = append(series, b.mean)
+			withData = append(withData, b)
+		}
+	}
+
+	mad := medianAbsDev(series, medianOf(series))
+	if mad == 0 {
+		return nil
+	}
+
+	var anomalies []latencyAnomaly
+	// Shifted windows overlap, so consecutive seconds above the threshold
+	// are reported once, at the middle of the run where the windows align.
+	shifts := []int{0}
+	runStart := -1
+	for i := levelShiftWindow; i <= len(series); i++ {
+		var score float64
+		if i+levelShiftWindow <= len(series) {
+			score = (medianOf(series[i:i+levelShiftWindow]) - medianOf(series[i-levelShiftWindow:i])) / mad
+		}
+		if math.Abs(score) > threshold {
+			if runStart < 0 {
+				runStart = i
+			}
+			continue
+		}
+		if runStart >= 0 {
+			mid := (runStart + i - 1) / 2
+			before := medianOf(series[mid-levelShiftWindow : mid])
+			after := medianOf(series[mid:min(mid+levelShiftWindow, len(series))])
+			anomalies = append(anomalies, latencyAnomaly{bucket: withData[mid], kind: "level shift", score: (after - before) / mad, before: before, now: after})
+			shifts = append(shifts, mid)
+			runStart = -1
+		}
+	}
+	shifts = append(shifts, len(series))
+
+	for i := 1; i < len(shifts); i++ {
+		segment := series[shifts[i-1]:shifts[i]]
+		med := medianOf(segment)
+		segMad := medianAbsDev(segment, med)
+		if segMad == 0 {
+			segMad = mad
+		}
+		for j, v := range segment {
+			if z := 0.6745 * (v - med) / segMad; math.Abs(z) > threshold {
+				anomalies = append(anomalies, latencyAnomaly{bucket: withData[shifts[i-1]+j], kind: "spike", score: z, before: med, now: v})
+			}
+		}
+	}
+
+	slices.SortStableFunc(anomalies, func(a, b latencyAnomaly) int { return a.bucket.start.Compare(b.bucket.start) })
+	return anomalies
+}
+
+// printAnomalies lists the anomalous time windows with the trace events that happened in them.
+func printAnomalies(anomalies []latencyAnomaly, format reportFormat) {
+	if len(anomalies) < 1 {
+		fmt.Printf("Latency Anomalies: none\n\n")
+		return
+	}
+
+	fmt.Println("Latency Anomalies:")
+	for _, a := range anomalies {
+		fmt.Printf(
+			"- %s at %s (1s window): %s -> %s, score %.2f, requests %d, new conns %d, failures %d\n",
+			a.kind,
+			a.bucket.start.Format(time.RFC3339),
+			format.duration(int64(a.before)),
+			format.duration(int64(a.now)),
+			a.score,
+			a.bucket.count,
+			a.bucket.newConns,
+			a.bucket.failures,
+		)
+	}
+	fmt.Println()
+}
+
+func medianOf(vs []float64) float64 {
+	if len(vs) < 1 {
+		return 0
+	}
+	s := slices.Clone(vs)
+	_, _, _, median := summarizeStats(s)
+	return median
+}
+
+func medianAbsDev(vs []float64, med float64) float64 {
+	devs := make([]float64, len(vs))
+	for i, v := range vs {
+		devs[i] = math.Abs(v - med)
+	}
+	return medianOf(devs)
+}
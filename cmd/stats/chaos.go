@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// readChaosEvents reads the chaos events of the run in dir, if any were recorded.
+func readChaosEvents(dir string, m results.Manifest) ([]results.ChaosEvent, error) {
+	if m.ChaosFile == "" {
+		return nil, nil
+	}
+	f, err := os.Open(filepath.Join(dir, m.ChaosFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return results.ReadChaosEvents(f)
+}
+
+// printChaosSummary summarizes how the clients were affected by each container
+// taken down during the run: how many of their requests failed, how long
+// after it was taken down a request succeeded again and how long that
+// request, which usually had to connect again, took.
+//
+// Clients are affected by a container if it is their target, or the target of their proxy.
+func printChaosSummary(dir string, m results.Manifest, format reportFormat) {
+	events, err := readChaosEvents(dir, m)
+	osutil.ExitOnErr(err)
+
+	for i, ev := range events {
+		if ev.Action == results.ChaosStart {
+			continue
+		}
+		// The effects of an action last until the container is taken down again.
+		end := time.Time{}
+		if j := slices.IndexFunc(events[i+1:], func(e results.ChaosEvent) bool {
+			return e.Container == ev.Container && e.Action != results.ChaosStart
+		}); j >= 0 {
+			end = events[i+1+j].Time
+		}
+		fmt.Printf("Summarizing chaos %s of %s container at %s\n", ev.Action, ev.Container, ev.Time.Format(time.RFC3339Nano))
+		for _, cli := range m.Containers {
+			if cli.Role != results.RoleClient || cli.LogFile == "" || !reaches(m, cli, ev.Container) {
+				continue
+			}
+			f, err := os.Open(filepath.Join(dir, cli.LogFile))
+			osutil.ExitOnErr(err)
+			recs, err := results.ReadRequestRecords(f)
+			f.Close()
+			osutil.ExitOnErr(err)
+
+			failed, recovery, ok := chaosRecovery(recs, ev.Time, end)
+			fmt.Printf("%s:\n- Failed Requests: %d\n", cli.Name, failed)
+			if !ok {
+				fmt.Printf("- Recovery Time: not recovered\n")
+				continue
+			}
+			fmt.Printf(
+				"- Recovery Time: %s\n- Reconnect Latency: %s\n",
+				format.duration(recovery.Time.Sub(ev.Time).Nanoseconds()),
+				format.duration(recovery.MaxTimeNano),
+			)
+		}
+		fmt.Println()
+	}
+}
+
+// chaosRecovery returns the amount of requests that failed after a container
+// was taken down at down, until end if not zero, and the first request started
+// after it that succeeded, if any, which is when the client recovered.
+func chaosRecovery(recs []results.RequestRecord, down, end time.Time) (failed int, recovery results.RequestRecord, ok bool) {
+	for _, r := range recs {
+		if r.Time.Before(down) || (!end.IsZero() && !r.Time.Before(end)) {
+			continue
+		}
+		if r.Failed {
+			failed++
+			continue
+		}
+		// Requests sent before the container was taken down may still succeed.
+		start := r.Time.Add(-time.Duration(r.MaxTimeNano))
+		if r.MaxTimeNano == 0 || start.Before(down) {
+			continue
+		}
+		if !ok || r.Time.Before(recovery.Time) {
+			recovery, ok = r, true
+		}
+	}
+	return failed, recovery, ok
+}
+
+// reaches reports whether the requests of the container c reach the container
+// named target, either directly or through the proxies in between.
+func reaches(m results.Manifest, c results.ManifestContainer, target string) bool {
+	for range len(m.Containers) {
+		if c.Target == target {
+			return true
+		}
+		i := slices.IndexFunc(m.Containers, func(mc results.ManifestContainer) bool {
+			return mc.Name == c.Target
+		})
+		if i < 0 {
+			return false
+		}
+		c = m.Containers[i]
+	}
+	return false
+}
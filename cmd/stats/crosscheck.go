@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// requestTally holds the amount of requests and response bytes
+// accounted for by either side of a client/server pair, and the
+// request bytes received by servers.
+type requestTally struct {
+	completed, failed int
+	bytes, received   int64
+}
+
+// printCrossCheck reconciles the requests observed by each server,
+// through its access logs, with the requests its clients report.
+//
+// Proxies are checked both as servers of their clients and as clients of their target.
+// Servers without an access log in the manifest are skipped.
+func printCrossCheck(dir string, m results.Manifest, format reportFormat) {
+	events, err := readChaosEvents(dir, m)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("unable to read chaos events: %w", err))
+	}
+	for _, srv := range m.Containers {
+		if (srv.Role != results.RoleServer && srv.Role != results.RoleProxy) || srv.LogFile == "" {
+			continue
+		}
+
+		served, err := tallyLogFile(filepath.Join(dir, srv.LogFile))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("unable to cross-check %s container: %w", srv.Name, err))
+			continue
+		}
+
+		var sent requestTally
+		for _, cli := range m.Containers {
+			if (cli.Role != results.RoleClient && cli.Role != results.RoleProxy) || cli.Target != srv.Name || cli.LogFile == "" {
+				continue
+			}
+			t, err := tallyLogFile(filepath.Join(dir, cli.LogFile))
+			osutil.ExitOnErr(err)
+			sent.completed += t.completed
+			sent.failed += t.failed
+			respLen := m.ResponseLength
+			if cli.ResponseLength != 0 {
+				respLen = cli.ResponseLength
+			}
+			sent.bytes += int64(t.completed) * int64(respLen)
+		}
+
+		fmt.Printf("Cross-checking %s container with its clients\n", srv.Name)
+		fmt.Printf(
+			"Requests:\n- Served: %d\n- Client Completed: %d\n- Client Failed: %d\n",
+			served.completed,
+			sent.completed,
+			sent.failed,
+		)
+		fmt.Printf("Bytes:\n- Served: %s\n- Client Expected: %s\n", format.bytes(served.bytes), format.bytes(sent.bytes))
+		switch {
+		case served.completed < sent.completed:
+			fmt.Printf("DISCREPANCY: %d completed requests were not observed by the server (dropped requests)\n", sent.completed-served.completed)
+		case served.completed > sent.completed+sent.failed:
+			fmt.Printf("DISCREPANCY: server observed %d requests more than clients sent (duplicate or retried requests)\n", served.completed-sent.completed-sent.failed)
+		}
+		if served.bytes != sent.bytes {
+			fmt.Printf("DISCREPANCY: server wrote %s bytes, clients expected %s\n", format.bytes(served.bytes), format.bytes(sent.bytes))
+		}
+		if slices.ContainsFunc(events, func(e results.ChaosEvent) bool { return e.Container == srv.Name }) {
+			// Logs are only followed until the container is first taken down.
+			fmt.Println("NOTE: the container was taken down during the run, discrepancies are expected")
+		}
+		fmt.Println()
+	}
+}
+
+// tallyLogFile counts the completed and failed requests in a log file.
+//
+// Client completions and server access log entries both count as completed requests.
+func tallyLogFile(path string) (requestTally, error) {
+	var t requestTally
+	for e, err := range decodeLines[logEntry](path) {
+		if err != nil {
+			return t, err
+		}
+		switch e.Msg {
+		case "req completion":
+			t.completed++
+		case "req served":
+			t.completed++
+			t.bytes += e.BytesWritten
+			t.received += e.BytesRead
+		case "req failed":
+			t.failed++
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+)
+
+// dnsQueryEntry is a query logged by the DNS server.
+type dnsQueryEntry struct {
+	Msg            string `json:"msg"`
+	Name           string `json:"name"`
+	RCode          string `json:"rcode"`
+	AnswerTimeNano int64  `json:"answer_time_nano"`
+}
+
+// printDNSSummary summarizes the queries answered by the DNS server, so how often
+// the clients resolved the names of the servers can be compared across runs.
+func printDNSSummary(path string, format reportFormat) {
+	fmt.Printf("Summarizing DNS queries from file: %s\n", path)
+	var answerTimesNano []int64
+	byName := make(map[string]int)
+	byRCode := make(map[string]int)
+	for e, err := range decodeLines[dnsQueryEntry](path) {
+		osutil.ExitOnErr(err)
+		if e.Msg != "dns query" {
+			continue
+		}
+		answerTimesNano = append(answerTimesNano, e.AnswerTimeNano)
+		byName[e.Name]++
+		byRCode[e.RCode]++
+	}
+
+	fmt.Printf("Queries: %d\n", len(answerTimesNano))
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		fmt.Printf("- %s: %d\n", name, byName[name])
+	}
+	fmt.Println("Response Codes:")
+	for _, rcode := range slices.Sorted(maps.Keys(byRCode)) {
+		fmt.Printf("- %s: %d\n", rcode, byRCode[rcode])
+	}
+	min, max, mean, median := summarizeStats(answerTimesNano)
+	fmt.Printf(
+		"Answer Time:\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+		format.duration(min),
+		format.duration(max),
+		format.duration(mean),
+		format.duration(median),
+	)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// latencyUnits maps the supported latency unit names to their size.
+var latencyUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// reportFormat controls how numbers are written in the reports.
+//
+// An empty unit humanizes latencies with [time.Duration.String].
+// Raw numbers are written without unit suffixes or humanization,
+// which is what downstream tools usually want to parse.
+type reportFormat struct {
+	unit     string
+	decimals int
+	raw      bool
+}
+
+// newReportFormat validates the formatting options and returns the [reportFormat].
+func newReportFormat(unit string, decimals int, raw bool) (reportFormat, error) {
+	if _, ok := latencyUnits[unit]; unit != "" && !ok {
+		return reportFormat{}, fmt.Errorf("invalid latency unit %s, must be one of ns, us, µs, ms or s", unit)
+	}
+	if decimals < 0 {
+		return reportFormat{}, fmt.Errorf("invalid number of decimals %d, must not be negative", decimals)
+	}
+	return reportFormat{unit: unit, decimals: decimals, raw: raw}, nil
+}
+
+// duration formats a latency given in nanoseconds.
+func (f reportFormat) duration(nanos int64) string {
+	unit := f.unit
+	if unit == "" {
+		if !f.raw {
+			return time.Duration(nanos).String()
+		}
+		unit = "ns"
+	}
+
+	v := float64(nanos) / float64(latencyUnits[unit])
+	s := strconv.FormatFloat(v, 'f', f.decimals, 64)
+	if unit == "ns" {
+		s = strconv.FormatInt(nanos, 10)
+	}
+	if f.raw {
+		return s
+	}
+	return s + unit
+}
+
+// percent formats a percentage.
+func (f reportFormat) percent(v float64) string {
+	s := strconv.FormatFloat(v, 'f', f.decimals, 64)
+	if f.raw {
+		return s
+	}
+	return s + "%"
+}
+
+// bytes formats an amount of bytes, using binary prefixes unless raw.
+func (f reportFormat) bytes(n int64) string {
+	if f.raw || n < 1024 {
+		return strconv.FormatInt(n, 10)
+	}
+	v, prefixes := float64(n), "KMGTPE"
+	i := -1
+	for v >= 1024 && i < len(prefixes)-1 {
+		v /= 1024
+		i++
+	}
+	return strconv.FormatFloat(v, 'f', f.decimals, 64) + " " + string(prefixes[i]) + "iB"
+}
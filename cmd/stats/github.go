@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// stepSummaryVar is the environment variable GitHub Actions sets to the
+// file the Markdown job summary of the current step is appended to.
+const stepSummaryVar = "GITHUB_STEP_SUMMARY"
+
+// writeGitHubReport appends a Markdown table of the results of the clients of the
+// run in dir to the job summary of the GitHub Actions step, or writes it to stdout
+// outside of GitHub Actions.
+//
+// If baselineDir is not empty, the request times are compared with those of the
+// clients of the same name in the baseline run, and a warning annotation is
+// written to stdout for every median or 99th percentile more than threshold
+// percent slower than its baseline.
+func writeGitHubReport(dir, baselineDir string, threshold float64, format reportFormat) error {
+	sums, err := results.SummarizeClients(dir)
+	if err != nil {
+		return err
+	}
+	base := make(map[string]results.ClientSummary)
+	if baselineDir != "" {
+		baseSums, err := results.SummarizeClients(baselineDir)
+		if err != nil {
+			return fmt.Errorf("error to summarize baseline: %w", err)
+		}
+		for _, s := range baseSums {
+			base[s.Name] = s
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Benchmark results of %s\n\n", filepath.Base(dir))
+	if baselineDir != "" {
+		fmt.Fprintf(&b, "Compared with the baseline %s, request times more than %s slower are in bold.\n\n",
+			filepath.Base(baselineDir), format.percent(threshold))
+	}
+	b.WriteString("| Client | Completed | Failed | Mean | Median | P99 |\n")
+	b.WriteString("| --- | ---: | ---: | ---: | ---: | ---: |\n")
+	for _, s := range sums {
+		bs, hasBase := base[s.Name]
+		// cell formats a request time with its change from the baseline, and annotates its regressions.
+		cell := func(label string, nanos, baseNanos int64) string {
+			v := format.duration(nanos)
+			if !hasBase || baseNanos == 0 {
+				return v
+			}
+			delta := 100 * float64(nanos-baseNanos) / float64(baseNanos)
+			sign := "+"
+			if delta < 0 {
+				sign = ""
+			}
+			v = fmt.Sprintf("%s (%s%s)", v, sign, format.percent(delta))
+			if label == "" || delta <= threshold {
+				return v
+			}
+			annotate(os.Stdout, "Latency regression", fmt.Sprintf("%s %s request time %s is %s slower than the baseline %s",
+				s.Name, label, format.duration(nanos), format.percent(delta), format.duration(baseNanos)))
+			return "**" + v + "**"
+		}
+		fmt.Fprintf(&b, "| %s | %d | %d | %s | %s | %s |\n", s.Name, s.Completed, s.Failed,
+			cell("", s.MeanNano, bs.MeanNano),
+			cell("median", s.MedianNano, bs.MedianNano),
+			cell("99th percentile", s.P99Nano, bs.P99Nano))
+	}
+	b.WriteString("\n")
+
+	path := os.Getenv(stepSummaryVar)
+	if path == "" {
+		_, err := io.WriteString(os.Stdout, b.String())
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error to open job summary: %w", err)
+	}
+	_, err = f.WriteString(b.String())
+	if err = errors.Join(err, f.Close()); err != nil {
+		return fmt.Errorf("error to write job summary: %w", err)
+	}
+	return nil
+}
+
+// annotate writes a GitHub Actions workflow command to w,
+// which shows the warning msg titled title on the workflow run.
+func annotate(w io.Writer, title, msg string) {
+	fmt.Fprintf(w, "::warning title=%s::%s\n", escapeWorkflowProperty(title), escapeWorkflowData(msg))
+}
+
+// escapeWorkflowData escapes the characters workflow commands do not allow in their data.
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeWorkflowProperty escapes the characters workflow commands do not allow in their properties.
+func escapeWorkflowProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
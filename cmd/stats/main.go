@@ -3,15 +3,21 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
+	"iter"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/results"
 )
 
 type logEntry struct {
@@ -27,6 +33,32 @@ type logEntry struct {
 	Status      bool      `json:"status,omitempty"`
 	StatusCode  int       `json:"status_code,omitempty"`
 	MaxTimeNano int64     `json:"max_time_nano,omitempty"`
+
+	BytesWritten    int64 `json:"bytes_written,omitempty"`
+	BytesRead       int64 `json:"bytes_read,omitempty"`
+	ServeTimeNano   int64 `json:"serve_time_nano,omitempty"`
+	ConnectTimeNano int64 `json:"connect_time_nano,omitempty"`
+
+	UpstreamReused   bool  `json:"upstream_reused,omitempty"`
+	UpstreamTimeNano int64 `json:"upstream_time_nano,omitempty"`
+	ProxyTimeNano    int64 `json:"proxy_time_nano,omitempty"`
+
+	TargetRPS   float64 `json:"target_rps,omitempty"`
+	AchievedRPS float64 `json:"achieved_rps,omitempty"`
+
+	DurationNano int64 `json:"duration_nano,omitempty"`
+	Requests     int64 `json:"requests,omitempty"`
+	Completed    int64 `json:"completed,omitempty"`
+	ElapsedNano  int64 `json:"elapsed_nano,omitempty"`
+
+	MinNano   int64 `json:"min_nano,omitempty"`
+	MaxNano   int64 `json:"max_nano,omitempty"`
+	MeanNano  int64 `json:"mean_nano,omitempty"`
+	P50Nano   int64 `json:"p50_nano,omitempty"`
+	P90Nano   int64 `json:"p90_nano,omitempty"`
+	P99Nano   int64 `json:"p99_nano,omitempty"`
+	P999Nano  int64 `json:"p999_nano,omitempty"`
+	P9999Nano int64 `json:"p9999_nano,omitempty"`
 }
 
 type statEntry struct {
@@ -47,23 +79,92 @@ type statEntry struct {
 
 func main() {
 	benchResDir := ""
+	allowPartial := false
+	parquetDir := ""
+	githubReport := false
+	baselineDir := ""
+	regressionThreshold := 10.0
+	whereFlag := flag.String("where", "", "only summarize requests matching the expression, e.g. 'status_code>=500 && reused==false'")
+	unitFlag := flag.String("unit", "", "latency unit, one of ns, us, µs, ms or s (default: humanized)")
+	decimalsFlag := flag.Int("decimals", 2, "number of decimals of fractional values")
+	rawFlag := flag.Bool("raw", false, "write raw numbers without units or humanization")
+	anomalyFlag := flag.Float64("anomaly-threshold", 3.5, "score above which per-second latencies are flagged as anomalies, 0 disables the detection")
 	osutil.ExitOnErr(
-		osutil.Load(
-			osutil.NewEnvVar("BENCH_RESULTS_DIRECTORY", &benchResDir, true),
+		osutil.Loader{Prefix: osutil.EnvPrefix}.LoadFlags(flag.CommandLine, os.Args[1:],
+			osutil.NewEnvVar("BENCH_RESULTS_DIRECTORY", &benchResDir, true).
+				WithDescription("directory containing the results of a benchmark run"),
+			osutil.NewEnvVar("ALLOW_PARTIAL_RESULTS", &allowPartial, false).
+				WithDescription("summarize results even when the integrity validation reports issues"),
+			osutil.NewEnvVar("PARQUET_EXPORT_DIRECTORY", &parquetDir, false).
+				WithDescription("directory to export the per-request records of each client to as Parquet files"),
+			osutil.NewEnvVar("GITHUB_REPORT", &githubReport, false).
+				WithDescription("write a Markdown table of the results of the clients to the GitHub Actions job summary, and annotate their regressions from the baseline"),
+			osutil.NewEnvVar("BASELINE_RESULTS_DIRECTORY", &baselineDir, false).
+				WithDescription("directory containing the results of the benchmark run the GitHub report compares the request times with"),
+			osutil.NewEnvVar("REGRESSION_THRESHOLD", &regressionThreshold, false).
+				WithDescription("percentage the median or 99th percentile request time of a client can be slower than the baseline before it is annotated as a regression").
+				WithValidators(osutil.Min(0.0)),
 		))
 
+	format, err := newReportFormat(*unitFlag, *decimalsFlag, *rawFlag)
+	osutil.ExitOnErr(err)
+
+	var where whereExpr
+	if *whereFlag != "" {
+		where, err = parseWhere(*whereFlag)
+		osutil.ExitOnErr(err)
+	}
+
+	if issues := validateRun(benchResDir); len(issues) > 0 {
+		printIssues(issues)
+		if !allowPartial {
+			osutil.ExitOnErr(fmt.Errorf("refusing to summarize incomplete results, set ALLOW_PARTIAL_RESULTS=true to summarize anyway"))
+		}
+	}
+
 	osutil.ExitOnErr(
 		filepath.WalkDir(benchResDir, func(path string, d fs.DirEntry, err error) error {
 			if d.IsDir() {
 				return nil
 			}
 
+			if strings.HasSuffix(path, "-runtime.jsonl") {
+				printRuntimeSummary(path, format)
+				return nil
+			}
+			if strings.HasSuffix(path, "-queries.jsonl") {
+				printDNSSummary(path, format)
+				return nil
+			}
+			if strings.HasSuffix(path, "-perf.jsonl") {
+				printPerfSummary(path, format)
+				return nil
+			}
 			if strings.Contains(path, "logs.jsonl") {
-				printLogSummary(path)
+				if isProxyFile(d.Name()) {
+					printProxySummary(path, format)
+					return nil
+				}
+				if isServerFile(d.Name(), results.ManifestContainer{}) {
+					// Server access logs are summarized by the cross-check.
+					return nil
+				}
+				printLogSummary(path, where, format, *anomalyFlag)
+				printConnectSummary(path, format)
+				if samples := readRuntimeSamples(path); len(samples) > 0 {
+					printRuntimeSamples(samples, format)
+				}
+				if parquetDir != "" {
+					exportParquet(path, parquetDir)
+				}
 				return nil
 			}
 			if strings.Contains(path, "stats.jsonl") {
-				printStatSummary(path)
+				printStatSummary(path, format)
+				return nil
+			}
+			if strings.HasSuffix(path, ".pcap") {
+				printPcapSummary(path)
 				return nil
 			}
 
@@ -71,48 +172,425 @@ func main() {
 		}),
 	)
 
+	if m, err := results.ReadManifest(benchResDir); err == nil {
+		printCrossCheck(benchResDir, m, format)
+		printThroughputSummary(benchResDir, m, format)
+		printChaosSummary(benchResDir, m, format)
+	}
+	if githubReport {
+		osutil.ExitOnErr(writeGitHubReport(benchResDir, baselineDir, regressionThreshold, format))
+	}
 }
 
-func printLogSummary(path string) {
+func printLogSummary(path string, where whereExpr, format reportFormat, anomalyThreshold float64) {
 	fmt.Printf("Summarizing result logs from file: %s\n", path)
 	f, err := os.Open(path)
 	osutil.ExitOnErr(err)
 	defer f.Close()
 
+	recs, err := results.ReadRequestRecords(f)
+	osutil.ExitOnErr(err)
+
+	var matched []results.RequestRecord
 	var reqTimesNano []int64
-	scn := bufio.NewScanner(f)
-	for scn.Scan() {
-		var e logEntry
-		err := json.Unmarshal(scn.Bytes(), &e)
-		osutil.ExitOnErr(err)
+	var timedOut, invalid int
+	for _, r := range recs {
+		if where != nil {
+			ok, err := where.eval(r.Fields())
+			osutil.ExitOnErr(err)
+			if !ok {
+				continue
+			}
+		}
+		matched = append(matched, r)
+		if r.TimedOut {
+			timedOut++
+		}
+		if r.Invalid {
+			invalid++
+		}
+		if r.MaxTimeNano == 0 {
+			continue
+		}
+		reqTimesNano = append(reqTimesNano, r.MaxTimeNano)
+	}
+	// Clients aggregating the request times only log a summary of them.
+	if len(reqTimesNano) > 0 {
+		min, max, mean, median := summarizeStats(reqTimesNano)
+		fmt.Printf(
+			"Request Time:\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+			format.duration(min),
+			format.duration(max),
+			format.duration(mean),
+			format.duration(median),
+		)
+	}
+	if timedOut > 0 {
+		fmt.Printf("Timed Out Requests: %d of %d (%s)\n\n", timedOut, len(matched),
+			format.percent(100*float64(timedOut)/float64(len(matched))))
+	}
+	if invalid > 0 {
+		fmt.Printf("Invalid Responses: %d of %d (%s)\n\n", invalid, len(matched),
+			format.percent(100*float64(invalid)/float64(len(matched))))
+	}
+	printTargetSummary(matched, format)
+	printWorkersSummary(matched, format)
+	printPhaseSummary(matched, format)
+	printResumptionSummary(matched, format)
+	printRetrySummary(matched, format)
+	printCorrectedSummary(matched, format)
+	printCompressionSummary(matched, format)
+	printConnWaitSummary(matched, format)
+
+	if anomalyThreshold > 0 {
+		printAnomalies(detectAnomalies(bucketBySecond(matched), anomalyThreshold), format)
+	}
+}
+
+// printTargetSummary summarizes the time of the completed requests
+// of each target, if the client picked them among several targets.
+func printTargetSummary(recs []results.RequestRecord, format reportFormat) {
+	timesNano := make(map[string][]int64)
+	for _, r := range recs {
+		if r.Target != "" && r.MaxTimeNano > 0 {
+			timesNano[r.Target] = append(timesNano[r.Target], r.MaxTimeNano)
+		}
+	}
+	if len(timesNano) == 0 {
+		return
+	}
+	for _, target := range slices.Sorted(maps.Keys(timesNano)) {
+		min, max, mean, median := summarizeStats(timesNano[target])
+		fmt.Printf(
+			"Request Time of %s (%d requests):\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+			target, len(timesNano[target]),
+			format.duration(min),
+			format.duration(max),
+			format.duration(mean),
+			format.duration(median),
+		)
+	}
+}
+
+// printCorrectedSummary summarizes the time of the completed requests corrected for coordinated
+// omission, from when they were scheduled to start, if the client sent them in an open loop.
+func printCorrectedSummary(recs []results.RequestRecord, format reportFormat) {
+	var delays bool
+	var timesNano []int64
+	for _, r := range recs {
+		if r.MaxTimeNano > 0 {
+			delays = delays || r.StartDelayNano > 0
+			timesNano = append(timesNano, r.MaxTimeNano+r.StartDelayNano)
+		}
+	}
+	if !delays {
+		return
+	}
+	min, max, mean, median := summarizeStats(timesNano)
+	fmt.Printf(
+		"Corrected Request Time (%d requests):\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+		len(timesNano),
+		format.duration(min),
+		format.duration(max),
+		format.duration(mean),
+		format.duration(median),
+	)
+}
+
+// printCompressionSummary summarizes the bytes read of the completed requests whose
+// responses the client decompressed, and the bytes they were decompressed into.
+func printCompressionSummary(recs []results.RequestRecord, format reportFormat) {
+	var n int
+	var read, decoded int64
+	for _, r := range recs {
+		if r.BytesDecoded > 0 {
+			n++
+			read += r.BytesRead
+			decoded += r.BytesDecoded
+		}
+	}
+	if n == 0 {
+		return
+	}
+	fmt.Printf("Compressed Responses (%d requests):\n- Bytes Read: %d\n- Bytes Decoded: %d\n- Compressed Size: %s of decoded\n\n",
+		n, read, decoded, format.percent(100*float64(read)/float64(decoded)))
+}
 
-		if e.MaxTimeNano == 0 {
+// printWorkersSummary summarizes the time of the completed requests at each
+// amount of workers of the client, if it ramped them up.
+func printWorkersSummary(recs []results.RequestRecord, format reportFormat) {
+	timesNano := make(map[int32][]int64)
+	for _, r := range recs {
+		if r.Workers > 0 && r.MaxTimeNano > 0 {
+			timesNano[r.Workers] = append(timesNano[r.Workers], r.MaxTimeNano)
+		}
+	}
+	if len(timesNano) == 0 {
+		return
+	}
+	for _, workers := range slices.Sorted(maps.Keys(timesNano)) {
+		min, max, mean, median := summarizeStats(timesNano[workers])
+		fmt.Printf(
+			"Request Time at %d Workers (%d requests):\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+			workers, len(timesNano[workers]),
+			format.duration(min),
+			format.duration(max),
+			format.duration(mean),
+			format.duration(median),
+		)
+	}
+}
+
+// printPhaseSummary summarizes the time of the phases of the completed requests, if they
+// were logged. DNS lookups, connections and TLS handshakes are only summarized among
+// the requests that went through them, on new connections.
+func printPhaseSummary(recs []results.RequestRecord, format reportFormat) {
+	phases := []struct {
+		name  string
+		nano  func(results.RequestRecord) int64
+		times []int64
+	}{
+		{name: "DNS Lookup", nano: func(r results.RequestRecord) int64 { return r.DNSNano }},
+		{name: "Connect", nano: func(r results.RequestRecord) int64 { return r.ConnectNano }},
+		{name: "TLS Handshake", nano: func(r results.RequestRecord) int64 { return r.TLSNano }},
+		{name: "Time to First Byte", nano: func(r results.RequestRecord) int64 { return r.TTFBNano }},
+	}
+	for _, r := range recs {
+		for i := range phases {
+			if v := phases[i].nano(r); v > 0 {
+				phases[i].times = append(phases[i].times, v)
+			}
+		}
+	}
+	for _, p := range phases {
+		if len(p.times) == 0 {
+			continue
+		}
+		min, max, mean, median := summarizeStats(p.times)
+		fmt.Printf(
+			"%s (%d requests):\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+			p.name, len(p.times),
+			format.duration(min),
+			format.duration(max),
+			format.duration(mean),
+			format.duration(median),
+		)
+	}
+}
+
+// printResumptionSummary summarizes, if any TLS session was resumed, the time of the full
+// TLS handshakes of the new connections apart from the time of those resuming a session.
+func printResumptionSummary(recs []results.RequestRecord, format reportFormat) {
+	var fullTimesNano, resumedTimesNano []int64
+	for _, r := range recs {
+		switch {
+		case r.TLSNano == 0:
+		case r.TLSResumed:
+			resumedTimesNano = append(resumedTimesNano, r.TLSNano)
+		default:
+			fullTimesNano = append(fullTimesNano, r.TLSNano)
+		}
+	}
+	if len(resumedTimesNano) == 0 {
+		return
+	}
+	for _, g := range []struct {
+		name  string
+		times []int64
+	}{
+		{"Full TLS Handshake", fullTimesNano},
+		{"Resumed TLS Handshake", resumedTimesNano},
+	} {
+		if len(g.times) == 0 {
+			continue
+		}
+		min, max, mean, median := summarizeStats(g.times)
+		fmt.Printf(
+			"%s (%d requests):\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+			g.name, len(g.times),
+			format.duration(min),
+			format.duration(max),
+			format.duration(mean),
+			format.duration(median),
+		)
+	}
+}
+
+// printRetrySummary summarizes, if any request was retried, the time of the
+// completed requests sent once apart from the time of those retried.
+func printRetrySummary(recs []results.RequestRecord, format reportFormat) {
+	var firstTimesNano, retriedTimesNano []int64
+	var retried, retries int
+	for _, r := range recs {
+		if r.Retries > 0 {
+			retried++
+			retries += int(r.Retries)
+		}
+		switch {
+		case r.MaxTimeNano == 0:
+		case r.Retries > 0:
+			retriedTimesNano = append(retriedTimesNano, r.MaxTimeNano)
+		default:
+			firstTimesNano = append(firstTimesNano, r.MaxTimeNano)
+		}
+	}
+	if retried == 0 {
+		return
+	}
+
+	fmt.Printf("Retried Requests: %d of %d (%s), %d retries\n\n", retried, len(recs),
+		format.percent(100*float64(retried)/float64(len(recs))), retries)
+	for _, s := range []struct {
+		name  string
+		times []int64
+	}{
+		{"First-Attempt Request Time", firstTimesNano},
+		{"Retried Request Time", retriedTimesNano},
+	} {
+		if len(s.times) == 0 {
 			continue
 		}
-		reqTimesNano = append(reqTimesNano, e.MaxTimeNano)
+		min, max, mean, median := summarizeStats(s.times)
+		fmt.Printf(
+			"%s (%d requests):\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+			s.name, len(s.times),
+			format.duration(min),
+			format.duration(max),
+			format.duration(mean),
+			format.duration(median),
+		)
+	}
+}
+
+// printConnectSummary summarizes the setup time of the connections
+// logged by WebSocket clients, if the log file has any, the request
+// rate achieved by clients pacing them at a target rate, and the
+// throughput of clients sending their requests for a duration, the request
+// times of clients aggregating them, and the requests of interrupted clients.
+func printConnectSummary(path string, format reportFormat) {
+	var connectTimesNano []int64
+	var rate, latency, interrupted *logEntry
+	for e, err := range decodeLines[logEntry](path) {
+		osutil.ExitOnErr(err)
+		switch e.Msg {
+		case "ws connected":
+			connectTimesNano = append(connectTimesNano, e.ConnectTimeNano)
+		case "rate summary", "duration summary":
+			rate = &e
+		case "latency summary":
+			latency = &e
+		case "interrupted summary":
+			interrupted = &e
+		}
+	}
+	if interrupted != nil {
+		fmt.Printf("Interrupted Run:\n- Requests: %d sent, %d completed\n- Elapsed: %s\n\n",
+			interrupted.Requests, interrupted.Completed, format.duration(interrupted.ElapsedNano))
+	}
+	if latency != nil {
+		fmt.Printf(
+			"Request Time (aggregated, %d of %d requests completed):\n- Min: %s\n- Max: %s\n- Mean: %s\n- P50: %s\n- P90: %s\n- P99: %s\n- P99.9: %s\n- P99.99: %s\n\n",
+			latency.Completed, latency.Requests,
+			format.duration(latency.MinNano),
+			format.duration(latency.MaxNano),
+			format.duration(latency.MeanNano),
+			format.duration(latency.P50Nano),
+			format.duration(latency.P90Nano),
+			format.duration(latency.P99Nano),
+			format.duration(latency.P999Nano),
+			format.duration(latency.P9999Nano),
+		)
+	}
+	if rate != nil && rate.Msg == "duration summary" {
+		fmt.Printf("Throughput:\n- Duration: %s\n- Requests: %d sent, %d completed\n- Elapsed: %s\n- Rate: %s/s\n",
+			format.duration(rate.DurationNano), rate.Requests, rate.Completed,
+			format.duration(rate.ElapsedNano),
+			strconv.FormatFloat(rate.AchievedRPS, 'f', format.decimals, 64))
+		if rate.TargetRPS > 0 {
+			fmt.Printf("- Target: %s/s (%s achieved)\n",
+				strconv.FormatFloat(rate.TargetRPS, 'f', format.decimals, 64),
+				format.percent(100*rate.AchievedRPS/rate.TargetRPS))
+		}
+		fmt.Println()
+	} else if rate != nil {
+		fmt.Printf("Request Rate:\n- Target: %s/s\n- Achieved: %s/s (%s of target)\n\n",
+			strconv.FormatFloat(rate.TargetRPS, 'f', format.decimals, 64),
+			strconv.FormatFloat(rate.AchievedRPS, 'f', format.decimals, 64),
+			format.percent(100*rate.AchievedRPS/rate.TargetRPS))
+	}
+	if len(connectTimesNano) == 0 {
+		return
 	}
-	osutil.ExitOnErr(scn.Err())
-	min, max, mean, median := summarizeStats(reqTimesNano)
+
+	min, max, mean, median := summarizeStats(connectTimesNano)
 	fmt.Printf(
-		"Request Time:\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
-		time.Duration(min),
-		time.Duration(max),
-		time.Duration(mean),
-		time.Duration(median),
+		"Connection Setup (%d connections):\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+		len(connectTimesNano),
+		format.duration(min),
+		format.duration(max),
+		format.duration(mean),
+		format.duration(median),
 	)
 }
 
-func printStatSummary(path string) {
-	fmt.Printf("Summarizing result stats from file: %s\n", path)
-	f, err := os.Open(path)
+// printProxySummary summarizes the time the upstream of a proxy took to respond,
+// the time the proxy took to forward the whole request and how often it reused
+// its upstream connections.
+func printProxySummary(path string, format reportFormat) {
+	fmt.Printf("Summarizing proxy logs from file: %s\n", path)
+	var upstreamTimesNano, proxyTimesNano []int64
+	reused := 0
+	for e, err := range decodeLines[logEntry](path) {
+		osutil.ExitOnErr(err)
+		if e.Msg != "req proxied" {
+			continue
+		}
+		upstreamTimesNano = append(upstreamTimesNano, e.UpstreamTimeNano)
+		proxyTimesNano = append(proxyTimesNano, e.ProxyTimeNano)
+		if e.UpstreamReused {
+			reused++
+		}
+	}
+
+	for _, s := range []struct {
+		label string
+		times []int64
+	}{
+		{"Upstream Time To First Byte", upstreamTimesNano},
+		{"Proxy Time", proxyTimesNano},
+	} {
+		min, max, mean, median := summarizeStats(s.times)
+		fmt.Printf(
+			"%s:\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+			s.label,
+			format.duration(min),
+			format.duration(max),
+			format.duration(mean),
+			format.duration(median),
+		)
+	}
+	fmt.Printf("Upstream Connections:\n- Reused: %d of %d requests\n\n", reused, len(upstreamTimesNano))
+}
+
+func exportParquet(path, dir string) {
+	osutil.ExitOnErr(os.MkdirAll(dir, os.ModePerm))
+	dest := filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), ".jsonl")+".parquet")
+	fmt.Printf("Exporting request records from file %s to %s\n\n", path, dest)
+
+	in, err := os.Open(path)
+	osutil.ExitOnErr(err)
+	defer in.Close()
+	out, err := os.Create(dest)
 	osutil.ExitOnErr(err)
-	defer f.Close()
 
+	err = results.ExportParquet(in, out)
+	osutil.ExitOnErr(errors.Join(err, out.Close()))
+}
+
+func printStatSummary(path string, format reportFormat) {
+	fmt.Printf("Summarizing result stats from file: %s\n", path)
 	var cpuRecordings []float64
-	scn := bufio.NewScanner(f)
-	for scn.Scan() {
-		var e statEntry
-		err := json.Unmarshal(scn.Bytes(), &e)
+	for e, err := range decodeLines[statEntry](path) {
 		osutil.ExitOnErr(err)
 		cpuDelta := e.CPUStats.CPUUsage.TotalUsage - e.PrecpuStats.CPUUsage.TotalUsage
 		sysCpuDelta := e.CPUStats.SystemCPUUsage - e.PrecpuStats.SystemCPUUsage
@@ -125,17 +603,45 @@ func printStatSummary(path string) {
 		cpuUsage := (float64(cpuDelta) / float64(sysCpuDelta)) * float64(numCpu) * 100
 		cpuRecordings = append(cpuRecordings, cpuUsage)
 	}
-	osutil.ExitOnErr(scn.Err())
 	min, max, mean, median := summarizeStats(cpuRecordings)
 	fmt.Printf(
-		"CPU Usage:\n- Min: %.2f%%\n- Max: %.2f%%\n- Mean: %.2f%%\n- Median: %.2f%%\n\n",
-		min,
-		max,
-		mean,
-		median,
+		"CPU Usage:\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+		format.percent(min),
+		format.percent(max),
+		format.percent(mean),
+		format.percent(median),
 	)
 }
 
+// decodeLines returns an iterator over the JSON lines of the file at path decoded
+// into entries of type T. Invalid lines are skipped, as the validation pass already
+// reports them. If the file can not be opened or read, the error is yielded last.
+func decodeLines[T any](path string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		f, err := os.Open(path)
+		if err != nil {
+			yield(zero, fmt.Errorf("error to open file %s: %w", path, err))
+			return
+		}
+		defer f.Close()
+
+		scn := bufio.NewScanner(f)
+		for scn.Scan() {
+			var e T
+			if err := json.Unmarshal(scn.Bytes(), &e); err != nil {
+				continue
+			}
+			if !yield(e, nil) {
+				return
+			}
+		}
+		if err := scn.Err(); err != nil {
+			yield(zero, fmt.Errorf("error to read file %s: %w", path, err))
+		}
+	}
+}
+
 type number interface {
 	~int | ~int8 | ~int16 | ~int32 | ~int64 |
 		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
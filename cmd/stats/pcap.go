@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Link types of the captures written by tcpdump.
+const (
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	// linkTypeLinuxSLL2 is written by recent tcpdump releases capturing on the any interface.
+	linkTypeLinuxSLL2 = 276
+)
+
+// tcpTally counts the TCP segments of a capture by the flags relevant to connection reuse.
+type tcpTally struct {
+	segments, syn, fin, rst int
+}
+
+// printPcapSummary summarizes the TCP segments captured into the pcap file at path.
+//
+// The SYN segments without ACK are the connections opened, which
+// are few when connections are reused, and RST segments are the
+// connections aborted, e.g. closed with unread response bodies.
+func printPcapSummary(path string) {
+	fmt.Printf("Summarizing packet capture from file: %s\n", path)
+	t, err := tallyPcap(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("unable to summarize packet capture: %w", err))
+		fmt.Println()
+		return
+	}
+	fmt.Printf(
+		"TCP Segments:\n- Total: %d\n- Connections Opened (SYN): %d\n- Connections Closed (FIN): %d\n- Connections Reset (RST): %d\n\n",
+		t.segments, t.syn, t.fin, t.rst,
+	)
+}
+
+// tallyPcap counts the TCP segments of the pcap file at path.
+func tallyPcap(path string) (tcpTally, error) {
+	var t tcpTally
+	f, err := os.Open(path)
+	if err != nil {
+		return t, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	hdr := make([]byte, 24)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return t, fmt.Errorf("error to read pcap header: %w", err)
+	}
+	var order binary.ByteOrder
+	switch binary.LittleEndian.Uint32(hdr) {
+	case 0xa1b2c3d4, 0xa1b23c4d:
+		order = binary.LittleEndian
+	case 0xd4c3b2a1, 0x4d3cb2a1:
+		order = binary.BigEndian
+	default:
+		return t, errors.New("not a pcap file, pcapng is not supported")
+	}
+	linkType := order.Uint32(hdr[20:]) & 0xffff
+
+	rec := make([]byte, 16)
+	for {
+		// A capture stopped abruptly may end with a partial record, which is ignored.
+		if _, err := io.ReadFull(r, rec); err != nil {
+			return t, nil
+		}
+		pkt := make([]byte, order.Uint32(rec[8:]))
+		if _, err := io.ReadFull(r, pkt); err != nil {
+			return t, nil
+		}
+		if flags, ok := tcpFlags(linkType, pkt); ok {
+			t.segments++
+			if flags&0x02 != 0 && flags&0x10 == 0 {
+				t.syn++
+			}
+			if flags&0x01 != 0 {
+				t.fin++
+			}
+			if flags&0x04 != 0 {
+				t.rst++
+			}
+		}
+	}
+}
+
+// tcpFlags returns the flags of the TCP segment in the packet pkt
+// of the link type, or false if it does not hold a TCP segment.
+func tcpFlags(linkType uint32, pkt []byte) (byte, bool) {
+	var proto uint16
+	switch linkType {
+	case linkTypeEthernet:
+		if len(pkt) < 14 {
+			return 0, false
+		}
+		proto, pkt = binary.BigEndian.Uint16(pkt[12:]), pkt[14:]
+	case linkTypeLinuxSLL:
+		if len(pkt) < 16 {
+			return 0, false
+		}
+		proto, pkt = binary.BigEndian.Uint16(pkt[14:]), pkt[16:]
+	case linkTypeLinuxSLL2:
+		if len(pkt) < 20 {
+			return 0, false
+		}
+		proto, pkt = binary.BigEndian.Uint16(pkt), pkt[20:]
+	case linkTypeRaw:
+		if len(pkt) < 1 {
+			return 0, false
+		}
+		proto = 0x0800
+		if pkt[0]>>4 == 6 {
+			proto = 0x86dd
+		}
+	default:
+		return 0, false
+	}
+
+	var tcp []byte
+	switch proto {
+	case 0x0800:
+		if len(pkt) < 20 || pkt[9] != 6 {
+			return 0, false
+		}
+		ihl := int(pkt[0]&0x0f) * 4
+		if len(pkt) < ihl {
+			return 0, false
+		}
+		tcp = pkt[ihl:]
+	case 0x86dd:
+		// Extension headers are not followed, they are not used by the benchmark.
+		if len(pkt) < 40 || pkt[6] != 6 {
+			return 0, false
+		}
+		tcp = pkt[40:]
+	default:
+		return 0, false
+	}
+	if len(tcp) < 14 {
+		return 0, false
+	}
+	return tcp[13], true
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+)
+
+// perfEntry is a line of the JSON output of bpftrace.
+type perfEntry struct {
+	Type string                     `json:"type"`
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+// perfBucket is a bucket of a bpftrace histogram, Max is
+// not set for the last bucket, which has no upper bound.
+type perfBucket struct {
+	Min   int64  `json:"min"`
+	Max   *int64 `json:"max"`
+	Count int64  `json:"count"`
+}
+
+// printPerfSummary summarizes the syscall counts, context switches and
+// run-queue latency recorded by bpftrace into the file at path.
+func printPerfSummary(path string, format reportFormat) {
+	fmt.Printf("Summarizing syscall and scheduling stats from file: %s\n", path)
+	syscalls := make(map[string]int64)
+	var contextSwitches int64
+	runqueue := make(map[int64]perfBucket)
+	for e, err := range decodeLines[perfEntry](path) {
+		osutil.ExitOnErr(err)
+		for name, raw := range e.Data {
+			switch name {
+			case "@syscalls":
+				var counts map[string]int64
+				if json.Unmarshal(raw, &counts) == nil {
+					for probe, n := range counts {
+						syscalls[strings.TrimPrefix(probe, "tracepoint:syscalls:sys_enter_")] += n
+					}
+				}
+			case "@context_switches":
+				var n int64
+				if json.Unmarshal(raw, &n) == nil {
+					contextSwitches += n
+				}
+			case "@runqueue_latency_us":
+				var buckets []perfBucket
+				if json.Unmarshal(raw, &buckets) == nil {
+					for _, b := range buckets {
+						merged := runqueue[b.Min]
+						merged.Min, merged.Max = b.Min, b.Max
+						merged.Count += b.Count
+						runqueue[b.Min] = merged
+					}
+				}
+			}
+		}
+	}
+
+	var total int64
+	for _, n := range syscalls {
+		total += n
+	}
+	names := slices.SortedFunc(maps.Keys(syscalls), func(a, b string) int {
+		return cmp.Compare(syscalls[b], syscalls[a])
+	})
+	fmt.Printf("Syscalls:\n- Total: %d\n", total)
+	for _, name := range names[:min(len(names), 10)] {
+		fmt.Printf("- %s: %d\n", name, syscalls[name])
+	}
+	fmt.Printf("Context Switches: %d\n", contextSwitches)
+
+	buckets := slices.SortedFunc(maps.Values(runqueue), func(a, b perfBucket) int {
+		return cmp.Compare(a.Min, b.Min)
+	})
+	fmt.Printf(
+		"Run-Queue Latency:\n- P50: %s\n- P99: %s\n\n",
+		format.duration(histQuantile(buckets, 0.5)),
+		format.duration(histQuantile(buckets, 0.99)),
+	)
+}
+
+// histQuantile returns the upper bound of the bucket of the histogram,
+// in microseconds, holding the quantile q, as a number of nanoseconds.
+//
+// The lower bound is used for the last bucket, which has no upper bound.
+func histQuantile(buckets []perfBucket, q float64) int64 {
+	var total int64
+	for _, b := range buckets {
+		total += b.Count
+	}
+	var seen int64
+	for _, b := range buckets {
+		seen += b.Count
+		if float64(seen) >= q*float64(total) && total > 0 {
+			bound := b.Min
+			if b.Max != nil {
+				bound = *b.Max
+			}
+			return int64(time.Duration(bound) * time.Microsecond)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// printConnWaitSummary breaks the time of the completed requests down into the time they
+// waited for a connection and the rest, on the wire, so requests blocked by the limits
+// of the connection pool of the client, or by the stream limits of HTTP/2 servers, can
+// be told apart from slow responses.
+//
+// Only requests logging their connection events are broken down,
+// so nothing is printed for the clients of other protocols.
+func printConnWaitSummary(recs []results.RequestRecord, format reportFormat) {
+	var waitTimesNano, wireTimesNano []int64
+	var waitSum, reqSum int64
+	for _, r := range recs {
+		if r.MaxTimeNano == 0 || r.ConnWaitNano == 0 {
+			continue
+		}
+		waitTimesNano = append(waitTimesNano, r.ConnWaitNano)
+		wireTimesNano = append(wireTimesNano, r.MaxTimeNano-r.ConnWaitNano)
+		waitSum += r.ConnWaitNano
+		reqSum += r.MaxTimeNano
+	}
+	if len(waitTimesNano) == 0 {
+		return
+	}
+
+	min, max, mean, median := summarizeStats(waitTimesNano)
+	fmt.Printf(
+		"Connection Wait:\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n- Share of Request Time: %s\n\n",
+		format.duration(min),
+		format.duration(max),
+		format.duration(mean),
+		format.duration(median),
+		format.percent(float64(waitSum)/float64(reqSum)*100),
+	)
+	min, max, mean, median = summarizeStats(wireTimesNano)
+	fmt.Printf(
+		"On-Wire Time:\n- Min: %s\n- Max: %s\n- Mean: %s\n- Median: %s\n\n",
+		format.duration(min),
+		format.duration(max),
+		format.duration(mean),
+		format.duration(median),
+	)
+}
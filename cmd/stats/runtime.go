@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/runtimemetrics"
+)
+
+// printRuntimeSummary summarizes the Go runtime metrics sampled into the file
+// at path, so the time spent on GC can be told apart from the time on the network.
+func printRuntimeSummary(path string, format reportFormat) {
+	fmt.Printf("Summarizing Go runtime metrics from file: %s\n", path)
+	printRuntimeSamples(readRuntimeSamples(path), format)
+}
+
+// readRuntimeSamples reads the Go runtime metrics sampled into the file at path,
+// either sampled by the benchmark or logged by a client among its requests.
+func readRuntimeSamples(path string) []runtimemetrics.Sample {
+	var samples []runtimemetrics.Sample
+	for s, err := range decodeLines[runtimeSampleEntry](path) {
+		osutil.ExitOnErr(err)
+		// The samples of the benchmark have no message.
+		if s.Msg != "" && s.Msg != runtimemetrics.LogMsg {
+			continue
+		}
+		samples = append(samples, s.Sample)
+	}
+	return samples
+}
+
+// runtimeSampleEntry is a Go runtime metrics sample, as logged by a client or sampled by the benchmark.
+type runtimeSampleEntry struct {
+	Msg string `json:"msg"`
+	runtimemetrics.Sample
+}
+
+// printRuntimeSamples summarizes the Go runtime metrics samples, in the order they were sampled.
+func printRuntimeSamples(samples []runtimemetrics.Sample, format reportFormat) {
+	var peakHeap, peakGoroutines uint64
+	for _, s := range samples {
+		peakHeap = max(peakHeap, s.HeapObjectsBytes)
+		peakGoroutines = max(peakGoroutines, s.Goroutines)
+	}
+	n := len(samples)
+	if n < 2 {
+		fmt.Printf("Not enough samples to summarize, %d found\n\n", n)
+		return
+	}
+
+	// Cumulative metrics are compared between the first and last samples.
+	first, last := samples[0], samples[n-1]
+	window := last.Time.Sub(first.Time)
+	pauseNano := last.GCPauseTotalNano - first.GCPauseTotalNano
+	var pauseShare float64
+	if window > 0 {
+		pauseShare = float64(pauseNano) / float64(window.Nanoseconds()) * 100
+	}
+	fmt.Printf(
+		"Go Runtime (%d samples over %s):\n- GC Cycles: %d\n- GC Pauses: %d\n- GC Pause Total: %s (%s of the time)\n- GC Pause Max: %s\n- Allocated: %s\n- Peak Heap: %s\n- Peak Goroutines: %d\n\n",
+		n,
+		format.duration(window.Nanoseconds()),
+		last.GCCycles-first.GCCycles,
+		last.GCPauses-first.GCPauses,
+		format.duration(pauseNano),
+		format.percent(pauseShare),
+		format.duration(last.GCPauseMaxNano),
+		format.bytes(int64(last.HeapAllocsBytes-first.HeapAllocsBytes)),
+		format.bytes(int64(peakHeap)),
+		peakGoroutines,
+	)
+}
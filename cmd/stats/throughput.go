@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// gib is the amount of bytes the CPU time of the containers is reported per.
+const gib = 1 << 30
+
+// printThroughputSummary summarizes the throughput of the clients reading their
+// response bodies or sending request bodies: how many bytes they transferred per
+// second over the whole run, and within each request, and how much CPU time they,
+// and the servers they send their requests to, spent per GiB transferred.
+//
+// Clients neither reading nor sending bodies are skipped.
+func printThroughputSummary(dir string, m results.Manifest, format reportFormat) {
+	for _, cli := range m.Containers {
+		if cli.Role != results.RoleClient || cli.LogFile == "" {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, cli.LogFile))
+		osutil.ExitOnErr(err)
+		recs, err := results.ReadRequestRecords(f)
+		f.Close()
+		osutil.ExitOnErr(err)
+
+		var bytesRead, bytesSent int64
+		var first, last time.Time
+		var ratesPerSec []float64
+		for _, r := range recs {
+			if r.MaxTimeNano == 0 || r.BytesRead+r.BytesSent == 0 {
+				continue
+			}
+			bytesRead += r.BytesRead
+			bytesSent += r.BytesSent
+			start := r.Time.Add(-time.Duration(r.MaxTimeNano))
+			if first.IsZero() || start.Before(first) {
+				first = start
+			}
+			if r.Time.After(last) {
+				last = r.Time
+			}
+			ratesPerSec = append(ratesPerSec, float64(r.BytesRead+r.BytesSent)/time.Duration(r.MaxTimeNano).Seconds())
+		}
+		transferred := bytesRead + bytesSent
+		if transferred == 0 {
+			continue
+		}
+
+		fmt.Printf("Summarizing throughput of %s container\n", cli.Name)
+		fmt.Printf(
+			"Bytes Read: %s\nBytes Sent: %s\nSustained Throughput: %s/s\n",
+			format.bytes(bytesRead),
+			format.bytes(bytesSent),
+			format.bytes(int64(float64(transferred)/last.Sub(first).Seconds())),
+		)
+		min, max, mean, median := summarizeStats(ratesPerSec)
+		fmt.Printf(
+			"Request Throughput:\n- Min: %s/s\n- Max: %s/s\n- Mean: %s/s\n- Median: %s/s\n",
+			format.bytes(int64(min)),
+			format.bytes(int64(max)),
+			format.bytes(int64(mean)),
+			format.bytes(int64(median)),
+		)
+		fmt.Println("CPU Time per GiB:")
+		printCPUPerGiB(dir, cli, transferred, format)
+		if i := slices.IndexFunc(m.Containers, func(c results.ManifestContainer) bool {
+			return c.Name == cli.Target && c.LogFile != ""
+		}); i >= 0 {
+			srv := m.Containers[i]
+			served, err := tallyLogFile(filepath.Join(dir, srv.LogFile))
+			osutil.ExitOnErr(err)
+			printCPUPerGiB(dir, srv, served.bytes+served.received, format)
+		}
+		fmt.Println()
+	}
+}
+
+// printCPUPerGiB prints the CPU time the container c spent per GiB of the n bytes it transferred.
+func printCPUPerGiB(dir string, c results.ManifestContainer, n int64, format reportFormat) {
+	if c.StatFile == "" || n == 0 {
+		return
+	}
+	cpu, err := cpuTimeNano(filepath.Join(dir, c.StatFile))
+	osutil.ExitOnErr(err)
+	fmt.Printf("- %s: %s\n", c.Name, format.duration(int64(float64(cpu)/float64(n)*gib)))
+}
+
+// cpuTimeNano returns the CPU time the container spent while its stats, at path, were streamed.
+func cpuTimeNano(path string) (int64, error) {
+	var first, last int64
+	for e, err := range decodeLines[statEntry](path) {
+		if err != nil {
+			return 0, err
+		}
+		if e.CPUStats.CPUUsage.TotalUsage == 0 {
+			continue
+		}
+		if first == 0 {
+			first = e.CPUStats.CPUUsage.TotalUsage
+		}
+		last = e.CPUStats.CPUUsage.TotalUsage
+	}
+	return last - first, nil
+}
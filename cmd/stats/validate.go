@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pessolato/httpmicrobench/pkg/results"
+)
+
+// validateRun checks the integrity of the benchmark results in dir and
+// returns a human readable description of each problem found.
+//
+// It reports expected files that are missing, lines that are not valid
+// JSON (usually truncated writes), client logs without any completed
+// request and any mismatch against the run manifest.
+func validateRun(dir string) []string {
+	var issues []string
+
+	m, err := results.ReadManifest(dir)
+	hasManifest := err == nil
+	if !hasManifest {
+		issues = append(issues, fmt.Sprintf("unable to validate against run manifest: %s", err))
+	}
+
+	expected := make(map[string]results.ManifestContainer)
+	for _, c := range m.Containers {
+		for _, name := range []string{c.LogFile, c.StatFile, c.PcapFile, c.PerfFile, c.RuntimeFile} {
+			if name == "" {
+				continue
+			}
+			expected[name] = c
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				issues = append(issues, fmt.Sprintf("missing expected file %s for %s container", name, c.Name))
+			}
+		}
+	}
+
+	for _, name := range []string{m.TracesFile, m.ChaosFile} {
+		if name == "" {
+			continue
+		}
+		expected[name] = results.ManifestContainer{}
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			issues = append(issues, fmt.Sprintf("missing expected file %s of the run", name))
+		}
+	}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".jsonl") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if hasManifest && rel == m.TracesFile {
+			// Spans are exported in batches, whose lines are too long to be scanned.
+			return nil
+		}
+		c, ok := expected[rel]
+		if hasManifest && !ok {
+			issues = append(issues, fmt.Sprintf("file %s is not listed in the run manifest", rel))
+		}
+
+		invalid, firstInvalid, completions, err := scanResultFile(path)
+		if err != nil {
+			return err
+		}
+		if invalid > 0 {
+			issues = append(issues, fmt.Sprintf("file %s has %d invalid JSON lines, first at line %d", rel, invalid, firstInvalid))
+		}
+		if !strings.HasSuffix(path, "logs.jsonl") || isServerFile(rel, c) {
+			// Servers and proxies only log the requests they serve and are checked by the cross-check.
+			return nil
+		}
+		if completions == 0 {
+			issues = append(issues, fmt.Sprintf("file %s has zero request completions", rel))
+		} else if ok && c.Role == results.RoleClient && c.DurationNano == 0 {
+			want := m.NumberOfRequests
+			if c.NumberOfRequests != 0 {
+				want = c.NumberOfRequests
+			}
+			if completions != want {
+				issues = append(issues, fmt.Sprintf("file %s has %d request completions, manifest expects %d", rel, completions, want))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		issues = append(issues, fmt.Sprintf("unable to walk results directory: %s", err))
+	}
+
+	return issues
+}
+
+// scanResultFile counts the invalid JSON lines and the request completions in a result file.
+func scanResultFile(path string) (invalid, firstInvalid, completions int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("error to open file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scn := bufio.NewScanner(f)
+	for line := 1; scn.Scan(); line++ {
+		var e logEntry
+		if err := json.Unmarshal(scn.Bytes(), &e); err != nil {
+			if invalid == 0 {
+				firstInvalid = line
+			}
+			invalid++
+			continue
+		}
+		switch {
+		case e.MaxTimeNano != 0:
+			completions++
+		case e.Msg == "latency summary":
+			// Clients aggregating the request times only log how many completed.
+			completions += int(e.Completed)
+		}
+	}
+	if err := scn.Err(); err != nil {
+		return 0, 0, 0, fmt.Errorf("error to read file %s: %w", path, err)
+	}
+	return invalid, firstInvalid, completions, nil
+}
+
+// isServerFile reports whether the result file rel belongs to a server, or proxy, container.
+//
+// The role recorded in the manifest takes precedence over the file name.
+func isServerFile(rel string, c results.ManifestContainer) bool {
+	if c.Role != "" {
+		return c.Role == results.RoleServer || c.Role == results.RoleProxy
+	}
+	return strings.HasPrefix(filepath.Base(rel), results.RoleServer+"-") || isProxyFile(rel)
+}
+
+// isProxyFile reports whether the result file rel belongs to a proxy container, by its name.
+func isProxyFile(rel string) bool {
+	return strings.HasPrefix(filepath.Base(rel), results.RoleProxy+"-")
+}
+
+// printIssues writes the integrity issues to stderr in a way that stands out from the summaries.
+func printIssues(issues []string) {
+	fmt.Fprintf(os.Stderr, "!!! Found %d run integrity issues !!!\n", len(issues))
+	for _, i := range issues {
+		fmt.Fprintf(os.Stderr, "- %s\n", i)
+	}
+	fmt.Fprintln(os.Stderr)
+}
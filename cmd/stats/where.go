@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// whereExpr is a parsed filter expression over the fields of a request record.
+//
+// The supported grammar is:
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison | field
+//	comparison = field ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) literal
+//	literal    = number | "quoted string" | true | false
+//
+// A bare field is true when its value is the boolean true.
+type whereExpr interface {
+	eval(fields map[string]any) (bool, error)
+}
+
+type orExpr struct{ l, r whereExpr }
+type andExpr struct{ l, r whereExpr }
+type notExpr struct{ e whereExpr }
+type fieldExpr struct{ name string }
+type cmpExpr struct {
+	field, op string
+	value     any
+}
+
+func (e orExpr) eval(fields map[string]any) (bool, error) {
+	l, err := e.l.eval(fields)
+	if err != nil || l {
+		return l, err
+	}
+	return e.r.eval(fields)
+}
+
+func (e andExpr) eval(fields map[string]any) (bool, error) {
+	l, err := e.l.eval(fields)
+	if err != nil || !l {
+		return false, err
+	}
+	return e.r.eval(fields)
+}
+
+func (e notExpr) eval(fields map[string]any) (bool, error) {
+	v, err := e.e.eval(fields)
+	return !v, err
+}
+
+func (e fieldExpr) eval(fields map[string]any) (bool, error) {
+	v, ok := fields[e.name]
+	if !ok {
+		return false, fmt.Errorf("unknown field %s", e.name)
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("field %s is not a boolean", e.name)
+	}
+	return b, nil
+}
+
+func (e cmpExpr) eval(fields map[string]any) (bool, error) {
+	v, ok := fields[e.field]
+	if !ok {
+		return false, fmt.Errorf("unknown field %s", e.field)
+	}
+
+	var c int
+	switch typed := v.(type) {
+	case bool:
+		b, ok := e.value.(bool)
+		if !ok || (e.op != "==" && e.op != "!=") {
+			return false, fmt.Errorf("field %s only supports == and != against true or false", e.field)
+		}
+		if typed != b {
+			c = 1
+		}
+	case string:
+		s, ok := e.value.(string)
+		if !ok {
+			return false, fmt.Errorf("field %s must be compared to a quoted string", e.field)
+		}
+		c = strings.Compare(typed, s)
+	default:
+		n, ok := e.value.(float64)
+		if !ok {
+			return false, fmt.Errorf("field %s must be compared to a number", e.field)
+		}
+		f, ok := toFloat(v)
+		if !ok {
+			return false, fmt.Errorf("unsupported type %T for field %s", v, e.field)
+		}
+		switch {
+		case f < n:
+			c = -1
+		case f > n:
+			c = 1
+		}
+	}
+
+	switch e.op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch typed := v.(type) {
+	case int:
+		return float64(typed), true
+	case int32:
+		return float64(typed), true
+	case int64:
+		return float64(typed), true
+	case float64:
+		return typed, true
+	}
+	return 0, false
+}
+
+// parseWhere parses the filter expression s.
+func parseWhere(s string) (whereExpr, error) {
+	toks, err := tokenizeWhere(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{toks: toks}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q in where expression", p.toks[p.pos])
+	}
+	return e, nil
+}
+
+type whereParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *whereParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *whereParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *whereParser) parseOr() (whereExpr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *whereParser) parseAnd() (whereExpr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = andExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *whereParser) parseUnary() (whereExpr, error) {
+	switch t := p.next(); {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of where expression")
+	case t == "!":
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	case t == "(":
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in where expression")
+		}
+		return e, nil
+	case isIdent(t):
+		switch op := p.peek(); op {
+		case "==", "!=", "<", "<=", ">", ">=":
+			p.next()
+			v, err := parseLiteral(p.next())
+			if err != nil {
+				return nil, err
+			}
+			return cmpExpr{field: t, op: op, value: v}, nil
+		}
+		return fieldExpr{t}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q in where expression", t)
+	}
+}
+
+func parseLiteral(t string) (any, error) {
+	switch {
+	case t == "true", t == "false":
+		return t == "true", nil
+	case strings.HasPrefix(t, `"`):
+		return strconv.Unquote(t)
+	}
+	n, err := strconv.ParseFloat(t, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid literal %q in where expression", t)
+	}
+	return n, nil
+}
+
+func isIdent(t string) bool {
+	if t == "" || t == "true" || t == "false" {
+		return false
+	}
+	for i, r := range t {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return true
+}
+
+func tokenizeWhere(s string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(s[i:], "&&"), strings.HasPrefix(s[i:], "||"),
+			strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="),
+			strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			toks = append(toks, s[i:i+2])
+			i += 2
+		case strings.ContainsRune("!()<>", rune(c)):
+			toks = append(toks, s[i:i+1])
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string in where expression")
+			}
+			toks = append(toks, s[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t!()<>=&|\"", rune(s[j])) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q in where expression", c)
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	return toks, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/worker"
+)
+
+func main() {
+	host := "127.0.0.1"
+	port := "9090"
+	secret := ""
+	targets := []string{}
+	osutil.ExitOnErr(
+		osutil.Loader{Prefix: osutil.EnvPrefix}.LoadFlags(flag.CommandLine, os.Args[1:],
+			osutil.NewEnvVar("WORKER_HOST", &host, false).
+				WithDescription("address of the interface the worker agent listens at, e.g. 0.0.0.0 for all of them, the loopback one by default"),
+			osutil.NewEnvVar("WORKER_PORT", &port, false).
+				WithDescription("port the worker agent listens at for jobs of the coordinator").
+				WithValidators(osutil.Match(`^[0-9]+$`)),
+			osutil.NewEnvVar("WORKER_SECRET", &secret, true).
+				WithDescription("secret shared with the coordinator, jobs without it are rejected"),
+			osutil.NewEnvVar("WORKER_ALLOWED_TARGETS", &targets, true).
+				WithDescription("comma-separated hosts, or host:port, the worker agent sends requests to, jobs targeting others are rejected"),
+		))
+
+	addr := net.JoinHostPort(host, port)
+	log.Printf("starting worker at %s ...", addr)
+	osutil.ExitOnErr(http.ListenAndServe(addr, worker.Handler(secret, targets)))
+}
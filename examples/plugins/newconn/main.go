@@ -0,0 +1,39 @@
+// Command newconn is an example workload plugin sending every
+// HTTP request over a new connection, with keep-alives disabled.
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/workload"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	c := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	osutil.ExitOnErr(workload.Serve(ctx, func(ctx context.Context, req workload.Request) workload.Result {
+		t1 := time.Now()
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, req.Target, nil)
+		if err != nil {
+			return workload.Result{Error: err.Error()}
+		}
+		resp, err := c.Do(r)
+		if err != nil {
+			return workload.Result{Error: err.Error()}
+		}
+		_, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return workload.Result{Error: err.Error()}
+		}
+		return workload.Result{StatusCode: resp.StatusCode, TimeNano: time.Since(t1).Nanoseconds()}
+	}))
+}
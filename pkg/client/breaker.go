@@ -0,0 +1,87 @@
+package client
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// ErrErrorRate is the error the requests of a client are aborted with once too many
+// of its last requests failed, as set by [DoTimeRepeatClient.WithAbortOnErrorRate].
+type ErrErrorRate struct {
+	Failed int
+	Window int
+}
+
+func (e *ErrErrorRate) Error() string {
+	return fmt.Sprintf("aborted as %d of the last %d requests failed", e.Failed, e.Window)
+}
+
+// errorRateBreaker trips once the share of failed requests among the last
+// requests of a client, over a window of them, exceeds its threshold.
+//
+// The methods of a nil *errorRateBreaker do nothing, so requests are sent the same way without it.
+type errorRateBreaker struct {
+	threshold float64 // share of the requests of the window which may fail
+	logger    *slog.Logger
+
+	mu       sync.Mutex
+	outcomes []bool // whether the last requests failed, a ring starting at next once full
+	next     int
+	full     bool
+	failed   int           // failed requests in the window
+	tripped  *ErrErrorRate // set once the breaker tripped
+}
+
+// record records whether a request failed, returning the error the requests
+// of the client are aborted with if the breaker tripped, now or before.
+func (b *errorRateBreaker) record(failed bool) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tripped != nil {
+		return b.tripped
+	}
+	if b.full && b.outcomes[b.next] {
+		b.failed--
+	}
+	b.outcomes[b.next] = failed
+	if failed {
+		b.failed++
+	}
+	b.next = (b.next + 1) % len(b.outcomes)
+	b.full = b.full || b.next == 0
+	if !b.full || float64(b.failed) <= b.threshold*float64(len(b.outcomes)) {
+		return nil
+	}
+	b.tripped = &ErrErrorRate{Failed: b.failed, Window: len(b.outcomes)}
+	b.logger.Error("error rate abort", "failed", b.failed, "window", len(b.outcomes),
+		"threshold_percent", 100*b.threshold)
+	return b.tripped
+}
+
+// err returns the error the requests of the client are aborted with, if the breaker tripped.
+func (b *errorRateBreaker) err() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tripped == nil {
+		return nil
+	}
+	return b.tripped
+}
+
+// WithAbortOnErrorRate has the client abort its requests once more than percent of the
+// last window requests failed, with an [ErrErrorRate], so broken runs fail fast instead
+// of producing useless results. Requests failed with an error, or whose response handler
+// failed, e.g. as they were invalid, count as failed, once done with their retries.
+//
+// The abort is logged as "error rate abort". The requests in flight are still awaited.
+func (c *DoTimeRepeatClient) WithAbortOnErrorRate(percent float64, window int) *DoTimeRepeatClient {
+	c.breaker = &errorRateBreaker{threshold: percent / 100, logger: c.logger, outcomes: make([]bool, max(window, 1))}
+	return c
+}
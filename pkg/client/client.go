@@ -1,16 +1,29 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	mrand "math/rand/v2"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // HttpVersion represents the HTTP protocol version to use in the client.
@@ -19,8 +32,10 @@ type HttpVersion uint8
 const (
 	// HTTP1 represents HTTP/1.x protocol.
 	HTTP1 HttpVersion = iota + 1
-	// HTTP2 represents HTTP/2 protocol.
+	// HTTP2 represents HTTP/2 protocol, unencrypted (h2c) for http URLs.
 	HTTP2 HttpVersion = iota + 1
+	// HTTP3 represents HTTP/3 protocol, over QUIC.
+	HTTP3 HttpVersion = iota + 1
 
 	UuidLogField = "req_uuid"
 )
@@ -36,6 +51,73 @@ type DoTimeRepeatClient struct {
 	c      *http.Client  // underlying HTTP client
 	req    *http.Request // base HTTP request to clone and send
 	logger *slog.Logger  // logger for request tracing and timing
+	tracer trace.Tracer  // tracer recording the spans of the requests, nil to disable them
+
+	upload    []byte // block of random bytes the request bodies repeat, nil to send no body
+	uploadLen int64  // length of the request bodies
+	chunked   bool   // stream the request bodies without a Content-Length
+	// Sent before and after the random bytes of the request bodies, framing them, e.g. as the file of
+	// a multipart form, nil to send the bytes alone.
+	uploadHead, uploadTail []byte
+	uploadType             string // Content-Type of the request bodies, empty to leave it to the base request
+	expectContinue         bool   // the requests are sent with an "Expect: 100-continue" header
+
+	bucket     *tokenBucket // paces the requests, nil to send them as fast as possible
+	targetRate float64      // requests per second the client aims for, 0 when it only caps them
+	sent       atomic.Int64 // requests sent, failed ones included
+	completed  atomic.Int64 // requests whose responses were handled
+	workers    atomic.Int64 // workers sending the requests while ramping up, 0 when not ramping up
+	idleGap    atomic.Int64 // idle gap before the next request to complete, 0 if not after a gap
+
+	timeout  time.Duration // bounds each request, its response body included, 0 to not bound them
+	timeouts atomic.Int64  // requests that took longer than the timeout
+
+	retry *RetryPolicy // how failed requests are retried, nil to not retry them
+	hist  *histogram   // records the times of the completed requests instead of logging them, nil to log them
+
+	thinkTime time.Duration // mean time each worker waits between its requests
+	thinkDist string        // distribution of the think times around their mean
+
+	targets *targetPicker // picks the base request of each request among several targets, nil to send c.req
+	proxied bool          // the requests are sent through a forward proxy
+
+	drain     time.Duration     // how long the requests in flight once the client is interrupted are awaited
+	breaker   *errorRateBreaker // aborts the requests once too many of them failed, nil to never abort them
+	metrics   *metrics          // counts the requests for Prometheus, nil to not count them
+	redirects bool              // the redirects followed by the requests are logged
+	slos      *slos             // objectives the runs must meet, nil to not check them
+	perWorker bool              // every worker sends its requests over a clone of the transport
+	earlyData bool              // the HTTP/3 GET requests are sent as TLS 1.3 early data
+
+	run atomic.Pointer[runStats] // accumulates the requests of the current run, nil outside of runs
+
+	dial     *net.Dialer       // dials the HTTP/1 and HTTP/2 connections, nil to leave it to the transport
+	hosts    map[string]string // addresses of host names connected to without resolving them
+	resolver bool              // host names are resolved with a DNS server of the client
+	dnsCache *dnsCache         // caches the addresses host names resolved to, nil to resolve them every dial
+	network  string            // network the connections are dialed over, tcp4 or tcp6, empty for either
+	sockOpts SocketOptions     // options of the sockets of the dialed connections
+
+	acceptEncoding string // encodings the responses are requested in, empty to leave it to the transport
+	decompress     bool   // decompress the responses before handling them
+}
+
+// uuidKey is the context key of the UUID of the request a context belongs to.
+type uuidKey struct{}
+
+// ErrRequestTimeout is the error of a request that took longer than the
+// timeout set by [DoTimeRepeatClient.WithRequestTimeout].
+type ErrRequestTimeout struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *ErrRequestTimeout) Error() string {
+	return fmt.Sprintf("request timed out after %s: %s", e.Timeout, e.Err)
+}
+
+func (e *ErrRequestTimeout) Unwrap() error {
+	return e.Err
 }
 
 // DoTimeRepeat sends the HTTP request n times, handling responses and errors with the provided handlers.
@@ -47,29 +129,401 @@ type DoTimeRepeatClient struct {
 //	eh: handler for processing errors
 //
 // Use the [ErrorHandler] parameter to define what errors should cause it to abort.
-func (c *DoTimeRepeatClient) DoTimeRepeat(ctx context.Context, n int, rh ResponseHandler, eh ErrorHandler) error {
-	for range n {
-		reqUuid := rand.Text()
-		req := c.req.Clone(ctx)
-		req = AddTraceToRequest(reqUuid, req, c.logger)
+func (c *DoTimeRepeatClient) DoTimeRepeat(ctx context.Context, n int, rh ResponseHandler, eh ErrorHandler) (err error) {
+	defer c.summarizeRun(&err)()
+	return c.doTimeRepeat(ctx, countdown(n), rh, eh)
+}
 
-		t1 := time.Now()
-		resp, err := c.c.Do(req)
-		if err := eh(reqUuid, err); err != nil {
+// countdown returns a function reporting there are requests left to send n times.
+func countdown(n int) func() bool {
+	return func() bool {
+		n--
+		return n >= 0
+	}
+}
+
+// doTimeRepeat sends the HTTP request as [DoTimeRepeatClient.DoTimeRepeat]
+// for as long as more reports there are requests left to send.
+func (c *DoTimeRepeatClient) doTimeRepeat(ctx context.Context, more func() bool, rh ResponseHandler, eh ErrorHandler) error {
+	reqCtx, cancel := c.drainContext(ctx)
+	defer cancel()
+	for i := 0; more(); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.breaker.err(); err != nil {
+			return err
+		}
+		if i > 0 && c.thinkTime > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.think()):
+			}
+		}
+		if err := c.bucket.wait(ctx); err != nil {
 			return err
 		}
-		if err := eh(reqUuid, rh(resp)); err != nil {
+		if err := c.doOnce(reqCtx, time.Time{}, rh, eh); err != nil {
 			return err
 		}
-		c.logger.Info("req completion", "status_code", resp.StatusCode, "max_time_nano", time.Since(t1).Nanoseconds(), UuidLogField, reqUuid)
 	}
 	return nil
 }
 
+// drainContext returns the context the requests sent while ctx is alive are sent with,
+// which outlives ctx by the drain timeout of the client, so the requests in flight once
+// ctx is done, e.g. as the client is interrupted, can complete. It is canceled along
+// with ctx if the client has no drain timeout.
+func (c *DoTimeRepeatClient) drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.drain <= 0 {
+		return context.WithCancel(ctx)
+	}
+	reqCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(c.drain, cancel)
+	})
+	return reqCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// summarizeInterruption returns a function logging the requests sent and completed
+// since it was returned as "interrupted summary", if ctx is done by the time it is
+// called, so the results of interrupted runs are still summarized.
+func (c *DoTimeRepeatClient) summarizeInterruption(ctx context.Context) func() {
+	start, sent, completed := time.Now(), c.sent.Load(), c.completed.Load()
+	return func() {
+		if ctx.Err() == nil {
+			return
+		}
+		c.logger.Warn("interrupted summary", "requests", c.sent.Load()-sent, "completed", c.completed.Load()-completed,
+			"elapsed_nano", time.Since(start).Nanoseconds())
+	}
+}
+
+// doOnce sends the HTTP request once, as an iteration of [DoTimeRepeatClient.DoTimeRepeat],
+// attempting it again as long as the retry policy of the client retries its failures.
+// The request was scheduled to start at scheduled, if it is not zero.
+func (c *DoTimeRepeatClient) doOnce(ctx context.Context, scheduled time.Time, rh ResponseHandler, eh ErrorHandler) error {
+	c.sent.Add(1)
+	c.run.Load().sent()
+	defer c.metrics.start()()
+	reqUuid := rand.Text()
+	base := c.req
+	if c.targets != nil {
+		base = c.targets.pick()
+	}
+	t1 := time.Now()
+	for attempt := 1; ; attempt++ {
+		if done, err := c.doAttempt(ctx, base, reqUuid, attempt, t1, scheduled, rh, eh); done || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.retry.backoff(attempt)):
+		}
+	}
+}
+
+// doAttempt sends an attempt of the request reqUuid to the target of base, first sent at t1
+// and scheduled to start at scheduled, if it is not zero, reporting whether the request
+// is done, or its attempt failed and is to be retried.
+func (c *DoTimeRepeatClient) doAttempt(ctx context.Context, base *http.Request, reqUuid string, attempt int, t1, scheduled time.Time, rh ResponseHandler, eh ErrorHandler) (bool, error) {
+	reqCtx := ctx
+	if c.proxied {
+		// For the tunnels through the proxy to be logged with the request they were established for.
+		reqCtx = context.WithValue(reqCtx, uuidKey{}, reqUuid)
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(reqCtx, c.timeout)
+		defer cancel()
+	}
+	var hops *redirectHops
+	if c.redirects {
+		reqCtx, hops = withRedirectHops(reqCtx, reqUuid, time.Now())
+	}
+	req := base.Clone(reqCtx)
+	if base.GetBody != nil {
+		// The body of the base request, e.g. replayed, is read anew by every request.
+		body, err := base.GetBody()
+		if err != nil {
+			return true, eh(reqUuid, err)
+		}
+		req.Body = body
+	}
+	if c.acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", c.acceptEncoding)
+	}
+	if c.earlyData && req.Method == http.MethodGet {
+		// Rewritten on every request, as the base requests of the targets are cloned before it is known.
+		req.Method = http3.MethodGet0RTT
+	}
+	if c.expectContinue {
+		// Set on every request, as the base requests of the targets are cloned before it is known.
+		req.Header.Set("Expect", "100-continue")
+	}
+	if c.hist == nil {
+		req = AddTraceToRequest(reqUuid, req, c.logger)
+	}
+	var sent *countingReadCloser
+	if c.upload != nil {
+		var body io.Reader = io.LimitReader(&repeatReader{block: c.upload}, c.uploadLen)
+		if c.uploadHead != nil {
+			body = io.MultiReader(bytes.NewReader(c.uploadHead), body, bytes.NewReader(c.uploadTail))
+		}
+		sent = &countingReadCloser{ReadCloser: io.NopCloser(body)}
+		req.Body, req.ContentLength = sent, int64(len(c.uploadHead))+c.uploadLen+int64(len(c.uploadTail))
+		if c.chunked {
+			req.ContentLength = -1
+		}
+		if c.uploadType != "" {
+			// Set on every request, as the base requests of the targets are cloned before it is known.
+			req.Header.Set("Content-Type", c.uploadType)
+		}
+	}
+	var spans *requestSpans
+	if c.tracer != nil {
+		req, spans = startRequestSpans(c.tracer, reqUuid, req)
+	}
+
+	hc := c.httpClient(ctx)
+	cookiesSent := 0
+	if hc.Jar != nil {
+		cookiesSent = len(hc.Jar.Cookies(req.URL))
+	}
+	tAttempt := time.Now()
+	// Recorded when the times are aggregated too, for the reuse of the connections to be summarized.
+	req, phases := startRequestPhases(req, tAttempt)
+	resp, err := hc.Do(req)
+	err = c.timedOut(ctx, err)
+	spans.responded(resp, err)
+	if c.retry.retries(req, attempt, resp, err) {
+		attrs := []any{"attempt", attempt, "max_time_nano", time.Since(tAttempt).Nanoseconds(), UuidLogField, reqUuid}
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		} else {
+			attrs = append(attrs, "status_code", resp.StatusCode)
+			// Drained for the connection to be reused by the next attempt.
+			err = DrainCloseBody(resp)
+		}
+		spans.end(err)
+		c.metrics.retried()
+		c.logger.Warn("req retry", attrs...)
+		return false, nil
+	}
+	if err != nil {
+		spans.end(nil)
+		c.metrics.fail(errors.As(err, new(*ErrRequestTimeout)))
+		if !errors.Is(err, context.Canceled) {
+			c.run.Load().fail()
+		}
+		// Failed requests, e.g. while the server is down, have no response to handle.
+		if err := eh(reqUuid, err); err != nil {
+			return true, err
+		}
+		return true, c.breaker.record(!errors.Is(err, context.Canceled))
+	}
+	body := &countingReadCloser{ReadCloser: resp.Body}
+	resp.Body = body
+	encoding := resp.Header.Get("Content-Encoding")
+	var decoded *countingReadCloser
+	if c.decompress {
+		decoded = decompressBody(resp)
+	}
+	err = c.timedOut(ctx, rh(resp))
+	spans.end(err)
+	if err := eh(reqUuid, err); err != nil {
+		return true, err
+	}
+	// Returned once the completion is recorded, so it is not lost.
+	abortErr := c.breaker.record(err != nil)
+	c.metrics.complete(resp.StatusCode, time.Since(t1), body.n)
+	c.run.Load().complete(time.Since(t1), err != nil, phases.reused())
+	if c.hist != nil {
+		if !scheduled.IsZero() {
+			// Corrected for coordinated omission, from when the request should have started.
+			t1 = scheduled
+		}
+		c.hist.record(time.Since(t1).Nanoseconds())
+		c.completed.Add(1)
+		return true, abortErr
+	}
+	attrs := []any{"status_code", resp.StatusCode, "max_time_nano", time.Since(t1).Nanoseconds(), "bytes_read", body.n, UuidLogField, reqUuid}
+	if sent != nil {
+		attrs = append(attrs, "bytes_sent", sent.n)
+	}
+	if encoding != "" {
+		attrs = append(attrs, "content_encoding", encoding)
+	}
+	if decoded != nil {
+		attrs = append(attrs, "bytes_decoded", decoded.n)
+	}
+	if !scheduled.IsZero() {
+		attrs = append(attrs, "start_delay_nano", t1.Sub(scheduled).Nanoseconds())
+	}
+	// Of the last attempt, when the request was retried.
+	attrs = append(attrs, phases.attrs()...)
+	if c.targets != nil {
+		attrs = append(attrs, "target", base.URL.String())
+	}
+	if base.Method != http.MethodGet {
+		attrs = append(attrs, "method", base.Method)
+	}
+	if src := c.dnsSource(base.URL.Hostname()); src != "" {
+		attrs = append(attrs, "dns_source", src)
+	}
+	if workers := c.workers.Load(); workers > 0 {
+		attrs = append(attrs, "workers", workers)
+	}
+	if gap := c.idleGap.Swap(0); gap > 0 {
+		attrs = append(attrs, "idle_gap_nano", gap)
+	}
+	if n := hops.count(); n > 0 {
+		attrs = append(attrs, "redirects", n)
+	}
+	if hc.Jar != nil {
+		attrs = append(attrs, "cookies_sent", cookiesSent, "cookies_set", len(resp.Cookies()))
+	}
+	// Logged with the completion, as reused connections
+	// do not report their TLS handshake to the trace.
+	if resp.TLS != nil {
+		attrs = append(attrs, "tls_resumed", resp.TLS.DidResume)
+	}
+	if spans != nil {
+		attrs = append(attrs, TraceIDLogField, spans.traceID())
+	}
+	c.completed.Add(1)
+	c.logger.Info("req completion", attrs...)
+	return true, abortErr
+}
+
+// timedOut counts and returns err as an [ErrRequestTimeout] when it is
+// due to the request timeout, rather than the cancellation of ctx.
+func (c *DoTimeRepeatClient) timedOut(ctx context.Context, err error) error {
+	if c.timeout == 0 || ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	c.timeouts.Add(1)
+	return &ErrRequestTimeout{Timeout: c.timeout, Err: err}
+}
+
+// logTimeouts logs the requests that timed out, if their time is bounded, once the client is done.
+func (c *DoTimeRepeatClient) logTimeouts(timeouts int64) {
+	if c.timeout > 0 {
+		c.logger.Info("timeout summary", "timeout_nano", c.timeout.Nanoseconds(), "timeouts", c.timeouts.Load()-timeouts)
+	}
+}
+
+// logLatencies logs the summary of the times of the completed requests, if they are aggregated, once the client is done.
+func (c *DoTimeRepeatClient) logLatencies() {
+	if c.hist == nil {
+		return
+	}
+	n, minNano, maxNano, meanNano := c.hist.summary()
+	c.logger.Info("latency summary", "requests", c.sent.Load(), "completed", n,
+		"min_nano", minNano, "max_nano", maxNano, "mean_nano", meanNano,
+		"p50_nano", c.hist.quantile(0.5), "p90_nano", c.hist.quantile(0.9), "p99_nano", c.hist.quantile(0.99),
+		"p999_nano", c.hist.quantile(0.999), "p9999_nano", c.hist.quantile(0.9999))
+}
+
+// DoTimeRepeatConcurrently sends the HTTP request n times as [DoTimeRepeatClient.DoTimeRepeat],
+// split across workers goroutines, each sending its requests one after the other.
+//
+// Workers only wait for each other to finish, so an error aborts the requests
+// left of the worker that got it, while the other workers go on.
+//
+// With a target rate, set by [DoTimeRepeatClient.WithTargetRate], the rate achieved
+// is logged once the workers are done, along with the target. With a request timeout,
+// set by [DoTimeRepeatClient.WithRequestTimeout], so are the requests that timed out,
+// and with [DoTimeRepeatClient.WithAggregation], the summary of the request times.
+func (c *DoTimeRepeatClient) DoTimeRepeatConcurrently(ctx context.Context, n, workers int, rh ResponseHandler, eh ErrorHandler) (err error) {
+	defer c.summarizeRun(&err)()
+	defer c.summarizeInterruption(ctx)()
+	defer c.logLatencies()
+	defer c.logTimeouts(c.timeouts.Load())
+	if c.targetRate > 0 {
+		start, sent := time.Now(), c.sent.Load()
+		defer func() {
+			elapsed := time.Since(start)
+			sent := c.sent.Load() - sent
+			c.logger.Info("rate summary", "target_rps", c.targetRate, "achieved_rps", float64(sent)/elapsed.Seconds(),
+				"requests", sent, "elapsed_nano", elapsed.Nanoseconds())
+		}()
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := range workers {
+		share := n / workers
+		if i < n%workers {
+			share++
+		}
+		if share == 0 {
+			continue
+		}
+		wg.Go(func() {
+			errs[i] = c.doTimeRepeat(c.workerContext(ctx), countdown(share), rh, eh)
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// DoTimeRepeatFor sends the HTTP request over and over for the duration d, as
+// [DoTimeRepeatClient.DoTimeRepeat], from workers goroutines, each sending its
+// requests one after the other.
+//
+// Once d elapsed, no more requests are sent and the requests in flight are
+// awaited. The requests sent and completed are then logged with the time they took
+// and the rate they completed at, so the throughput of clients can be compared,
+// as are the requests that timed out with a request timeout and the summary
+// of the request times when they are aggregated.
+func (c *DoTimeRepeatClient) DoTimeRepeatFor(ctx context.Context, d time.Duration, workers int, rh ResponseHandler, eh ErrorHandler) (err error) {
+	defer c.summarizeRun(&err)()
+	defer c.summarizeInterruption(ctx)()
+	defer c.logLatencies()
+	defer c.logTimeouts(c.timeouts.Load())
+	start, sent, completed := time.Now(), c.sent.Load(), c.completed.Load()
+	until := start.Add(d)
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := range workers {
+		wg.Go(func() {
+			errs[i] = c.doTimeRepeat(c.workerContext(ctx), func() bool { return time.Now().Before(until) }, rh, eh)
+		})
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	completed = c.completed.Load() - completed
+	attrs := []any{"duration_nano", d.Nanoseconds(), "requests", c.sent.Load() - sent, "completed", completed,
+		"elapsed_nano", elapsed.Nanoseconds(), "achieved_rps", float64(completed) / elapsed.Seconds()}
+	if c.targetRate > 0 {
+		attrs = append(attrs, "target_rps", c.targetRate)
+	}
+	c.logger.Info("duration summary", attrs...)
+	return errors.Join(errs...)
+}
+
 // LogErr logs the error with the logger set at the client adding the request UUID information.
+//
+// Responses found invalid by a handler of [ValidateResponse] are logged as "req invalid" instead.
 func (c *DoTimeRepeatClient) LogErr(reqUuid string, err error) error {
+	var invalidErr *ErrInvalidResponse
+	if errors.As(err, &invalidErr) {
+		c.logger.Error("req invalid", "error", err, UuidLogField, reqUuid)
+		return nil
+	}
 	if err != nil {
-		c.logger.Error("req failed", "error", err, UuidLogField, reqUuid)
+		attrs := []any{"error", err, UuidLogField, reqUuid}
+		var timeoutErr *ErrRequestTimeout
+		if errors.As(err, &timeoutErr) {
+			attrs = append(attrs, "timeout", true)
+		}
+		c.logger.Error("req failed", attrs...)
 	}
 	return nil
 }
@@ -86,7 +540,363 @@ func NewDoTimeRepeatClient(req *http.Request, logger *slog.Logger, httpV HttpVer
 	if err != nil {
 		return nil, fmt.Errorf("failed to create underlying HTTP client: %w", err)
 	}
-	return &DoTimeRepeatClient{c, req, logger}, nil
+	return &DoTimeRepeatClient{c: c, req: req, logger: logger}, nil
+}
+
+// NewDoTimeRepeatClientWithTransport creates a new DoTimeRepeatClient sending its requests
+// through rt, e.g. an instrumented, caching or proxying transport, instead of a transport
+// of its own, so custom transports are timed, traced and logged the same way.
+//
+// The phases of the requests are logged as far as rt reports them to the
+// [httptrace.ClientTrace] of their context, as an [http.Transport] it wraps does.
+// The options setting the transport of the client, e.g. [DoTimeRepeatClient.WithTransportOptions]
+// or [DoTimeRepeatClient.WithStaticHosts], are ignored unless rt is an [http.Transport]
+// or an [http3.Transport].
+func NewDoTimeRepeatClientWithTransport(req *http.Request, logger *slog.Logger, rt http.RoundTripper) *DoTimeRepeatClient {
+	return &DoTimeRepeatClient{c: &http.Client{Transport: rt}, req: req, logger: logger}
+}
+
+// WithTracer has the client record a span of every request with tracer, with child spans of
+// its DNS lookup, connection, TLS handshake and response body, and log the ID of its trace.
+//
+// The HTTP/3 transport reports the QUIC handshake of its connections as their connection
+// and TLS handshake, which end once the requests can be sent, before the handshake is
+// complete when they are sent as 0-RTT early data.
+func (c *DoTimeRepeatClient) WithTracer(tracer trace.Tracer) *DoTimeRepeatClient {
+	c.tracer = tracer
+	return c
+}
+
+// WithTLSResumption has the client resume the TLS sessions of its new connections
+// with the session tickets issued by the server, instead of a full handshake.
+//
+// If earlyData is true, HTTP/3 GET requests are also sent as TLS 1.3 early
+// data (0-RTT), along with the handshake of connections resuming a session.
+func (c *DoTimeRepeatClient) WithTLSResumption(earlyData bool) *DoTimeRepeatClient {
+	c.tlsConfig().ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	if _, ok := c.c.Transport.(*http3.Transport); ok {
+		c.earlyData = earlyData
+	}
+	return c
+}
+
+// WithAlternatingTLSResumption has the client resume the TLS sessions of every other new
+// connection only, as [DoTimeRepeatClient.WithTLSResumption], with a full handshake on the
+// others, so the latency of fresh and resumed handshakes can be compared within a single run.
+// Whether the connection of every request resumed its session is logged with its completion.
+//
+// Handshakes only happen on new connections, e.g. for every request with [DoTimeRepeatClient.WithoutKeepAlive].
+func (c *DoTimeRepeatClient) WithAlternatingTLSResumption(earlyData bool) *DoTimeRepeatClient {
+	c.WithTLSResumption(earlyData)
+	cfg := c.tlsConfig()
+	cfg.ClientSessionCache = &alternatingSessionCache{ClientSessionCache: cfg.ClientSessionCache}
+	return c
+}
+
+// alternatingSessionCache is a [tls.ClientSessionCache] missing every
+// other lookup of a session, so every other handshake is a full one.
+type alternatingSessionCache struct {
+	tls.ClientSessionCache
+	gets atomic.Uint64
+}
+
+func (c *alternatingSessionCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	if c.gets.Add(1)%2 == 1 {
+		return nil, false
+	}
+	return c.ClientSessionCache.Get(sessionKey)
+}
+
+// WithRootCAs has the client verify the certificates of https servers
+// against the certificate authorities in roots, instead of those of
+// the system, HTTP/3 servers included.
+func (c *DoTimeRepeatClient) WithRootCAs(roots *x509.CertPool) *DoTimeRepeatClient {
+	cfg := c.tlsConfig()
+	cfg.RootCAs, cfg.InsecureSkipVerify = roots, false
+	return c
+}
+
+// WithInsecureSkipVerify has the client skip the verification of the
+// certificates of https servers, e.g. of staging services with
+// self-signed certificates, HTTP/3 servers included.
+func (c *DoTimeRepeatClient) WithInsecureSkipVerify() *DoTimeRepeatClient {
+	c.tlsConfig().InsecureSkipVerify = true
+	return c
+}
+
+// tlsConfig returns the TLS configuration of the transport of the client, creating it if needed.
+func (c *DoTimeRepeatClient) tlsConfig() *tls.Config {
+	switch t := c.c.Transport.(type) {
+	case *http3.Transport:
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		return t.TLSClientConfig
+	case *http.Transport:
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		return t.TLSClientConfig
+	case *FastHTTPTransport:
+		if t.c.TLSConfig == nil {
+			t.c.TLSConfig = &tls.Config{}
+		}
+		return t.c.TLSConfig
+	}
+	return &tls.Config{}
+}
+
+// LoadCertPool returns a pool of the PEM encoded certificates in the file at path.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no PEM encoded certificate found in %s", path)
+	}
+	return pool, nil
+}
+
+// WithMaxConnsPerHost limits the connections the client opens to the server to n,
+// so concurrent requests beyond the limit block waiting for a connection.
+//
+// HTTP/2 connections carry concurrent requests up to the server limit of
+// concurrent streams, requests beyond it wait for a connection as well.
+// HTTP/3 connections are not limited.
+func (c *DoTimeRepeatClient) WithMaxConnsPerHost(n int) *DoTimeRepeatClient {
+	return c.WithTransportOptions(TransportOptions{MaxConnsPerHost: n})
+}
+
+// TransportOptions tune the connection pool of the HTTP/1 and HTTP/2 transport
+// of a client, so its behavior, e.g. the churn of connections once the idle pool
+// is exhausted, can be measured. Zero values keep the defaults of net/http.
+//
+// MaxIdleConns bounds the idle connections kept open to all the hosts and
+// MaxIdleConnsPerHost to each host, 2 by default. MaxConnsPerHost bounds
+// the connections open to each host, idle or not, and IdleConnTimeout how
+// long idle connections are kept open.
+type TransportOptions struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+}
+
+// WithTransportOptions sets the options of o which are not zero to the transport of the client.
+//
+// HTTP/3 connections are not pooled by the transport, which ignores them.
+func (c *DoTimeRepeatClient) WithTransportOptions(o TransportOptions) *DoTimeRepeatClient {
+	t, ok := c.c.Transport.(*http.Transport)
+	if !ok {
+		return c
+	}
+	if o.MaxIdleConns > 0 {
+		t.MaxIdleConns = o.MaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	}
+	if o.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = o.MaxConnsPerHost
+	}
+	if o.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = o.IdleConnTimeout
+	}
+	return c
+}
+
+// WithoutKeepAlive has the client dial a new connection for every request, closing it
+// once the request is done, so the latency of cold connections, their TCP and TLS
+// handshakes included, can be compared with that of reused ones.
+//
+// HTTP/1 requests are sent with a "Connection: close" header, and HTTP/2 connections
+// carry a single request each. HTTP/3 connections are still reused.
+func (c *DoTimeRepeatClient) WithoutKeepAlive() *DoTimeRepeatClient {
+	if t, ok := c.c.Transport.(*http.Transport); ok {
+		t.DisableKeepAlives = true
+	}
+	return c
+}
+
+// WithReadBufferSize sets the size of the buffer the client reads
+// HTTP/1 responses from their connections through to n bytes.
+//
+// HTTP/2 and HTTP/3 responses are read through the buffers of their transports.
+func (c *DoTimeRepeatClient) WithReadBufferSize(n int) *DoTimeRepeatClient {
+	if t, ok := c.c.Transport.(*http.Transport); ok {
+		t.ReadBufferSize = n
+	}
+	return c
+}
+
+// WithUploadBody has the client send a body of n random bytes with every request, with
+// its Content-Length or, if chunked is true, streamed without it, chunked in HTTP/1.
+//
+// The bodies repeat a block of random bytes, so generating them costs no more than copying it.
+func (c *DoTimeRepeatClient) WithUploadBody(n int64, chunked bool) *DoTimeRepeatClient {
+	c.upload = make([]byte, 64<<10)
+	rand.Read(c.upload)
+	c.uploadLen, c.chunked = n, chunked
+	return c
+}
+
+// WithMultipartUploadBody has the client send n random bytes with every request as the file
+// of a multipart/form-data form, as browsers upload files, streamed as [DoTimeRepeatClient.WithUploadBody]
+// streams them, so servers parsing the forms can be benchmarked.
+//
+// The bytes sent of every request, as logged, include the framing of the form.
+func (c *DoTimeRepeatClient) WithMultipartUploadBody(n int64, chunked bool) *DoTimeRepeatClient {
+	c.WithUploadBody(n, chunked)
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	// Writes the boundary and the headers of the part, into the buffer only.
+	mw.CreateFormFile("file", "upload.bin")
+	c.uploadHead = bytes.Clone(b.Bytes())
+	b.Reset()
+	mw.Close()
+	c.uploadTail = bytes.Clone(b.Bytes())
+	c.uploadType = mw.FormDataContentType()
+	return c
+}
+
+// WithExpectContinue has the client send the requests with an "Expect: 100-continue"
+// header, waiting for the server to accept them, or at most timeout, before sending
+// their bodies.
+//
+// HTTP/3 requests are sent without the header.
+func (c *DoTimeRepeatClient) WithExpectContinue(timeout time.Duration) *DoTimeRepeatClient {
+	if t, ok := c.c.Transport.(*http.Transport); ok {
+		t.ExpectContinueTimeout = timeout
+		c.expectContinue = true
+	}
+	return c
+}
+
+// WithRequestTimeout bounds the time of each request, its response body included, to d,
+// so hung connections fail their requests instead of stalling the client.
+// The requests that timed out are logged as failed, with a timeout field.
+func (c *DoTimeRepeatClient) WithRequestTimeout(d time.Duration) *DoTimeRepeatClient {
+	c.timeout = d
+	return c
+}
+
+// WithDrainTimeout has the client await the requests in flight once the context of its
+// requests is done, e.g. as it is interrupted, for at most d before canceling them, instead
+// of canceling them right away, so interrupted runs still yield their results. The requests
+// sent and completed until then are logged as "interrupted summary".
+func (c *DoTimeRepeatClient) WithDrainTimeout(d time.Duration) *DoTimeRepeatClient {
+	c.drain = d
+	return c
+}
+
+// WithAggregation has the client record the times of its completed requests into an
+// HDR histogram in memory, instead of logging their completions and trace events,
+// which costs too much at high request rates. The amount of requests, the minimum,
+// maximum and mean of their times, and their percentiles, are logged once it is done.
+//
+// Failed and retried requests are still logged, as they are rare and worth a look.
+func (c *DoTimeRepeatClient) WithAggregation() *DoTimeRepeatClient {
+	c.hist = &histogram{}
+	return c
+}
+
+// WithProxy has the client send its requests through the forward proxy at proxyUrl, an http,
+// https or socks5 URL, so the overhead the proxy adds to each request can be measured.
+//
+// The connections of the client are then those to the proxy, and requests to https
+// targets tunneled through HTTP proxies log the response to their CONNECT request
+// as "proxy connect", once the tunnel is established. HTTP/3 requests are not proxied.
+func (c *DoTimeRepeatClient) WithProxy(proxyUrl *url.URL) *DoTimeRepeatClient {
+	return c.withProxy(http.ProxyURL(proxyUrl))
+}
+
+// WithProxyFromEnvironment has the client send its requests through the forward proxies set
+// by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, as [DoTimeRepeatClient.WithProxy].
+func (c *DoTimeRepeatClient) WithProxyFromEnvironment() *DoTimeRepeatClient {
+	return c.withProxy(http.ProxyFromEnvironment)
+}
+
+func (c *DoTimeRepeatClient) withProxy(proxy func(*http.Request) (*url.URL, error)) *DoTimeRepeatClient {
+	t, ok := c.c.Transport.(*http.Transport)
+	if !ok {
+		return c
+	}
+	t.Proxy = proxy
+	t.OnProxyConnectResponse = func(ctx context.Context, proxyUrl *url.URL, connectReq *http.Request, connectRes *http.Response) error {
+		attrs := []any{"proxy", proxyUrl.Redacted(), "target", connectReq.Host, "status_code", connectRes.StatusCode}
+		if reqUuid, ok := ctx.Value(uuidKey{}).(string); ok {
+			attrs = append(attrs, UuidLogField, reqUuid)
+		}
+		c.logger.Info("proxy connect", attrs...)
+		return nil
+	}
+	c.proxied = true
+	return c
+}
+
+// WithCookieJar has the client keep the cookies set by the responses, and send them back with
+// the next requests, so servers sticking sessions to cookies set on a first response can be
+// benchmarked. The cookies sent with each request, and set by its response, are logged with it.
+//
+// The cookies are shared by all the requests of the client, as a single session.
+func (c *DoTimeRepeatClient) WithCookieJar() *DoTimeRepeatClient {
+	// New only fails with options holding an invalid public suffix list.
+	c.c.Jar, _ = cookiejar.New(nil)
+	return c
+}
+
+// Distributions of the think times of [DoTimeRepeatClient.WithThinkTime].
+const (
+	// ThinkFixed waits the mean think time between every request.
+	ThinkFixed = "fixed"
+	// ThinkUniform waits between 0 and twice the mean think time, uniformly distributed.
+	ThinkUniform = "uniform"
+	// ThinkExponential waits exponentially distributed times, as between requests arriving at random.
+	ThinkExponential = "exponential"
+)
+
+// WithThinkTime has each worker of the client wait between its requests, for
+// times of the distribution dist, ThinkFixed, ThinkUniform or ThinkExponential,
+// around the mean d, modeling clients which do not saturate the server and letting
+// idle connections be reused, or not, across the keep-alive timeouts of the server.
+func (c *DoTimeRepeatClient) WithThinkTime(d time.Duration, dist string) *DoTimeRepeatClient {
+	c.thinkTime, c.thinkDist = d, dist
+	return c
+}
+
+// think returns how long to wait before the next request.
+func (c *DoTimeRepeatClient) think() time.Duration {
+	switch c.thinkDist {
+	case ThinkUniform:
+		return mrand.N(2*c.thinkTime + 1)
+	case ThinkExponential:
+		return time.Duration(mrand.ExpFloat64() * float64(c.thinkTime))
+	default:
+		return c.thinkTime
+	}
+}
+
+// WithMaxRate caps the rate the client sends its requests at to rps requests per
+// second, across all the workers of [DoTimeRepeatClient.DoTimeRepeatConcurrently].
+//
+// Requests are spaced out evenly, without bursts, and the time a request waits for
+// its turn is not part of its time, which is still bound by the response time.
+func (c *DoTimeRepeatClient) WithMaxRate(rps float64) *DoTimeRepeatClient {
+	c.bucket, c.targetRate = newTokenBucket(rps, 1), 0
+	return c
+}
+
+// WithTargetRate paces the requests of the client at rps requests per second, across
+// all the workers of [DoTimeRepeatClient.DoTimeRepeatConcurrently], with a token bucket.
+//
+// Unlike [DoTimeRepeatClient.WithMaxRate], requests delayed by slow responses are made
+// up for in bursts of up to a second of requests, so the rate achieved stays on target
+// as long as the workers can keep up with it.
+func (c *DoTimeRepeatClient) WithTargetRate(rps float64) *DoTimeRepeatClient {
+	c.bucket, c.targetRate = newTokenBucket(rps, max(rps, 1)), rps
+	return c
 }
 
 // NewHTTPClient creates a new *http.Client configured for the specified HTTP version.
@@ -94,13 +904,30 @@ func NewDoTimeRepeatClient(req *http.Request, logger *slog.Logger, httpV HttpVer
 //	httpV: HTTP protocol version to use
 //
 // Returns a pointer to http.Client or an error if the version is invalid.
+//
+// The certificates of the servers are verified, HTTP/3 ones included, unless
+// skipped with [DoTimeRepeatClient.WithInsecureSkipVerify], e.g. for the
+// self-signed certificates of the servers of the benchmark.
+//
+// HTTP/2 is negotiated over TLS for https URLs and sent unencrypted, with prior
+// knowledge (h2c), for http URLs, instead of falling back to HTTP/1.1, so the
+// framing overhead of HTTP/2 can be measured without TLS in the picture.
 func NewHTTPClient(httpV HttpVersion) (*http.Client, error) {
+	if httpV == HTTP3 {
+		return &http.Client{
+			Transport: &http3.Transport{
+				TLSClientConfig: &tls.Config{},
+			},
+		}, nil
+	}
+
 	protos := &http.Protocols{}
 	switch httpV {
 	case HTTP1:
 		protos.SetHTTP1(true)
 	case HTTP2:
 		protos.SetHTTP2(true)
+		protos.SetUnencryptedHTTP2(true)
 	default:
 		return nil, fmt.Errorf("invalid HTTP version: %d", httpV)
 	}
@@ -139,6 +966,9 @@ func AddTraceToRequest(reqUuid string, req *http.Request, logger *slog.Logger) *
 			}
 			logger.Info(label, "status", true, UuidLogField, reqUuid)
 		},
+		Got100Continue: func() {
+			logger.Info("got 100 continue", UuidLogField, reqUuid)
+		},
 		GotFirstResponseByte: func() {
 			logger.Info("ttfb", UuidLogField, reqUuid)
 		},
@@ -162,7 +992,7 @@ func AddTraceToRequest(reqUuid string, req *http.Request, logger *slog.Logger) *
 			if err != nil {
 				logger.Error(label, "error", err, "server", cs.ServerName, UuidLogField, reqUuid)
 			}
-			logger.Info(label, "server", cs.ServerName, UuidLogField, reqUuid)
+			logger.Info(label, "server", cs.ServerName, "resumed", cs.DidResume, UuidLogField, reqUuid)
 		},
 	}))
 
@@ -185,3 +1015,156 @@ func DrainCloseBody(resp *http.Response) error {
 	}
 	return nil
 }
+
+// DrainCloseBodyBuffered returns a [ResponseHandler] draining and closing the
+// response body as [DrainCloseBody], reading it size bytes at a time.
+func DrainCloseBodyBuffered(size int) ResponseHandler {
+	bufs := &sync.Pool{New: func() any { return make([]byte, size) }}
+	return func(resp *http.Response) error {
+		if resp == nil {
+			return nil
+		}
+		buf := bufs.Get().([]byte)
+		defer bufs.Put(buf)
+		// io.Copy would read through the buffer of io.Discard instead.
+		var err error
+		for err == nil {
+			_, err = resp.Body.Read(buf)
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		return errors.Join(resp.Body.Close(), err)
+	}
+}
+
+// ReadCloseBody returns a [ResponseHandler] reading at most the first n bytes of
+// the response body before closing it, as clients abandoning large responses do.
+// Closing a body with much of it left unread closes its HTTP/1 connection, while
+// HTTP/2 and HTTP/3 reset the stream of the request only, so the connection reuse
+// of the versions can be compared.
+func ReadCloseBody(n int64) ResponseHandler {
+	return func(resp *http.Response) error {
+		if resp == nil {
+			return nil
+		}
+		_, err := io.CopyN(io.Discard, resp.Body, n)
+		if err == io.EOF {
+			// The body is shorter than n.
+			err = nil
+		}
+		return errors.Join(resp.Body.Close(), err)
+	}
+}
+
+// ThrottledReadCloseBody returns a [ResponseHandler] draining and closing the response body
+// as [DrainCloseBody], reading it at most bytesPerSec bytes per second, as slow clients do.
+// The unread body fills the receive buffers of the connection and its flow control window,
+// so the server is held back writing the response, and the connection held for longer.
+// It stops reading once the context of the request is done.
+func ThrottledReadCloseBody(bytesPerSec int64) ResponseHandler {
+	// Reads about every 10ms, so the body is read at a steady rate instead of in bursts.
+	size := max(bytesPerSec/100, 1)
+	return func(resp *http.Response) error {
+		if resp == nil {
+			return nil
+		}
+		ctx := resp.Request.Context()
+		buf := make([]byte, size)
+		start := time.Now()
+		var n int64
+		var err error
+		for err == nil {
+			var read int
+			read, err = resp.Body.Read(buf)
+			n += int64(read)
+			// When the bytes read so far are due at the rate.
+			due := start.Add(time.Duration(float64(n) / float64(bytesPerSec) * float64(time.Second)))
+			if wait := time.Until(due); wait > 0 && err == nil {
+				t := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					err = ctx.Err()
+				case <-t.C:
+				}
+				t.Stop()
+			}
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		return errors.Join(resp.Body.Close(), err)
+	}
+}
+
+// countingReadCloser counts the bytes read from the ReadCloser it wraps.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// tokenBucket paces the requests of the goroutines sharing it at its rate, in
+// bursts of up to its size. It starts with a single token, so the requests of
+// a run start at the rate instead of with a burst.
+type tokenBucket struct {
+	rate  float64 // tokens added per second
+	burst float64 // most tokens the bucket holds
+
+	mu     sync.Mutex
+	tokens float64   // tokens left, negative when requests are waiting for them
+	last   time.Time // when the tokens were last added
+}
+
+// newTokenBucket returns a [tokenBucket] adding rate tokens per second up to burst.
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: 1}
+}
+
+// wait takes a token, blocking until one is added if the bucket is empty, or ctx is
+// done. A nil bucket never waits.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	// The token is taken even if it is yet to be added, so waiting
+	// requests are given the tokens in the order they asked for them.
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// repeatReader reads its block of bytes over and over, never ending.
+type repeatReader struct {
+	block []byte
+	off   int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := copy(p, r.block[r.off:])
+	r.off = (r.off + n) % len(r.block)
+	return n, nil
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+)
+
+// FastHTTPTransport is an [http.RoundTripper] sending the requests with the HTTP/1.1
+// client of fasthttp instead of net/http, so both can be benchmarked head to head
+// by a client of [NewDoTimeRepeatClientWithTransport], timed and logged the same way.
+//
+// The requests and responses are converted from and to those of net/http, copying
+// their headers, while their bodies are streamed. The phases of the requests are not
+// reported to their [httptrace.ClientTrace], and the requests are only canceled at
+// the deadline of their context, as fasthttp does not take contexts.
+type FastHTTPTransport struct {
+	c *fasthttp.Client
+}
+
+// NewFastHTTPTransport returns a [FastHTTPTransport] with the defaults of fasthttp,
+// streaming the response bodies instead of reading them before returning them.
+func NewFastHTTPTransport() *FastHTTPTransport {
+	return &FastHTTPTransport{c: &fasthttp.Client{
+		StreamResponseBody: true,
+		// Sent as they are, as net/http sends them.
+		DisablePathNormalizing: true,
+	}}
+}
+
+func (t *FastHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	ctx := req.Context()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	freq := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(freq)
+	freq.Header.SetMethod(req.Method)
+	freq.SetRequestURI(req.URL.String())
+	if req.Host != "" {
+		freq.Header.SetHost(req.Host)
+	}
+	for k, vs := range req.Header {
+		for _, v := range vs {
+			freq.Header.Add(k, v)
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		n := int(req.ContentLength)
+		if n == 0 {
+			// Unknown, as for net/http, so the body is sent chunked.
+			n = -1
+		}
+		freq.SetBodyStream(req.Body, n)
+	}
+
+	fresp := fasthttp.AcquireResponse()
+	var err error
+	if deadline, ok := ctx.Deadline(); ok {
+		err = t.c.DoDeadline(freq, fresp, deadline)
+	} else {
+		err = t.c.Do(freq, fresp)
+	}
+	if err != nil {
+		fasthttp.ReleaseResponse(fresp)
+		if errors.Is(err, fasthttp.ErrTimeout) {
+			// For timed out requests to be told apart as those of net/http are.
+			err = fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+		}
+		return nil, err
+	}
+
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", fresp.StatusCode(), http.StatusText(fresp.StatusCode())),
+		StatusCode:    fresp.StatusCode(),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		ContentLength: max(int64(fresp.Header.ContentLength()), -1),
+		Request:       req,
+	}
+	for k, v := range fresp.Header.All() {
+		resp.Header.Add(string(k), string(v))
+	}
+	body := fresp.BodyStream()
+	if body == nil {
+		// Read along with the headers, e.g. when short enough.
+		body = bytes.NewReader(fresp.Body())
+	}
+	resp.Body = &fastHTTPBody{Reader: body, resp: fresp}
+	return resp, nil
+}
+
+// fastHTTPBody is the body of a response of a [FastHTTPTransport],
+// releasing the response, and its connection, once closed.
+type fastHTTPBody struct {
+	io.Reader
+	resp *fasthttp.Response
+}
+
+func (b *fastHTTPBody) Close() error {
+	if b.resp == nil {
+		return nil
+	}
+	err := b.resp.CloseBodyStream()
+	fasthttp.ReleaseResponse(b.resp)
+	b.resp = nil
+	return err
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/pessolato/httpmicrobench/pkg/grpcecho"
+)
+
+// EchoClient sends echo calls to the gRPC echo service over a single connection and logs timing information.
+type EchoClient struct {
+	conn    *grpc.ClientConn // underlying gRPC connection
+	payload []byte           // message sent in every call
+	logger  *slog.Logger     // logger for timing
+}
+
+// NewEchoClient creates a new EchoClient for the echo service listening at addr.
+//
+//	addr: host:port of the gRPC server
+//	payloadLen: size of the random message sent, and echoed back, in every call
+//	logger: logger for timing
+//
+// The connection is established lazily with the first call.
+func NewEchoClient(addr string, payloadLen int, logger *slog.Logger) (*EchoClient, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(grpcecho.CodecName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client: %w", err)
+	}
+	payload := make([]byte, payloadLen)
+	rand.Read(payload)
+	return &EchoClient{conn, payload, logger}, nil
+}
+
+// UnaryRepeat sends n unary echo calls, logging the timing of each one.
+//
+// The request UUID is sent in the metadata of the call, failed calls are logged and do not abort.
+func (c *EchoClient) UnaryRepeat(ctx context.Context, n int) error {
+	for range n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		reqUuid := rand.Text()
+		callCtx := metadata.AppendToOutgoingContext(ctx, grpcecho.UuidMetadataKey, reqUuid)
+
+		var resp []byte
+		t1 := time.Now()
+		err := c.conn.Invoke(callCtx, grpcecho.UnaryMethod, &c.payload, &resp)
+		if err != nil {
+			c.logger.Error("req failed", "error", err, "grpc_code", status.Code(err).String(), UuidLogField, reqUuid)
+			continue
+		}
+		c.logger.Info("req completion", "grpc_code", "OK", "max_time_nano", time.Since(t1).Nanoseconds(), UuidLogField, reqUuid)
+	}
+	return nil
+}
+
+// StreamRepeat sends n messages over a single streaming echo call, logging
+// the round trip of each one, tagged with its own request UUID.
+//
+// A failure breaks the stream, so it is logged and aborts.
+func (c *EchoClient) StreamRepeat(ctx context.Context, n int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.conn.NewStream(ctx, &grpcecho.StreamDesc, grpcecho.StreamMethod)
+	if err != nil {
+		return fmt.Errorf("failed to open echo stream: %w", err)
+	}
+
+	for range n {
+		reqUuid := rand.Text()
+		var resp []byte
+		t1 := time.Now()
+		err := stream.SendMsg(&c.payload)
+		if err == nil {
+			err = stream.RecvMsg(&resp)
+		}
+		if err != nil {
+			c.logger.Error("req failed", "error", err, "grpc_code", status.Code(err).String(), UuidLogField, reqUuid)
+			return fmt.Errorf("echo stream failed: %w", err)
+		}
+		c.logger.Info("req completion", "grpc_code", "OK", "max_time_nano", time.Since(t1).Nanoseconds(), UuidLogField, reqUuid)
+	}
+	return stream.CloseSend()
+}
+
+// Close closes the underlying connection.
+func (c *EchoClient) Close() error {
+	return c.conn.Close()
+}
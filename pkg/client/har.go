@@ -0,0 +1,134 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// harFile is the part of an HTTP Archive (HAR) 1.2 file the requests are replayed from.
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					// Encoding is not part of HAR 1.2, but is set by the
+					// browsers capturing binary bodies, as for responses.
+					Encoding string `json:"encoding"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// harSkippedHeaders are the headers of the captured requests which are not replayed,
+// as they are set by the transport for every request, or are HTTP/2 pseudo-headers,
+// which start with a colon.
+var harSkippedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+	"Accept-Encoding":   true,
+}
+
+// ReadHAR reads the requests of the entries of the HTTP Archive (HAR) read from r, in their
+// order, with their method, URL, headers and body, to be replayed by [DoTimeRepeatClient.WithReplay].
+//
+// The headers the transport sets for every request, e.g. Host, Content-Length and
+// Accept-Encoding, are not read, so the requests are sent as any other of the client.
+func ReadHAR(ctx context.Context, r io.Reader) ([]*http.Request, error) {
+	var har harFile
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("failed to decode HAR: %w", err)
+	}
+	reqs := make([]*http.Request, 0, len(har.Log.Entries))
+	for i, e := range har.Log.Entries {
+		var body []byte
+		if pd := e.Request.PostData; pd != nil {
+			body = []byte(pd.Text)
+			if pd.Encoding == "base64" {
+				b, err := base64.StdEncoding.DecodeString(pd.Text)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode body of HAR entry %d: %w", i, err)
+				}
+				body = b
+			}
+		}
+		var bodyReader io.Reader
+		if len(body) > 0 {
+			// Sets GetBody, so the body is sent again by every request.
+			bodyReader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, e.Request.Method, e.Request.URL, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request of HAR entry %d: %w", i, err)
+		}
+		for _, h := range e.Request.Headers {
+			if strings.HasPrefix(h.Name, ":") || harSkippedHeaders[http.CanonicalHeaderKey(h.Name)] {
+				continue
+			}
+			req.Header.Add(h.Name, h.Value)
+		}
+		if pd := e.Request.PostData; pd != nil && pd.MimeType != "" && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", pd.MimeType)
+		}
+		reqs = append(reqs, req)
+	}
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("no request found in HAR")
+	}
+	return reqs, nil
+}
+
+// LoadHAR reads the requests of the HTTP Archive (HAR) file at path, as [ReadHAR].
+func LoadHAR(ctx context.Context, path string) ([]*http.Request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open HAR: %w", err)
+	}
+	defer f.Close()
+	return ReadHAR(ctx, f)
+}
+
+// WithReplay has the client send the requests reqs, e.g. captured in a HAR file and read
+// by [ReadHAR], instead of its base request, picking them as selection, SelectRoundRobin,
+// in their order, or SelectRandom, so real traffic shapes are benchmarked instead of a
+// single request. The headers of the base request of the client, e.g. its Authorization
+// header, are set to every request, replacing those captured.
+//
+// The bodies of the requests are sent by every request, if the requests can get them
+// again, as those created by [http.NewRequest] with an in-memory body can. The URL of
+// the request of every completed request is logged with it, as with [DoTimeRepeatClient.WithTargets].
+func (c *DoTimeRepeatClient) WithReplay(reqs []*http.Request, selection string) *DoTimeRepeatClient {
+	if len(reqs) == 0 {
+		return c
+	}
+	p := &targetPicker{random: selection == SelectRandom}
+	for i, req := range reqs {
+		req = req.Clone(req.Context())
+		for name, values := range c.req.Header {
+			req.Header[name] = values
+		}
+		p.reqs = append(p.reqs, req)
+		p.cum = append(p.cum, i+1)
+	}
+	c.targets = p
+	return c
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// subBuckets is the amount of linear buckets each power of 2 range of
+// values is split in, so values are recorded with 3 significant digits.
+const subBuckets = 1024
+
+// histogram is an HDR histogram of latencies in nanoseconds, which records them
+// in buckets of constant memory instead of keeping every one of them.
+//
+// Values below 2*subBuckets are recorded exactly, and larger ones in the bucket
+// of their power of 2 range they fall in, off by less than 1/subBuckets of them.
+type histogram struct {
+	mu       sync.Mutex
+	counts   []uint64
+	n        uint64
+	min, max int64
+	sum      float64
+}
+
+// bucketOf returns the index of the bucket of the value v.
+func bucketOf(v int64) int {
+	if v < 2*subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - bits.Len64(2*subBuckets-1)
+	return 2*subBuckets + (shift-1)*subBuckets + int(v>>shift) - subBuckets
+}
+
+// highestOf returns the highest value recorded in the bucket i.
+func highestOf(i int) int64 {
+	if i < 2*subBuckets {
+		return int64(i)
+	}
+	shift := (i-2*subBuckets)/subBuckets + 1
+	sub := int64((i-2*subBuckets)%subBuckets + subBuckets)
+	return (sub+1)<<shift - 1
+}
+
+// record records the value v, negative values as 0.
+func (h *histogram) record(v int64) {
+	v = max(v, 0)
+	i := bucketOf(v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	if h.n == 0 || v < h.min {
+		h.min = v
+	}
+	h.max = max(h.max, v)
+	h.n++
+	h.sum += float64(v)
+}
+
+// quantile returns the value below which the share q of the values recorded fall,
+// as the highest value of its bucket, at most the highest value recorded.
+func (h *histogram) quantile(q float64) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.n == 0 {
+		return 0
+	}
+	rank := max(uint64(math.Ceil(q*float64(h.n))), 1)
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return min(highestOf(i), h.max)
+		}
+	}
+	return h.max
+}
+
+// summary returns the amount, minimum, maximum and mean of the values recorded.
+func (h *histogram) summary() (n uint64, minV, maxV, mean int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.n == 0 {
+		return 0, 0, 0, 0
+	}
+	return h.n, h.min, h.max, int64(h.sum / float64(h.n))
+}
+
+// Histogram is the HDR histogram of latencies in nanoseconds the client aggregates the
+// latencies of its requests with, for other packages to summarize latencies in constant
+// memory too, however many of them there are. Its zero value is an empty histogram.
+//
+// It is safe for concurrent use.
+type Histogram struct {
+	h histogram
+}
+
+// Record records the latency v, negative ones as 0.
+func (h *Histogram) Record(v int64) {
+	h.h.record(v)
+}
+
+// Quantile returns the latency below which the share q of the latencies recorded fall,
+// within 3 significant digits.
+func (h *Histogram) Quantile(q float64) int64 {
+	return h.h.quantile(q)
+}
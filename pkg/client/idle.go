@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// IdleSweep is a schedule of idle gaps of a client, which sends Batch requests one after
+// the other, then waits Step before its next batch, and Step longer before every next one,
+// up to Max, so the reuse of idle connections across the keep-alive timeout of the server
+// can be observed within a single run.
+type IdleSweep struct {
+	Batch int
+	Step  time.Duration
+	Max   time.Duration
+}
+
+// gaps returns the idle gaps of the sweep, in their order.
+func (s IdleSweep) gaps() []time.Duration {
+	var gaps []time.Duration
+	for gap := s.Step; gap > 0 && gap <= s.Max; gap += s.Step {
+		gaps = append(gaps, gap)
+	}
+	return gaps
+}
+
+// DoTimeRepeatIdleSweep sends the HTTP request in batches, as [DoTimeRepeatClient.DoTimeRepeat],
+// leaving its connection idle for the gaps of the sweep s between them, a first batch, and a
+// batch after every gap.
+//
+// The first request after every gap is logged with it as idle_gap_nano, along with, as every
+// request, how long the connection it reused was idle for as idle_nano, or the time it took to
+// connect again if the server closed the connection meanwhile. The requests which were sent
+// over an idle connection the server closed, and were sent again over a new connection by the
+// transport, are logged as stale_conn. Every gap is logged once over as "idle gap", with
+// whether its first request reused the connection, so the keep-alive timeout of the server
+// shows as the first gap whose request did not.
+func (c *DoTimeRepeatClient) DoTimeRepeatIdleSweep(ctx context.Context, s IdleSweep, rh ResponseHandler, eh ErrorHandler) (err error) {
+	defer c.summarizeRun(&err)()
+	defer c.summarizeInterruption(ctx)()
+	defer c.logLatencies()
+	defer c.logTimeouts(c.timeouts.Load())
+	if err := c.doTimeRepeat(ctx, countdown(s.Batch), rh, eh); err != nil {
+		return err
+	}
+	for _, gap := range s.gaps() {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(gap):
+		}
+		c.idleGap.Store(int64(gap))
+		reused := c.run.Load().reused.Load()
+		if err := c.doTimeRepeat(ctx, countdown(1), rh, eh); err != nil {
+			return err
+		}
+		c.logger.Info("idle gap", "gap_nano", gap.Nanoseconds(), "reused", c.run.Load().reused.Load() > reused)
+		if err := c.doTimeRepeat(ctx, countdown(s.Batch-1), rh, eh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsPath is the path the metrics of [DoTimeRepeatClient.MetricsHandler] are served at.
+const MetricsPath = "/metrics"
+
+// durationBuckets are the upper bounds, in seconds, of the buckets of the request durations.
+var durationBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics counts the requests of a client as they complete, to be scraped by Prometheus.
+//
+// The methods of a nil *metrics do nothing, so requests are sent the same way without it.
+type metrics struct {
+	inFlight  atomic.Int64
+	failed    atomic.Int64
+	timeouts  atomic.Int64
+	retries   atomic.Int64
+	bytesRead atomic.Int64
+
+	mu      sync.Mutex
+	codes   map[int]int64 // completed requests by status code
+	buckets []int64       // completed requests by the bucket of their duration, not cumulative
+	sum     time.Duration // durations of the completed requests
+}
+
+// start counts a request in flight, returning the function counting it done.
+func (m *metrics) start() func() {
+	if m == nil {
+		return func() {}
+	}
+	m.inFlight.Add(1)
+	return func() { m.inFlight.Add(-1) }
+}
+
+// retried counts a retried attempt of a request.
+func (m *metrics) retried() {
+	if m != nil {
+		m.retries.Add(1)
+	}
+}
+
+// fail counts a failed request, which timed out if timeout is true.
+func (m *metrics) fail(timeout bool) {
+	if m == nil {
+		return
+	}
+	m.failed.Add(1)
+	if timeout {
+		m.timeouts.Add(1)
+	}
+}
+
+// complete counts a completed request, with its status code, duration and bytes read.
+func (m *metrics) complete(code int, d time.Duration, bytesRead int64) {
+	if m == nil {
+		return
+	}
+	m.bytesRead.Add(bytesRead)
+	i, _ := slices.BinarySearch(durationBuckets, d.Seconds())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[code]++
+	m.buckets[i]++
+	m.sum += d
+}
+
+// write writes the metrics to w in the Prometheus text exposition format.
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	codes := make([]int, 0, len(m.codes))
+	for code := range m.codes {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	counts := make([]int64, len(codes))
+	for i, code := range codes {
+		counts[i] = m.codes[code]
+	}
+	buckets, sum := slices.Clone(m.buckets), m.sum
+	m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP hmb_client_requests_total Requests completed, by status code.")
+	fmt.Fprintln(w, "# TYPE hmb_client_requests_total counter")
+	for i, code := range codes {
+		fmt.Fprintf(w, "hmb_client_requests_total{code=\"%d\"} %d\n", code, counts[i])
+	}
+	counter := func(name, help string, v int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	counter("hmb_client_request_failures_total", "Requests failed without a response, once done with their retries.", m.failed.Load())
+	counter("hmb_client_request_timeouts_total", "Requests failed as they took longer than the request timeout.", m.timeouts.Load())
+	counter("hmb_client_request_retries_total", "Attempts of requests retried.", m.retries.Load())
+	counter("hmb_client_response_bytes_total", "Bytes of response bodies read by the completed requests.", m.bytesRead.Load())
+	fmt.Fprintf(w, "# HELP hmb_client_requests_in_flight Requests sent and not done yet.\n# TYPE hmb_client_requests_in_flight gauge\nhmb_client_requests_in_flight %d\n", m.inFlight.Load())
+
+	fmt.Fprintln(w, "# HELP hmb_client_request_duration_seconds Time the completed requests took, their retries included.")
+	fmt.Fprintln(w, "# TYPE hmb_client_request_duration_seconds histogram")
+	var n int64
+	for i, le := range durationBuckets {
+		n += buckets[i]
+		fmt.Fprintf(w, "hmb_client_request_duration_seconds_bucket{le=\"%s\"} %d\n", strconv.FormatFloat(le, 'g', -1, 64), n)
+	}
+	n += buckets[len(durationBuckets)]
+	fmt.Fprintf(w, "hmb_client_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", n)
+	fmt.Fprintf(w, "hmb_client_request_duration_seconds_sum %s\n", strconv.FormatFloat(sum.Seconds(), 'g', -1, 64))
+	fmt.Fprintf(w, "hmb_client_request_duration_seconds_count %d\n", n)
+}
+
+// WithMetrics has the client count its requests as they complete, by status code, along with
+// its failed, timed out and retried requests, its requests in flight and a histogram of the
+// times of its requests, so long-running benchmarks can be watched while they run. They are
+// served by [DoTimeRepeatClient.MetricsHandler] for Prometheus to scrape.
+func (c *DoTimeRepeatClient) WithMetrics() *DoTimeRepeatClient {
+	c.metrics = &metrics{codes: make(map[int]int64), buckets: make([]int64, len(durationBuckets)+1)}
+	return c
+}
+
+// MetricsHandler returns a handler responding with the metrics of the client
+// in the Prometheus text exposition format, set by [DoTimeRepeatClient.WithMetrics].
+// It responds with 404 Not Found if the client does not count them.
+func (c *DoTimeRepeatClient) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.metrics == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.metrics.write(w)
+	})
+}
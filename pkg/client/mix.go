@@ -0,0 +1,124 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MixRequest is a kind of request of a request mix, sent in proportion to its Weight among
+// the others: Method to Path, resolved against the URL of the base request of the client, with a
+// body of BodySize bytes, if not 0, and the Headers, replacing those of the base request.
+type MixRequest struct {
+	Method   string            `json:"method" yaml:"method"`
+	Path     string            `json:"path" yaml:"path"`
+	BodySize int64             `json:"body_size" yaml:"body_size"`
+	Headers  map[string]string `json:"headers" yaml:"headers"`
+	Weight   int               `json:"weight" yaml:"weight"`
+}
+
+// mixFile is the file a request mix is read from.
+type mixFile struct {
+	Requests []MixRequest `json:"requests" yaml:"requests"`
+}
+
+// ReadMix reads the requests of a request mix from r, decoded as YAML or, if isJSON
+// is true, JSON, from their requests list. The method of the requests is GET,
+// and their weight 1, if not set.
+func ReadMix(r io.Reader, isJSON bool) ([]MixRequest, error) {
+	var mix mixFile
+	if err := decodeMix(r, isJSON, &mix); err != nil {
+		return nil, fmt.Errorf("failed to decode request mix: %w", err)
+	}
+	if len(mix.Requests) == 0 {
+		return nil, fmt.Errorf("no request found in request mix")
+	}
+	for i := range mix.Requests {
+		m := &mix.Requests[i]
+		if m.Weight < 0 || m.BodySize < 0 {
+			return nil, fmt.Errorf("request %d of request mix has a negative weight or body size", i)
+		}
+		if m.Weight == 0 {
+			m.Weight = 1
+		}
+		if m.Method == "" {
+			m.Method = http.MethodGet
+		}
+		if _, err := url.Parse(m.Path); err != nil {
+			return nil, fmt.Errorf("invalid path of request %d of request mix: %w", i, err)
+		}
+	}
+	return mix.Requests, nil
+}
+
+func decodeMix(r io.Reader, isJSON bool, mix *mixFile) error {
+	if isJSON {
+		return json.NewDecoder(r).Decode(mix)
+	}
+	return yaml.NewDecoder(r).Decode(mix)
+}
+
+// LoadMix reads the requests of the request mix file at path, as [ReadMix],
+// decoded as JSON if its extension is .json, or as YAML otherwise.
+func LoadMix(path string) ([]MixRequest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open request mix: %w", err)
+	}
+	defer f.Close()
+	return ReadMix(f, strings.ToLower(filepath.Ext(path)) == ".json")
+}
+
+// WithMix has the client send the requests of the request mix reqs, e.g. read by [ReadMix],
+// instead of its base request, picking them as selection, SelectRoundRobin or SelectRandom,
+// in proportion to their weights, so a single client benchmarks a realistic mix of reads
+// and writes. Requests with a weight below 1, or whose path is not a valid URL, are never picked.
+//
+// The bodies repeat a block of random bytes, as those of [DoTimeRepeatClient.WithUploadBody].
+// The URL of the request of every completed request is logged with it, as with
+// [DoTimeRepeatClient.WithTargets], so requests to different paths are summarized apart.
+func (c *DoTimeRepeatClient) WithMix(reqs []MixRequest, selection string) *DoTimeRepeatClient {
+	var block []byte
+	p := &targetPicker{random: selection == SelectRandom}
+	var total int
+	for _, m := range reqs {
+		ref, err := url.Parse(m.Path)
+		if m.Weight < 1 || err != nil {
+			continue
+		}
+		req := c.req.Clone(c.req.Context())
+		req.Method = m.Method
+		req.URL = c.req.URL.ResolveReference(ref)
+		req.Host = req.URL.Host
+		for name, value := range m.Headers {
+			req.Header.Set(name, value)
+		}
+		if m.BodySize > 0 {
+			if block == nil {
+				block = make([]byte, 64<<10)
+				rand.Read(block)
+			}
+			n := m.BodySize
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(io.LimitReader(&repeatReader{block: block}, n)), nil
+			}
+			req.Body, _ = req.GetBody()
+			req.ContentLength = n
+		}
+		total += m.Weight
+		p.reqs = append(p.reqs, req)
+		p.cum = append(p.cum, total)
+	}
+	if len(p.reqs) > 0 {
+		c.targets = p
+	}
+	return c
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DoTimeRepeatOpenLoop sends the HTTP request n times, as [DoTimeRepeatClient.DoTimeRepeat],
+// at rps requests per second on a fixed schedule, each request sent at its scheduled time
+// whether or not the requests before it were responded to.
+//
+// Unlike the workers of [DoTimeRepeatClient.DoTimeRepeatConcurrently], which send their
+// next request only once their last one is done and so send fewer requests while the server
+// stalls, under-reporting its latency, the requests of an open loop keep coming. Requests
+// starting late, e.g. as the client runs short of CPU, have their completion logged with
+// how long after their scheduled time they started, so their time can be corrected for
+// coordinated omission. Aggregated request times are corrected when recorded.
+//
+// The requests sent are logged once done with the rate they were sent at, as a rate summary.
+func (c *DoTimeRepeatClient) DoTimeRepeatOpenLoop(ctx context.Context, n int, rps float64, rh ResponseHandler, eh ErrorHandler) (err error) {
+	defer c.summarizeRun(&err)()
+	defer c.summarizeInterruption(ctx)()
+	defer c.logLatencies()
+	defer c.logTimeouts(c.timeouts.Load())
+	start, sent := time.Now(), c.sent.Load()
+	interval := float64(time.Second) / rps
+
+	reqCtx, cancel := c.drainContext(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for i := range n {
+		scheduled := start.Add(time.Duration(float64(i) * interval))
+		t.Reset(time.Until(scheduled))
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+		}
+		if c.breaker.err() != nil {
+			// Returned by the requests which tripped it.
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			break
+		}
+		wg.Go(func() {
+			if err := c.doOnce(reqCtx, scheduled, rh, eh); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	sentN := c.sent.Load() - sent
+	c.logger.Info("rate summary", "target_rps", rps, "achieved_rps", float64(sentN)/elapsed.Seconds(),
+		"requests", sentN, "elapsed_nano", elapsed.Nanoseconds())
+	return errors.Join(errs...)
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestPhases records when the phases of a request, its DNS lookup, connection,
+// TLS handshake and wait for the first byte of the response, start and end, so
+// their durations are logged with its completion instead of reconstructed from
+// the trace events.
+//
+// The methods of a nil *requestPhases do nothing, so requests are sent the same way without them.
+type requestPhases struct {
+	start time.Time
+
+	mu                       sync.Mutex // guards the times set by the trace hooks, called from the dialing goroutines
+	dnsStart, dnsDone        time.Time
+	connectStart, connectEnd time.Time
+	tlsStart, tlsDone        time.Time
+	firstByte                time.Time
+	connReused               bool
+	connIdle                 time.Duration // how long the reused connection was idle for
+	conns                    int           // connections got, more than one once a stale one was retried
+}
+
+// startRequestPhases returns req recording the phases of the request sent at start.
+func startRequestPhases(req *http.Request, start time.Time) (*http.Request, *requestPhases) {
+	p := &requestPhases{start: start}
+	// at sets t to the current time, if it is not set yet.
+	at := func(t *time.Time) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if t.IsZero() {
+			*t = time.Now()
+		}
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { at(&p.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { at(&p.dnsDone) },
+		// Connections may be dialed to several addresses, from
+		// the first dial until the first connection is done.
+		ConnectStart:         func(string, string) { at(&p.connectStart) },
+		ConnectDone:          func(string, string, error) { at(&p.connectEnd) },
+		TLSHandshakeStart:    func() { at(&p.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { at(&p.tlsDone) },
+		GotFirstResponseByte: func() { at(&p.firstByte) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.connReused, p.connIdle = info.Reused, info.IdleTime
+			p.conns++
+		},
+	})), p
+}
+
+// reused reports whether the request was sent over a reused connection.
+func (p *requestPhases) reused() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.connReused
+}
+
+// attrs returns the durations of the phases the request went through as log attributes.
+// Requests sent over reused connections have no DNS lookup, connection or TLS handshake,
+// but how long their connection was idle for. Requests the transport sent again over a
+// new connection, as their idle one was closed by the server, are logged as stale_conn.
+func (p *requestPhases) attrs() []any {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var attrs []any
+	for _, ph := range []struct {
+		field      string
+		start, end time.Time
+	}{
+		{"dns_nano", p.dnsStart, p.dnsDone},
+		{"connect_nano", p.connectStart, p.connectEnd},
+		{"tls_nano", p.tlsStart, p.tlsDone},
+		{"ttfb_nano", p.start, p.firstByte},
+	} {
+		if !ph.start.IsZero() && !ph.end.IsZero() {
+			attrs = append(attrs, ph.field, ph.end.Sub(ph.start).Nanoseconds())
+		}
+	}
+	if p.connReused && p.connIdle > 0 {
+		attrs = append(attrs, "idle_nano", p.connIdle.Nanoseconds())
+	}
+	if p.conns > 1 {
+		attrs = append(attrs, "stale_conn", true)
+	}
+	return attrs
+}
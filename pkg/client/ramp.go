@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Ramp is a schedule of the concurrency of a client, which starts with Start workers
+// and adds Step more of them every Every, up to Max, so the latency of the requests
+// at each concurrency can be compared within a single run.
+//
+// The concurrency of every stage, Max included, is held for Every.
+type Ramp struct {
+	Start int
+	Step  int
+	Max   int
+	Every time.Duration
+}
+
+// stages returns the amount of stages of the ramp, a single one if it does not ramp up.
+func (r Ramp) stages() int {
+	if r.Step <= 0 || r.Max <= r.Start {
+		return 1
+	}
+	return 1 + (r.Max-r.Start+r.Step-1)/r.Step
+}
+
+// workersAt returns the concurrency of the stage i of the ramp.
+func (r Ramp) workersAt(i int) int {
+	return min(r.Start+i*r.Step, max(r.Max, r.Start))
+}
+
+// DoTimeRepeatRamp sends the HTTP request over and over, as [DoTimeRepeatClient.DoTimeRepeat],
+// from as many workers as the stage of the ramp r the client is at, each sending its requests
+// one after the other, until the last stage is over.
+//
+// The completions of the requests are logged with the amount of workers at the time, and
+// every stage is logged once over as "ramp stage", with the requests sent and completed
+// during it and the rate they completed at, so the latency and throughput at every
+// concurrency can be compared. The requests in flight at the end of the ramp are awaited.
+func (c *DoTimeRepeatClient) DoTimeRepeatRamp(ctx context.Context, r Ramp, rh ResponseHandler, eh ErrorHandler) (err error) {
+	defer c.summarizeRun(&err)()
+	defer c.summarizeInterruption(ctx)()
+	defer c.logLatencies()
+	defer c.logTimeouts(c.timeouts.Load())
+	stages := r.stages()
+	start := time.Now()
+	until := start.Add(time.Duration(stages) * r.Every)
+	more := func() bool { return time.Now().Before(until) }
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	workers := 0
+	for i := range stages {
+		stageStart, sent, completed := time.Now(), c.sent.Load(), c.completed.Load()
+		next := r.workersAt(i)
+		c.workers.Store(int64(next))
+		for ; workers < next; workers++ {
+			wg.Go(func() {
+				if err := c.doTimeRepeat(c.workerContext(ctx), more, rh, eh); err != nil {
+					mu.Lock()
+					defer mu.Unlock()
+					errs = append(errs, err)
+				}
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Until(start.Add(time.Duration(i+1) * r.Every))):
+		}
+		elapsed := time.Since(stageStart)
+		completed = c.completed.Load() - completed
+		c.logger.Info("ramp stage", "stage", i+1, "workers", next, "requests", c.sent.Load()-sent,
+			"completed", completed, "elapsed_nano", elapsed.Nanoseconds(),
+			"achieved_rps", float64(completed)/elapsed.Seconds())
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
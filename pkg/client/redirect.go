@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// redirectKey is the context key of the redirects followed by the request a context belongs to.
+type redirectKey struct{}
+
+// redirectHops holds the redirects followed by a request, for them to be logged.
+//
+// The methods of a nil *redirectHops do nothing, so requests are sent the same way without them.
+type redirectHops struct {
+	uuid string
+
+	mu   sync.Mutex
+	last time.Time // when the request, or its last redirect, was sent
+	n    int       // redirects followed
+}
+
+// withRedirectHops returns ctx recording the redirects followed by the request reqUuid, sent at start.
+func withRedirectHops(ctx context.Context, reqUuid string, start time.Time) (context.Context, *redirectHops) {
+	hops := &redirectHops{uuid: reqUuid, last: start}
+	return context.WithValue(ctx, redirectKey{}, hops), hops
+}
+
+// count returns the redirects followed.
+func (h *redirectHops) count() int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.n
+}
+
+// WithRedirects has the client follow at most n redirects of every request, 0 to follow
+// none, handling the last redirect response as the response of the request instead, so the
+// time of the requests to endpoints which redirect them does not silently include that of
+// another request. net/http follows up to 10 of them, and fails the requests redirected more.
+//
+// Every redirect followed is logged as "req redirect", with the number of the hop, the status
+// code of the redirect response, the URLs it redirected from and to, and how long it took
+// since the request, or its last redirect, was sent. The completion of the request is logged
+// with the amount of redirects it followed, if any.
+func (c *DoTimeRepeatClient) WithRedirects(n int) *DoTimeRepeatClient {
+	c.redirects = true
+	c.c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > n {
+			return http.ErrUseLastResponse
+		}
+		hops, ok := req.Context().Value(redirectKey{}).(*redirectHops)
+		if !ok {
+			return nil
+		}
+		hops.mu.Lock()
+		defer hops.mu.Unlock()
+		now := time.Now()
+		elapsed := now.Sub(hops.last)
+		hops.last = now
+		hops.n++
+		c.logger.Info("req redirect", "hop", hops.n, "status_code", req.Response.StatusCode,
+			"from", via[len(via)-1].URL.String(), "to", req.URL.String(),
+			"max_time_nano", elapsed.Nanoseconds(), UuidLogField, hops.uuid)
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources the addresses of the targets of a client are resolved from, logged as dns_source.
+const (
+	// DNSStatic is the static mapping of host names of [DoTimeRepeatClient.WithStaticHosts].
+	DNSStatic = "static"
+	// DNSResolver is the DNS server of [DoTimeRepeatClient.WithResolver].
+	DNSResolver = "resolver"
+	// DNSSystem is the resolver of the system, e.g. the embedded DNS of Docker.
+	DNSSystem = "system"
+	// DNSCache is the cache of [DoTimeRepeatClient.WithDNSCache], whose misses are resolved
+	// by the DNS server of the client, if it has one, or the resolver of the system.
+	DNSCache = "cache"
+)
+
+// Networks the connections of a client can be dialed over, by [DoTimeRepeatClient.WithNetwork].
+const (
+	NetworkIPv4 = "tcp4"
+	NetworkIPv6 = "tcp6"
+)
+
+// dialer returns the dialer the HTTP/1 and HTTP/2 transport of the client dials its
+// connections with, installing it on the transport on its first call. It has the
+// timeouts of the dialer of net/http.
+//
+// The static hosts of the client are dialed to their addresses, and the other hosts
+// to the addresses cached by its DNS cache, if any. The connections are dialed over
+// the network of the client, if set, with the socket options of the client.
+//
+// It is nil if the client has another transport.
+func (c *DoTimeRepeatClient) dialer() *net.Dialer {
+	if c.dial != nil {
+		return c.dial
+	}
+	t, ok := c.c.Transport.(*http.Transport)
+	if !ok {
+		return nil
+	}
+	c.dial = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if c.network != "" {
+			network = c.network
+		}
+		var ips []net.IP
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if ip, ok := c.hosts[host]; ok {
+				addr = net.JoinHostPort(ip, port)
+			} else if c.dnsCache != nil && net.ParseIP(host) == nil {
+				if ips, err = c.dnsCache.lookup(ctx, c.dial.Resolver, network, host); err != nil {
+					return nil, err
+				}
+				addr = port
+			}
+		}
+		conn, err := c.dialAddrs(ctx, network, addr, ips)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.sockOpts.apply(conn); err != nil {
+			return nil, errors.Join(err, conn.Close())
+		}
+		return conn, nil
+	}
+	return c.dial
+}
+
+// dialAddrs dials addr or, if ips is not empty, the port addr at each of ips
+// in their order, until a connection is established.
+func (c *DoTimeRepeatClient) dialAddrs(ctx context.Context, network, addr string, ips []net.IP) (net.Conn, error) {
+	if len(ips) == 0 {
+		return c.dial.DialContext(ctx, network, addr)
+	}
+	var errs error
+	for _, ip := range ips {
+		conn, err := c.dial.DialContext(ctx, network, net.JoinHostPort(ip.String(), addr))
+		if err == nil {
+			return conn, nil
+		}
+		errs = errors.Join(errs, err)
+	}
+	return nil, errs
+}
+
+// dnsCache caches the addresses host names resolved to for ttl.
+type dnsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry // keyed by the network and host name
+}
+
+type dnsEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// lookup returns the addresses host resolves to over network, tcp, tcp4 or tcp6, from the
+// cache, or resolved with r, the default resolver if nil, if not cached or expired.
+func (dc *dnsCache) lookup(ctx context.Context, r *net.Resolver, network, host string) ([]net.IP, error) {
+	key := network + "/" + host
+	dc.mu.Lock()
+	e, ok := dc.entries[key]
+	dc.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.ips, nil
+	}
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	// ip, ip4 or ip6, as the network of the connections.
+	ips, err := r.LookupIP(ctx, "ip"+strings.TrimPrefix(network, "tcp"), host)
+	if err != nil {
+		return nil, err
+	}
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.entries[key] = dnsEntry{ips: ips, expires: time.Now().Add(dc.ttl)}
+	return ips, nil
+}
+
+// WithDNSCache has the client cache the addresses the names of its targets resolved to for
+// ttl, instead of resolving them again for every connection it dials, so benchmarks dialing
+// many connections, e.g. without keep-alive, can compare cached resolution with a lookup
+// for every connection. The lookups of the misses are logged as any other, with their
+// time as the dns_nano of their request, while the requests hitting the cache have none.
+//
+// The addresses are dialed in the order they resolved to, one after the other, without
+// racing the families of addresses (Happy Eyeballs). HTTP/3 connections still resolve the names.
+func (c *DoTimeRepeatClient) WithDNSCache(ttl time.Duration) *DoTimeRepeatClient {
+	if c.dialer() != nil {
+		c.dnsCache = &dnsCache{ttl: ttl, entries: make(map[string]dnsEntry)}
+	}
+	return c
+}
+
+// WithStaticHosts has the client connect to the addresses of hosts, keyed by host name,
+// instead of resolving their names, so the cost of resolving them can be left out of,
+// or compared with, the time of the requests. The requests are still sent to the names,
+// e.g. in their Host header and the server name of their TLS handshakes.
+//
+// HTTP/3 connections still resolve the names.
+func (c *DoTimeRepeatClient) WithStaticHosts(hosts map[string]string) *DoTimeRepeatClient {
+	if c.dialer() != nil {
+		c.hosts = hosts
+	}
+	return c
+}
+
+// WithNetwork has the client dial its connections over network, NetworkIPv4 or NetworkIPv6,
+// only, to the addresses of that family of its targets, instead of either, so the family
+// of the connections does not vary between the runs on dual-stack networks.
+//
+// HTTP/3 connections are still dialed to either family.
+func (c *DoTimeRepeatClient) WithNetwork(network string) *DoTimeRepeatClient {
+	if c.dialer() != nil {
+		c.network = network
+	}
+	return c
+}
+
+// WithoutHappyEyeballs has the client dial the addresses of its targets one after the
+// other, in the order they were resolved, instead of racing a connection to an address of
+// the other family when the first one is slow to connect (Happy Eyeballs, RFC 6555), so
+// the family of the connections does not depend on how fast the first one connected.
+//
+// HTTP/3 connections are not raced.
+func (c *DoTimeRepeatClient) WithoutHappyEyeballs() *DoTimeRepeatClient {
+	if d := c.dialer(); d != nil {
+		d.FallbackDelay = -1
+	}
+	return c
+}
+
+// WithResolver has the client resolve the names of its targets with the DNS server at addr,
+// e.g. 10.0.0.2:53, instead of the resolver of the system, e.g. to bypass the embedded DNS
+// of Docker, which forwards the queries it does not answer itself.
+//
+// HTTP/3 connections still resolve the names with the resolver of the system.
+func (c *DoTimeRepeatClient) WithResolver(addr string) *DoTimeRepeatClient {
+	d := c.dialer()
+	if d == nil {
+		return c
+	}
+	d.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	c.resolver = true
+	return c
+}
+
+// dnsSource returns where the address of host is resolved from, empty
+// if the client resolves names with the resolver of the system alone.
+func (c *DoTimeRepeatClient) dnsSource(host string) string {
+	if _, ok := c.hosts[host]; ok {
+		return DNSStatic
+	}
+	if net.ParseIP(host) != nil {
+		// Addresses are not resolved.
+		return ""
+	}
+	switch {
+	case c.dnsCache != nil:
+		return DNSCache
+	case c.resolver:
+		return DNSResolver
+	case c.hosts != nil:
+		return DNSSystem
+	}
+	return ""
+}
+
+// SocketOptions tune the sockets of the HTTP/1 and HTTP/2 connections of a client, as
+// benchmarks of small responses are sensitive to them. Zero values keep the defaults.
+//
+// EnableNagle has the connections delay sending small segments until the segments sent
+// before are acknowledged (Nagle's algorithm), which Go disables by default (TCP_NODELAY).
+// SendBuffer and ReceiveBuffer are the sizes in bytes of the send and receive buffers of
+// the sockets (SO_SNDBUF and SO_RCVBUF), which the system may round up, e.g. Linux doubles them.
+type SocketOptions struct {
+	EnableNagle   bool
+	SendBuffer    int
+	ReceiveBuffer int
+}
+
+// apply sets the options to the socket of conn, if it is a TCP connection.
+func (o SocketOptions) apply(conn net.Conn) error {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if o.EnableNagle {
+		if err := tc.SetNoDelay(false); err != nil {
+			return fmt.Errorf("failed to enable Nagle's algorithm: %w", err)
+		}
+	}
+	if o.SendBuffer > 0 {
+		if err := tc.SetWriteBuffer(o.SendBuffer); err != nil {
+			return fmt.Errorf("failed to set send buffer size: %w", err)
+		}
+	}
+	if o.ReceiveBuffer > 0 {
+		if err := tc.SetReadBuffer(o.ReceiveBuffer); err != nil {
+			return fmt.Errorf("failed to set receive buffer size: %w", err)
+		}
+	}
+	return nil
+}
+
+// WithSocketOptions sets the options o to the sockets of the connections the client dials.
+//
+// HTTP/3 connections run over the UDP socket of their transport, which ignores them.
+func (c *DoTimeRepeatClient) WithSocketOptions(o SocketOptions) *DoTimeRepeatClient {
+	if c.dialer() != nil {
+		c.sockOpts = o
+	}
+	return c
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Classes of failures a [RetryPolicy] retries the requests on.
+const (
+	// RetryOnError retries requests failed with an error, e.g. a refused or reset connection.
+	RetryOnError = "error"
+	// RetryOnTimeout retries requests that took longer than the request timeout.
+	RetryOnTimeout = "timeout"
+	// RetryOn5xx retries requests the server responded to with a 5xx status code.
+	RetryOn5xx = "5xx"
+)
+
+// RetryPolicy is how the requests of a [DoTimeRepeatClient] are retried.
+//
+// A request is sent at most MaxAttempts times, waiting Backoff before the first
+// retry and twice as long before every next one, up to MaxBackoff if set.
+// On holds the classes of failures retried, RetryOnError, RetryOnTimeout or RetryOn5xx.
+//
+// Only idempotent requests are retried, unless NonIdempotent is true: those whose method
+// is GET, HEAD, OPTIONS, TRACE, PUT or DELETE, or which have an Idempotency-Key header,
+// as a failed POST may well have been processed by the server, and sending it again
+// would process it twice.
+type RetryPolicy struct {
+	MaxAttempts   int
+	Backoff       time.Duration
+	MaxBackoff    time.Duration
+	On            []string
+	NonIdempotent bool
+}
+
+// idempotent reports whether sending the request req more than once has the same effect as once.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	// As the transport, which retries requests with either header on broken connections.
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// retries reports whether the attempt of the request req failed with err, or responded with
+// resp, is retried, as the policy retries its failure and the request, and has attempts left.
+func (p *RetryPolicy) retries(req *http.Request, attempt int, resp *http.Response, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts || !p.NonIdempotent && !idempotent(req) {
+		return false
+	}
+	var class string
+	var timeoutErr *ErrRequestTimeout
+	switch {
+	case errors.As(err, &timeoutErr):
+		class = RetryOnTimeout
+	case errors.Is(err, context.Canceled):
+		// The client is stopping, there is no point in retrying.
+		return false
+	case err != nil:
+		class = RetryOnError
+	case resp.StatusCode >= 500:
+		class = RetryOn5xx
+	default:
+		return false
+	}
+	return slices.Contains(p.On, class)
+}
+
+// backoff returns how long to wait before retrying a request after its attempt failed.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for range attempt - 1 {
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+		d *= 2
+	}
+	if p.MaxBackoff > 0 {
+		d = min(d, p.MaxBackoff)
+	}
+	return d
+}
+
+// WithRetries has the client retry its failed requests according to the policy p.
+//
+// The attempts retried are logged as "req retry", with the number of the attempt,
+// its time and error or status code, and the completion or failure of the request
+// is logged once its last attempt is done, with the time of all its attempts.
+func (c *DoTimeRepeatClient) WithRetries(p RetryPolicy) *DoTimeRepeatClient {
+	c.retry = &p
+	return c
+}
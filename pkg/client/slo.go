@@ -0,0 +1,101 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSLOViolated is returned by the runs of a client which did not meet its
+// objectives, set by [DoTimeRepeatClient.WithSLOs], once they are done.
+type ErrSLOViolated struct {
+	Violations []string
+}
+
+func (e *ErrSLOViolated) Error() string {
+	return "SLOs violated: " + strings.Join(e.Violations, ", ")
+}
+
+// LatencySLO is an objective for the times of the completed requests of a run:
+// their quantile Quantile, e.g. 0.99, must be at most Max.
+type LatencySLO struct {
+	Quantile float64
+	Max      time.Duration
+}
+
+// ParseLatencySLO parses a latency objective written as the percentile, prefixed by p,
+// and the time it must be at most, e.g. p99=20ms or p99.9=50ms.
+func ParseLatencySLO(s string) (LatencySLO, error) {
+	p, d, ok := strings.Cut(s, "=")
+	if !ok || !strings.HasPrefix(p, "p") {
+		return LatencySLO{}, fmt.Errorf("latency SLO %q is not written as p<percentile>=<duration>", s)
+	}
+	percentile, err := strconv.ParseFloat(p[1:], 64)
+	if err != nil || percentile <= 0 || percentile > 100 {
+		return LatencySLO{}, fmt.Errorf("latency SLO %q has no percentile between 0 and 100", s)
+	}
+	limit, err := time.ParseDuration(d)
+	if err != nil {
+		return LatencySLO{}, fmt.Errorf("latency SLO %q has an invalid duration: %w", s, err)
+	}
+	return LatencySLO{Quantile: percentile / 100, Max: limit}, nil
+}
+
+func (o LatencySLO) String() string {
+	return fmt.Sprintf("p%s=%s", strconv.FormatFloat(100*o.Quantile, 'f', -1, 64), o.Max)
+}
+
+// slos are the objectives the runs of a client must meet.
+//
+// The methods of a nil *slos do nothing, so runs succeed the same way without them.
+type slos struct {
+	latencies       []LatencySLO
+	maxErrorPercent float64
+}
+
+// check checks the requests of the run s against the objectives, logging those
+// violated as "slo violation" and returning them as an [ErrSLOViolated].
+func (o *slos) check(s *runStats, c *DoTimeRepeatClient) error {
+	if o == nil {
+		return nil
+	}
+	var violations []string
+	for _, l := range o.latencies {
+		if s.completed.Load() == 0 {
+			break
+		}
+		v := time.Duration(s.times.quantile(l.Quantile))
+		if v <= l.Max {
+			continue
+		}
+		c.logger.Error("slo violation", "slo", l.String(), "actual_nano", v.Nanoseconds())
+		violations = append(violations, fmt.Sprintf("%s took %s", l, v))
+	}
+	if requests := s.requests.Load(); requests > 0 {
+		percent := 100 * float64(s.errors.Load()) / float64(requests)
+		if percent > o.maxErrorPercent {
+			slo := fmt.Sprintf("errors=%g%%", o.maxErrorPercent)
+			c.logger.Error("slo violation", "slo", slo, "actual_percent", percent)
+			violations = append(violations, fmt.Sprintf("%s had %g%%", slo, percent))
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ErrSLOViolated{Violations: violations}
+}
+
+// WithSLOs has the runs of the client check their requests, once done, against the latency
+// objectives latencies and the percent of them which may fail, maxErrorPercent, 100 to not
+// check it, so a run can be told apart as passing or failing, e.g. by the exit status of a
+// benchmark in CI. The objectives violated are logged as "slo violation", and returned by
+// the run as an [ErrSLOViolated], joined with its other errors.
+//
+// The latencies are those of the completed requests, as logged in the run summary, and the
+// failed requests those failed with an error, or whose response handler failed, e.g. with
+// an invalid response.
+func (c *DoTimeRepeatClient) WithSLOs(latencies []LatencySLO, maxErrorPercent float64) *DoTimeRepeatClient {
+	c.slos = &slos{latencies: latencies, maxErrorPercent: maxErrorPercent}
+	return c
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// runStats accumulates the requests of a run of a client, to be logged
+// once the run is done as a single "run summary" line.
+//
+// The methods of a nil *runStats do nothing, so requests are sent the same way without it.
+type runStats struct {
+	start     time.Time
+	requests  atomic.Int64
+	completed atomic.Int64
+	errors    atomic.Int64 // requests failed, or whose response handler failed
+	reused    atomic.Int64 // completed requests sent over reused connections
+	times     histogram    // times of the completed requests, as logged with their completion
+}
+
+// sent counts a request sent.
+func (s *runStats) sent() {
+	if s != nil {
+		s.requests.Add(1)
+	}
+}
+
+// fail counts a request failed without a response.
+func (s *runStats) fail() {
+	if s != nil {
+		s.errors.Add(1)
+	}
+}
+
+// complete counts a completed request which took d, whose response
+// handler failed if failed is true, sent over a reused connection if reused is true.
+func (s *runStats) complete(d time.Duration, failed, reused bool) {
+	if s == nil {
+		return
+	}
+	s.completed.Add(1)
+	if failed {
+		s.errors.Add(1)
+	}
+	if reused {
+		s.reused.Add(1)
+	}
+	s.times.record(d.Nanoseconds())
+}
+
+// summarizeRun starts a run of the client, returning a function logging the requests sent
+// during it as "run summary", once it is done, so downstream tools need not derive them
+// from the lines of every request: the amount of requests sent, completed and with errors,
+// the completed requests sent over reused connections, the minimum, maximum, mean and 95th
+// percentile of their times, as logged with their completion, and the wall time of the run.
+//
+// The requests are then checked against the objectives of the client, if any, joining the
+// objectives violated to the error *errp the run returns.
+func (c *DoTimeRepeatClient) summarizeRun(errp *error) func() {
+	s := &runStats{start: time.Now()}
+	c.run.Store(s)
+	return func() {
+		c.run.CompareAndSwap(s, nil)
+		defer func() {
+			*errp = errors.Join(*errp, c.slos.check(s, c))
+		}()
+		_, minNano, maxNano, meanNano := s.times.summary()
+		c.logger.Info("run summary", "requests", s.requests.Load(), "completed", s.completed.Load(),
+			"errors", s.errors.Load(), "reused", s.reused.Load(),
+			"min_nano", minNano, "max_nano", maxNano, "mean_nano", meanNano, "p95_nano", s.times.quantile(0.95),
+			"elapsed_nano", time.Since(s.start).Nanoseconds())
+	}
+}
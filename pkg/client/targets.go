@@ -0,0 +1,69 @@
+package client
+
+import (
+	mrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync/atomic"
+)
+
+// Ways the targets of [DoTimeRepeatClient.WithTargets] are picked.
+const (
+	// SelectRoundRobin picks the targets in turn, each as many times in a row as its weight.
+	SelectRoundRobin = "round-robin"
+	// SelectRandom picks the targets at random, in proportion to their weights.
+	SelectRandom = "random"
+)
+
+// Target is an endpoint a client sends its requests to, among others,
+// picked in proportion to its Weight among the targets of the client.
+type Target struct {
+	URL    *url.URL
+	Weight int
+}
+
+// targetPicker picks the base request of each request among those of the targets.
+type targetPicker struct {
+	reqs   []*http.Request
+	cum    []int // cumulated weights of the targets, in their order
+	random bool
+	next   atomic.Uint64 // turn of the next request, when picked in turns
+}
+
+// pick returns the base request of the target picked for the next request.
+func (p *targetPicker) pick() *http.Request {
+	total := p.cum[len(p.cum)-1]
+	var n int
+	if p.random {
+		n = mrand.N(total)
+	} else {
+		n = int((p.next.Add(1) - 1) % uint64(total))
+	}
+	return p.reqs[sort.SearchInts(p.cum, n+1)]
+}
+
+// WithTargets has the client send its requests to the targets instead of the URL of its
+// base request, picking them as selection, SelectRoundRobin or SelectRandom, in proportion
+// to their weights, so a single client exercises several routes or response sizes.
+//
+// Targets with a weight below 1 are never picked. The URL of the target of
+// every completed request is logged with it, so they are summarized apart.
+func (c *DoTimeRepeatClient) WithTargets(targets []Target, selection string) *DoTimeRepeatClient {
+	p := &targetPicker{random: selection == SelectRandom}
+	var total int
+	for _, t := range targets {
+		if t.Weight < 1 {
+			continue
+		}
+		req := c.req.Clone(c.req.Context())
+		req.URL, req.Host = t.URL, t.URL.Host
+		total += t.Weight
+		p.reqs = append(p.reqs, req)
+		p.cum = append(p.cum, total)
+	}
+	if len(p.reqs) > 0 {
+		c.targets = p
+	}
+	return c
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName is the name of the tracer recording the spans of the requests.
+	TracerName = "github.com/pessolato/httpmicrobench/pkg/client"
+
+	TraceIDLogField = "trace_id"
+)
+
+// NewTracerProvider creates a tracer provider exporting the spans, in batches,
+// over OTLP/HTTP to the collector at endpointUrl, e.g. http://collector:4318.
+//
+//	serviceName: name of the service the spans are recorded for
+//
+// Spans are queued in memory between exports, so the requests are not slowed down by the
+// exports, and dropped if the queue is full. The provider must be shut down to flush them.
+func NewTracerProvider(ctx context.Context, endpointUrl, serviceName string) (*sdktrace.TracerProvider, error) {
+	exp, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpointUrl))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp,
+			sdktrace.WithMaxQueueSize(1<<16),
+			sdktrace.WithMaxExportBatchSize(4096),
+		),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	), nil
+}
+
+// requestSpans records the span of a request, with child spans of its DNS
+// lookup, connection, TLS handshake and response body.
+//
+// The methods of a nil *requestSpans do nothing, so requests are sent the same way with tracing disabled.
+type requestSpans struct {
+	tracer trace.Tracer
+	ctx    context.Context // context of the request span
+	req    trace.Span
+	body   trace.Span
+
+	mu      sync.Mutex // guards the spans started by the trace hooks, called from the dialing goroutines
+	dns     trace.Span
+	connect map[string]trace.Span // connect spans by address
+	tls     trace.Span
+}
+
+// startRequestSpans starts the span of the request req with tracer and
+// returns a new *http.Request starting its child spans as it is sent.
+func startRequestSpans(tracer trace.Tracer, reqUuid string, req *http.Request) (*http.Request, *requestSpans) {
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(UuidLogField, reqUuid),
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.String()),
+		))
+	s := &requestSpans{tracer: tracer, ctx: ctx, req: span, connect: make(map[string]trace.Span)}
+
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(gci httptrace.GotConnInfo) {
+			span.AddEvent("got conn", trace.WithAttributes(attribute.Bool("reused", gci.Reused)))
+		},
+		GotFirstResponseByte: func() {
+			span.AddEvent("ttfb")
+		},
+		DNSStart: func(di httptrace.DNSStartInfo) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			_, s.dns = tracer.Start(s.ctx, "dns", trace.WithAttributes(attribute.String("host", di.Host)))
+		},
+		DNSDone: func(di httptrace.DNSDoneInfo) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			endSpan(s.dns, di.Err)
+		},
+		ConnectStart: func(network, addr string) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			_, s.connect[addr] = tracer.Start(s.ctx, "connect", trace.WithAttributes(
+				attribute.String("network", network),
+				attribute.String("addr", addr),
+			))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			endSpan(s.connect[addr], err)
+		},
+		TLSHandshakeStart: func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			_, s.tls = tracer.Start(s.ctx, "tls")
+		},
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.tls != nil {
+				s.tls.SetAttributes(attribute.String("server", cs.ServerName), attribute.Bool("resumed", cs.DidResume))
+			}
+			endSpan(s.tls, err)
+		},
+	})
+	return req.WithContext(ctx), s
+}
+
+// responded records the outcome of sending the request,
+// starting the span of the response body if there is one.
+func (s *requestSpans) responded(resp *http.Response, err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.req.RecordError(err)
+		s.req.SetStatus(codes.Error, err.Error())
+		return
+	}
+	s.req.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		s.req.SetStatus(codes.Error, resp.Status)
+	}
+	_, s.body = s.tracer.Start(s.ctx, "body")
+}
+
+// end ends the span of the response body, with the error handling it, if
+// any, and the span of the request, which must not be recorded further.
+func (s *requestSpans) end(bodyErr error) {
+	if s == nil {
+		return
+	}
+	if s.body != nil {
+		endSpan(s.body, bodyErr)
+	}
+	if bodyErr != nil {
+		s.req.SetStatus(codes.Error, bodyErr.Error())
+	}
+	s.req.End()
+}
+
+// traceID returns the ID of the trace of the request, or an empty string if it is not traced.
+func (s *requestSpans) traceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.req.SpanContext().TraceID().String()
+}
+
+// endSpan ends span, if started, recording err as its error, if any.
+func endSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
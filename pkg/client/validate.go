@@ -0,0 +1,85 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ResponseExpectations are what the responses of a misbehaving server would not
+// live up to. StatusCode is the status code expected, 0 to not check it, and
+// BodyLength the length of the body expected, negative to not check it.
+type ResponseExpectations struct {
+	StatusCode int
+	BodyLength int64
+}
+
+// ErrInvalidResponse is the error of a response which did not live up to
+// the expectations checked by a handler of [ValidateResponse].
+type ErrInvalidResponse struct {
+	Problems []string
+}
+
+func (e *ErrInvalidResponse) Error() string {
+	return "invalid response: " + strings.Join(e.Problems, ", ")
+}
+
+// ValidateResponse returns a [ResponseHandler] handling the responses with rh, and
+// checking them against the expectations exp, so a misbehaving server is detected
+// rather than the time of its responses summarized as any other.
+//
+// The Content-Length of the responses is checked, if they have one, along with the
+// length of their bodies, if rh reads them to the end or they end unexpectedly, e.g.
+// as the connection was closed before the whole body was sent. Responses falling short
+// are reported as an [ErrInvalidResponse], which [DoTimeRepeatClient.LogErr] logs apart.
+// The body length of the responses to HEAD requests is not checked, as they have none.
+func ValidateResponse(rh ResponseHandler, exp ResponseExpectations) ResponseHandler {
+	return func(resp *http.Response) error {
+		body := &eofReadCloser{ReadCloser: resp.Body}
+		resp.Body = body
+		err := rh(resp)
+
+		var problems []string
+		if exp.StatusCode != 0 && resp.StatusCode != exp.StatusCode {
+			problems = append(problems, fmt.Sprintf("status code %d, expected %d", resp.StatusCode, exp.StatusCode))
+		}
+		if exp.BodyLength >= 0 && (resp.Request == nil || resp.Request.Method != http.MethodHead) {
+			if resp.ContentLength >= 0 && resp.ContentLength != exp.BodyLength {
+				problems = append(problems, fmt.Sprintf("content length %d, expected %d", resp.ContentLength, exp.BodyLength))
+			}
+			switch {
+			case body.truncated:
+				problems = append(problems, fmt.Sprintf("body truncated after %d bytes, expected %d", body.n, exp.BodyLength))
+			case body.eof && body.n != exp.BodyLength:
+				problems = append(problems, fmt.Sprintf("body length %d, expected %d", body.n, exp.BodyLength))
+			}
+		}
+		if len(problems) > 0 {
+			err = errors.Join(err, &ErrInvalidResponse{Problems: problems})
+		}
+		return err
+	}
+}
+
+// eofReadCloser counts the bytes read from the ReadCloser it wraps,
+// and whether it was read to the end, or ended unexpectedly.
+type eofReadCloser struct {
+	io.ReadCloser
+	n         int64
+	eof       bool
+	truncated bool
+}
+
+func (r *eofReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	switch {
+	case err == io.EOF:
+		r.eof = true
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		r.truncated = true
+	}
+	return n, err
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketClient sends messages over concurrent WebSocket connections and logs their round trip.
+type WebSocketClient struct {
+	url     string       // WebSocket URL of the echo endpoint
+	conns   int          // number of concurrent connections
+	payload []byte       // message sent over the connections
+	logger  *slog.Logger // logger for timing
+}
+
+// NewWebSocketClient creates a new WebSocketClient.
+//
+//	wsUrl: ws:// URL of the echo endpoint
+//	conns: number of concurrent connections to open
+//	payloadLen: size of the random message sent, and echoed back, in every round trip
+//	logger: logger for timing
+func NewWebSocketClient(wsUrl string, conns, payloadLen int, logger *slog.Logger) *WebSocketClient {
+	payload := make([]byte, payloadLen)
+	rand.Read(payload)
+	return &WebSocketClient{wsUrl, conns, payload, logger}
+}
+
+// RoundTripRepeat sends n messages split across the connections, each connection
+// sending its messages one after the other and waiting for them to be echoed.
+//
+// The setup of every connection is logged with its duration, and every message
+// is logged like a request, with its own request UUID and its round trip time.
+// A failure breaks the connection, so it is logged and aborts the messages left on it.
+func (c *WebSocketClient) RoundTripRepeat(ctx context.Context, n int) error {
+	var wg sync.WaitGroup
+	errs := make([]error, c.conns)
+	for i := range c.conns {
+		share := n / c.conns
+		if i < n%c.conns {
+			share++
+		}
+		if share == 0 {
+			continue
+		}
+		wg.Go(func() {
+			errs[i] = c.roundTrips(ctx, i, share)
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// roundTrips opens the connection connId and sends n messages over it.
+func (c *WebSocketClient) roundTrips(ctx context.Context, connId, n int) error {
+	cfg, err := websocket.NewConfig(c.url, "http://localhost/")
+	if err != nil {
+		return fmt.Errorf("invalid WebSocket URL %s: %w", c.url, err)
+	}
+	t1 := time.Now()
+	ws, err := cfg.DialContext(ctx)
+	if err != nil {
+		c.logger.Error("ws connect failed", "error", err, "conn_id", connId)
+		return fmt.Errorf("failed to open WebSocket connection %d: %w", connId, err)
+	}
+	c.logger.Info("ws connected", "conn_id", connId, "connect_time_nano", time.Since(t1).Nanoseconds())
+
+	// Unblock a pending round trip when the context is canceled.
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+	defer ws.Close()
+
+	for range n {
+		reqUuid := rand.Text()
+		var resp []byte
+		t1 := time.Now()
+		err := websocket.Message.Send(ws, c.payload)
+		if err == nil {
+			err = websocket.Message.Receive(ws, &resp)
+		}
+		if err != nil {
+			c.logger.Error("req failed", "error", err, "conn_id", connId, UuidLogField, reqUuid)
+			return fmt.Errorf("WebSocket connection %d failed: %w", connId, err)
+		}
+		c.logger.Info("req completion", "conn_id", connId, "max_time_nano", time.Since(t1).Nanoseconds(), UuidLogField, reqUuid)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// workerClientKey is the context key of the HTTP client of the worker a context belongs to.
+type workerClientKey struct{}
+
+// WithTransportPerWorker has every worker of the client send its requests over a transport of
+// its own, a clone of the transport of the client with a connection pool of its own, instead
+// of the transport shared by all of them, so the contention of the workers on the locks of
+// the shared connection pool can be benchmarked, comparing runs with and without it.
+//
+// Only the HTTP/1 and HTTP/2 transport is cloned, for the workers of
+// [DoTimeRepeatClient.DoTimeRepeatConcurrently], [DoTimeRepeatClient.DoTimeRepeatFor] and
+// [DoTimeRepeatClient.DoTimeRepeatRamp]. The requests of an open loop, which are not sent
+// by workers, share the transport.
+func (c *DoTimeRepeatClient) WithTransportPerWorker() *DoTimeRepeatClient {
+	if _, ok := c.c.Transport.(*http.Transport); ok {
+		c.perWorker = true
+	}
+	return c
+}
+
+// workerContext returns ctx with an HTTP client of its own for the worker it
+// belongs to, if the workers of the client have transports of their own.
+func (c *DoTimeRepeatClient) workerContext(ctx context.Context) context.Context {
+	if !c.perWorker {
+		return ctx
+	}
+	hc := *c.c
+	hc.Transport = c.c.Transport.(*http.Transport).Clone()
+	return context.WithValue(ctx, workerClientKey{}, &hc)
+}
+
+// httpClient returns the HTTP client the requests of ctx are sent with.
+func (c *DoTimeRepeatClient) httpClient(ctx context.Context) *http.Client {
+	if hc, ok := ctx.Value(workerClientKey{}).(*http.Client); ok {
+		return hc
+	}
+	return c.c
+}
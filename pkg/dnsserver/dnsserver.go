@@ -0,0 +1,188 @@
+// Package dnsserver implements a DNS server with controllable TTLs, response
+// latency and record rotation, so the effects of DNS resolution on the latency
+// of the requests can be benchmarked.
+package dnsserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Options configures the DNS server returned by [New].
+type Options struct {
+	// Zone is the domain, e.g. bench.test, whose names are answered.
+	//
+	// Names in the zone are answered with the addresses of the name without
+	// the zone, as resolved by Resolver, e.g. server-0.bench.test with the
+	// addresses of server-0. Queries of other names are refused.
+	Zone string
+	// TTL is the time to live of the records answered.
+	TTL time.Duration
+	// Latency is how long the server waits before responding to each query.
+	Latency time.Duration
+	// Rotate rotates the order of the records of names with several
+	// addresses across responses, as round-robin DNS does.
+	Rotate bool
+	// Resolver resolves the names of the zone, nil uses [net.DefaultResolver].
+	Resolver *net.Resolver
+}
+
+// Server answers DNS queries over UDP.
+type Server struct {
+	opts   Options
+	zone   string // fully qualified zone, with its leading and trailing dots
+	logger *slog.Logger
+	// queries counts the queries served, so the records of each response are rotated.
+	queries atomic.Uint64
+}
+
+// New returns a DNS server answering the queries of the names in the zone of opts.
+//
+// If logger is not nil, a "dns query" entry is written for every query answered
+// with its name, type, amount of records and how long it took to answer.
+func New(opts Options, logger *slog.Logger) (*Server, error) {
+	if opts.Zone == "" {
+		return nil, errors.New("zone of the DNS server must not be empty")
+	}
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	return &Server{opts: opts, zone: "." + strings.Trim(strings.ToLower(opts.Zone), ".") + ".", logger: logger}, nil
+}
+
+// ListenAndServe listens on the UDP network address addr and answers the queries received.
+func (s *Server) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 512)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		query := append([]byte(nil), buf[:n]...)
+		// Each query is answered on its own, so the latency does not delay the others.
+		go func() {
+			resp, err := s.answer(query)
+			if err != nil {
+				if s.logger != nil {
+					s.logger.Error("dns query failed", "error", err)
+				}
+				return
+			}
+			conn.WriteTo(resp, from)
+		}()
+	}
+}
+
+// answer returns the response to the packed DNS message query.
+func (s *Server) answer(query []byte) ([]byte, error) {
+	t1 := time.Now()
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse question: %w", err)
+	}
+
+	rcode, addrs := s.resolve(q)
+	if s.opts.Latency > 0 {
+		time.Sleep(s.opts.Latency)
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 hdr.ID,
+		Response:           true,
+		Authoritative:      rcode != dnsmessage.RCodeRefused,
+		RecursionDesired:   hdr.RecursionDesired,
+		RecursionAvailable: true,
+		RCode:              rcode,
+	})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: uint32(s.opts.TTL.Seconds())}
+	for _, a := range addrs {
+		if a.Is4() {
+			err = b.AResource(rh, dnsmessage.AResource{A: a.As4()})
+		} else {
+			err = b.AAAAResource(rh, dnsmessage.AAAAResource{AAAA: a.As16()})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.logger != nil {
+		s.logger.Info("dns query",
+			"name", q.Name.String(),
+			"type", strings.TrimPrefix(q.Type.String(), "Type"),
+			"rcode", strings.TrimPrefix(rcode.String(), "RCode"),
+			"records", len(addrs),
+			"answer_time_nano", time.Since(t1).Nanoseconds(),
+		)
+	}
+	return resp, nil
+}
+
+// resolve returns the response code and the addresses answering the question q.
+func (s *Server) resolve(q dnsmessage.Question) (dnsmessage.RCode, []netip.Addr) {
+	name, ok := strings.CutSuffix(strings.ToLower(q.Name.String()), s.zone)
+	if !ok || q.Class != dnsmessage.ClassINET {
+		return dnsmessage.RCodeRefused, nil
+	}
+
+	if q.Type != dnsmessage.TypeA && q.Type != dnsmessage.TypeAAAA {
+		// The name may exist, but has no records of other types.
+		return dnsmessage.RCodeSuccess, nil
+	}
+
+	// Both families are looked up, so names without addresses of
+	// the type queried are answered as existing without records.
+	all, err := s.opts.Resolver.LookupNetIP(context.Background(), "ip", name)
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return dnsmessage.RCodeNameError, nil
+	case err != nil:
+		return dnsmessage.RCodeServerFailure, nil
+	}
+	var addrs []netip.Addr
+	for _, a := range all {
+		if a = a.Unmap(); a.Is4() == (q.Type == dnsmessage.TypeA) {
+			addrs = append(addrs, a)
+		}
+	}
+	if s.opts.Rotate && len(addrs) > 1 {
+		n := int(s.queries.Add(1) % uint64(len(addrs)))
+		addrs = append(addrs[n:], addrs[:n]...)
+	}
+	return dnsmessage.RCodeSuccess, addrs
+}
@@ -0,0 +1,105 @@
+// Package grpcecho defines the gRPC echo service shared by the benchmark client and server.
+//
+// Messages are raw bytes exchanged with the [Codec], so the service needs no generated code.
+package grpcecho
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// ServiceName is the full name of the echo service.
+	ServiceName = "httpmicrobench.Echo"
+	// UnaryMethod is the full method name of the unary echo call.
+	UnaryMethod = "/" + ServiceName + "/Unary"
+	// StreamMethod is the full method name of the bidirectional streaming
+	// echo call, which echoes every message received on the stream.
+	StreamMethod = "/" + ServiceName + "/Stream"
+
+	// UuidMetadataKey is the metadata key the request UUID of unary calls is sent with.
+	UuidMetadataKey = "req-uuid"
+)
+
+// CodecName is the content subtype of the [Codec], set with [grpc.CallContentSubtype].
+const CodecName = "raw"
+
+func init() {
+	encoding.RegisterCodec(Codec{})
+}
+
+// Codec marshals messages that are pointers to byte slices as they are.
+type Codec struct{}
+
+func (Codec) Marshal(v any) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return *b, nil
+}
+
+func (Codec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (Codec) Name() string {
+	return CodecName
+}
+
+// Server is the implementation of the echo service.
+type Server interface {
+	// Unary handles a unary call, returning the response message.
+	Unary(ctx context.Context, msg []byte) ([]byte, error)
+	// Stream handles a streaming call.
+	Stream(stream grpc.ServerStream) error
+}
+
+// Register registers srv as the echo service of s.
+func Register(s *grpc.Server, srv Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+// StreamDesc describes the streaming call for [grpc.ClientConn.NewStream].
+var StreamDesc = grpc.StreamDesc{
+	StreamName:    "Stream",
+	Handler:       streamHandler,
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Unary", Handler: unaryHandler},
+	},
+	Streams: []grpc.StreamDesc{StreamDesc},
+}
+
+func unaryHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	var in []byte
+	if err := dec(&in); err != nil {
+		return nil, err
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		out, err := srv.(Server).Unary(ctx, *req.(*[]byte))
+		return &out, err
+	}
+	if interceptor == nil {
+		return handler(ctx, &in)
+	}
+	return interceptor(ctx, &in, &grpc.UnaryServerInfo{Server: srv, FullMethod: UnaryMethod}, handler)
+}
+
+func streamHandler(srv any, stream grpc.ServerStream) error {
+	return srv.(Server).Stream(stream)
+}
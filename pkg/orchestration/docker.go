@@ -1,11 +1,21 @@
 package orchestration
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
+	"time"
 
 	"github.com/pessolato/httpmicrobench/pkg/osutil"
 
@@ -32,6 +42,11 @@ func NewDockerOrchestrator() (*DockerOrchestrator, error) {
 	return &DockerOrchestrator{c: c}, nil
 }
 
+// Close releases the resources of the underlying Docker client.
+func (o *DockerOrchestrator) Close() error {
+	return o.c.Close()
+}
+
 // WithPreRunStep sets the pre-run steps.
 //
 // Failures during pre-run steps halt the process
@@ -52,6 +67,7 @@ func (o *DockerOrchestrator) WithRunStep(steps ...RunStep) *DockerOrchestrator {
 // WithPosRunStep sets the post-run steps.
 //
 // Failures during post-run steps halt the process.
+// Post-run steps are not canceled with the context of [DockerOrchestrator.Run].
 func (o *DockerOrchestrator) WithPosRunStep(steps ...RunStep) *DockerOrchestrator {
 	o.pos = append(o.pos, steps...)
 	return o
@@ -72,8 +88,11 @@ func (o *DockerOrchestrator) Run(ctx context.Context) error {
 		}
 	}
 
+	// Post-run steps clean up after the run, so they
+	// still run when the run itself was canceled.
+	posCtx := context.WithoutCancel(ctx)
 	for _, s := range o.pos {
-		if err := s(ctx, o.c); err != nil {
+		if err := s(posCtx, o.c); err != nil {
 			runErr = errors.Join(fmt.Errorf("failed running pos run step: %w", err), runErr)
 			break
 		}
@@ -83,20 +102,35 @@ func (o *DockerOrchestrator) Run(ctx context.Context) error {
 }
 
 type Container struct {
-	Name     string
-	Config   container.Config
-	Network  network.NetworkingConfig
-	LogSink  io.WriteCloser
-	StatSink io.WriteCloser
+	Name   string
+	Config container.Config
+	// HostConfig holds the non-portable configuration
+	// of the container, e.g. sysctls and capabilities.
+	HostConfig container.HostConfig
+	Network    network.NetworkingConfig
+	LogSink    io.WriteCloser
+	StatSink   io.WriteCloser
+	// MetricsPort, if set, is the port, e.g. 6060/tcp, published to the host
+	// of an HTTP endpoint at MetricsPath whose responses are sampled by
+	// [ContainerMetricsStep] into MetricsSink.
+	MetricsPort string
+	MetricsPath string
+	MetricsSink io.WriteCloser
+	// CopyOut maps paths of files in the container to the paths
+	// on the host [ContainerCopyOutStep] copies them to.
+	CopyOut map[string]string
 	// ID is usually used as a read-only field which
 	// is populated when a create step is executed.
 	ID string
+	// IPAddress is a read-only field populated
+	// when an address step is executed.
+	IPAddress string
 }
 
 func ContainerCreateStep(specs ...*Container) RunStep {
 	return func(ctx context.Context, c *client.Client) error {
 		for _, s := range specs {
-			resp, err := c.ContainerCreate(ctx, &s.Config, nil, &s.Network, nil, s.Name)
+			resp, err := c.ContainerCreate(ctx, &s.Config, &s.HostConfig, &s.Network, nil, s.Name)
 			if err != nil {
 				return fmt.Errorf("failed to create %s container: %w", s.Name, err)
 			}
@@ -117,6 +151,28 @@ func ContainerStartStep(specs ...*Container) RunStep {
 	}
 }
 
+// ContainerAddressStep returns a RunStep that populates the IPAddress
+// of the started containers with their address in the network netName.
+func ContainerAddressStep(netName string, specs ...*Container) RunStep {
+	return func(ctx context.Context, c *client.Client) error {
+		for _, s := range specs {
+			resp, err := c.ContainerInspect(ctx, s.ID)
+			if err != nil {
+				return fmt.Errorf("failed to inspect %s container: %w", s.Name, err)
+			}
+			var ep *network.EndpointSettings
+			if resp.NetworkSettings != nil {
+				ep = resp.NetworkSettings.Networks[netName]
+			}
+			if ep == nil || ep.IPAddress == "" {
+				return fmt.Errorf("%s container has no address in %s network", s.Name, netName)
+			}
+			s.IPAddress = ep.IPAddress
+		}
+		return nil
+	}
+}
+
 // ContainerLogStep returns a RunStep that copies the container logs
 // to the provided log sinks concurrently in the background.
 //
@@ -182,32 +238,105 @@ func ContainerStreamStatStep(errLogSink io.Writer, specs ...*Container) RunStep
 	}
 }
 
+// ContainerWaitStep returns a RunStep that waits for the containers to stop running.
+//
+// Fails as soon as a container exits with a non-zero status, e.g. a client aborting
+// a broken run, without waiting for the other containers.
 func ContainerWaitStep(errLogSink io.Writer, specs ...*Container) RunStep {
 	return func(ctx context.Context, c *client.Client) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var mu sync.Mutex
+		var exitErr error // of the first container which exited with a non-zero status
 		var wg sync.WaitGroup
 		for _, s := range specs {
 			stsCh, errCh := c.ContainerWait(ctx, s.ID, container.WaitConditionNotRunning)
 			wg.Add(1)
-			go func(stsCh <-chan container.WaitResponse, errCh <-chan error) {
+			go func(name string, stsCh <-chan container.WaitResponse, errCh <-chan error) {
 				defer wg.Done()
 				select {
 				case err := <-errCh:
-					if err != nil {
+					mu.Lock()
+					// The waits canceled as a container failed are not worth logging.
+					failed := exitErr != nil
+					mu.Unlock()
+					if err != nil && !failed {
 						fmt.Fprintln(errLogSink, err)
 					}
-				case <-stsCh:
+				case sts := <-stsCh:
+					if sts.StatusCode != 0 {
+						mu.Lock()
+						if exitErr == nil {
+							exitErr = fmt.Errorf("%s container exited with status %d", name, sts.StatusCode)
+						}
+						mu.Unlock()
+						cancel()
+					}
 				}
-			}(stsCh, errCh)
+			}(s.Name, stsCh, errCh)
 		}
 
 		wg.Wait()
+		return exitErr
+	}
+}
+
+// ContainerHealthyStep returns a RunStep that waits for the started containers
+// with a health check to become healthy, polling their state every interval.
+//
+// Fails if a container becomes unhealthy, stops running or is not healthy within timeout.
+func ContainerHealthyStep(timeout, interval time.Duration, specs ...*Container) RunStep {
+	return func(ctx context.Context, c *client.Client) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		for _, s := range specs {
+			if s.Config.Healthcheck == nil {
+				continue
+			}
+			if err := waitHealthy(ctx, c, s, interval); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 }
 
+// waitHealthy polls the state of the container s every interval until it is healthy.
+func waitHealthy(ctx context.Context, c *client.Client, s *Container, interval time.Duration) error {
+	for {
+		resp, err := c.ContainerInspect(ctx, s.ID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s container: %w", s.Name, err)
+		}
+		if resp.State == nil || !resp.State.Running {
+			return fmt.Errorf("%s container stopped before becoming healthy", s.Name)
+		}
+		if resp.State.Health != nil {
+			switch resp.State.Health.Status {
+			case container.Healthy:
+				return nil
+			case container.Unhealthy:
+				return fmt.Errorf("%s container is unhealthy", s.Name)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s container is not healthy: %w", s.Name, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// ContainerStopStep returns a RunStep that stops the containers.
+//
+// Containers never created, e.g. when the run failed before, are skipped.
 func ContainerStopStep(specs ...*Container) RunStep {
 	return func(ctx context.Context, c *client.Client) error {
 		for _, s := range specs {
+			if s == nil || s.ID == "" {
+				continue
+			}
 			err := c.ContainerStop(ctx, s.ID, client.ContainerStopOptions{})
 			if err != nil {
 				return fmt.Errorf("failed to stop %s container: %w", s.Name, err)
@@ -217,9 +346,32 @@ func ContainerStopStep(specs ...*Container) RunStep {
 	}
 }
 
+// ContainerKillStep returns a RunStep that sends signal, e.g. SIGKILL, to the containers.
+//
+// Containers never created are skipped.
+func ContainerKillStep(signal string, specs ...*Container) RunStep {
+	return func(ctx context.Context, c *client.Client) error {
+		for _, s := range specs {
+			if s == nil || s.ID == "" {
+				continue
+			}
+			if err := c.ContainerKill(ctx, s.ID, signal); err != nil {
+				return fmt.Errorf("failed to kill %s container: %w", s.Name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// ContainerRemoveStep returns a RunStep that removes the containers.
+//
+// Containers never created are skipped.
 func ContainerRemoveStep(specs ...*Container) RunStep {
 	return func(ctx context.Context, c *client.Client) error {
 		for _, s := range specs {
+			if s == nil || s.ID == "" {
+				continue
+			}
 			err := c.ContainerRemove(ctx, s.ID, client.ContainerRemoveOptions{})
 			if err != nil {
 				return fmt.Errorf("failed to remove %s container: %w", s.Name, err)
@@ -229,18 +381,197 @@ func ContainerRemoveStep(specs ...*Container) RunStep {
 	}
 }
 
+// ContainerMetricsStep returns a RunStep that samples the metrics endpoints of
+// the started containers every interval concurrently in the background.
+//
+// Only Containers with a non-nil MetricsSink are sampled. The sampling of
+// a container ends once its endpoint fails after having responded, e.g.
+// because the container stopped, or if it never responds in 30 attempts.
+func ContainerMetricsStep(errLogSink io.Writer, interval time.Duration, specs ...*Container) RunStep {
+	return func(ctx context.Context, c *client.Client) error {
+		for _, s := range specs {
+			if s.MetricsSink == nil {
+				continue
+			}
+
+			resp, err := c.ContainerInspect(ctx, s.ID)
+			if err != nil {
+				return fmt.Errorf("failed to inspect %s container: %w", s.Name, err)
+			}
+			var bindings []container.PortBinding
+			if resp.NetworkSettings != nil {
+				bindings = resp.NetworkSettings.Ports[container.PortRangeProto(s.MetricsPort)]
+			}
+			if len(bindings) == 0 && (resp.State == nil || !resp.State.Running) {
+				// Ports are not published anymore once the container stopped.
+				s.MetricsSink.Close()
+				continue
+			}
+			if len(bindings) == 0 {
+				return fmt.Errorf("metrics port %s of %s container is not published", s.MetricsPort, s.Name)
+			}
+			host := bindings[0].HostIP
+			if host == "" || host == "0.0.0.0" || host == "::" {
+				host = "127.0.0.1"
+			}
+			url := "http://" + net.JoinHostPort(host, bindings[0].HostPort) + s.MetricsPath
+
+			go func(cnt *Container) {
+				err := sampleMetrics(ctx, url, interval, cnt.MetricsSink)
+				err = errors.Join(err, cnt.MetricsSink.Close())
+				if err != nil {
+					fmt.Fprintln(errLogSink, fmt.Errorf("failed to sample %s container metrics or close sinks: %w", cnt.Name, err))
+				}
+			}(s)
+		}
+		return nil
+	}
+}
+
+// sampleMetrics writes the responses of the endpoint at url to sink every interval.
+func sampleMetrics(ctx context.Context, url string, interval time.Duration, sink io.Writer) error {
+	hc := &http.Client{Timeout: interval}
+	responded, failures := false, 0
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		resp, err := hc.Get(url)
+		if err != nil {
+			failures++
+			if responded {
+				// The container stopped.
+				return nil
+			}
+			if failures >= 30 {
+				return fmt.Errorf("metrics endpoint never responded: %w", err)
+			}
+			continue
+		}
+		responded = true
+		_, err = io.Copy(sink, resp.Body)
+		if err = errors.Join(err, resp.Body.Close()); err != nil {
+			return err
+		}
+	}
+}
+
+// ContainerCopyOutStep returns a RunStep that copies the CopyOut
+// files of the containers, which may be stopped, to the host.
+//
+// Containers never created are skipped.
+func ContainerCopyOutStep(specs ...*Container) RunStep {
+	return func(ctx context.Context, c *client.Client) error {
+		for _, s := range specs {
+			if s == nil || s.ID == "" {
+				continue
+			}
+			for src, dest := range s.CopyOut {
+				if err := copyOut(ctx, c, s.ID, src, dest); err != nil {
+					return fmt.Errorf("failed to copy %s out of %s container: %w", src, s.Name, err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// copyOut copies the regular file src out of the container id to dest.
+func copyOut(ctx context.Context, c *client.Client, id, src, dest string) error {
+	rc, _, err := c.CopyFromContainer(ctx, id, src)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// The file is archived as the single entry of a tar stream.
+	tr := tar.NewReader(rc)
+	if _, err := tr.Next(); err != nil {
+		return fmt.Errorf("failed reading archive: %w", err)
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, tr)
+	return errors.Join(err, f.Close())
+}
+
 func EnsureContainerSinkCloseStep(specs ...*Container) RunStep {
 	return func(ctx context.Context, c *client.Client) error {
 		for _, s := range specs {
+			if s == nil {
+				continue
+			}
 			if s.LogSink != nil {
 				s.LogSink.Close()
 			}
 			if s.StatSink != nil {
 				s.StatSink.Close()
 			}
+			if s.MetricsSink != nil {
+				s.MetricsSink.Close()
+			}
+		}
+		return nil
+	}
+}
+
+// SleepStep returns a RunStep that waits for d, failing if canceled before.
+func SleepStep(d time.Duration) RunStep {
+	return func(ctx context.Context, c *client.Client) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+			return nil
+		}
+	}
+}
+
+// DelayedStep returns a RunStep, start, that runs steps in order in the background
+// once delay has passed, and another, stop, that cancels them and waits for them
+// to return, so they do not outlive the phase they are scheduled in.
+//
+// The first failing step stops the others and its error is written to errLogSink.
+// Stopping steps that were never started does nothing.
+func DelayedStep(errLogSink io.Writer, delay time.Duration, steps ...RunStep) (start, stop RunStep) {
+	var cancel context.CancelFunc
+	var done chan struct{}
+	start = func(ctx context.Context, c *client.Client) error {
+		ctx, cancel = context.WithCancel(ctx)
+		done = make(chan struct{})
+		go func() {
+			defer close(done)
+			for _, s := range append([]RunStep{SleepStep(delay)}, steps...) {
+				if err := s(ctx, c); err != nil {
+					if ctx.Err() == nil {
+						fmt.Fprintln(errLogSink, fmt.Errorf("failed running delayed step: %w", err))
+					}
+					return
+				}
+			}
+		}()
+		return nil
+	}
+	stop = func(ctx context.Context, c *client.Client) error {
+		if done == nil {
+			return nil
+		}
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 		return nil
 	}
+	return start, stop
 }
 
 type Network struct {
@@ -281,37 +612,219 @@ func EnsureNetworkStep(specs ...*Network) RunStep {
 
 type GoBuild struct {
 	PkgPath, Dest string
+	Opts          osutil.GoBuildOpts
 	BuildCtxSpecs []osutil.BuildCtxSpec
-	// ArtifactStore is used to store the context once the build is complete.
+	// ArtifactStore, if set, is used to store the context once the build is complete.
+	//
+	// Prefer [GoBuild.Context] to stream the context without holding it in memory.
 	ArtifactStore io.Writer
+	// CacheDir, if set, is where build contexts are cached keyed by
+	// the hash of their sources, so unchanged packages are not rebuilt.
+	CacheDir string
+
+	// GoVersion, BinarySHA256 and ContextSHA256 are populated with the version
+	// of the go toolchain and the hex encoded SHA-256 checksums of the built
+	// binary and its build context when a build step is executed.
+	GoVersion, BinarySHA256, ContextSHA256 string
+
+	// cached is the path of the cached context of the last build, if any.
+	cached string
 }
 
+// Context opens the build context of the package, which
+// must be called after the [GoBuildStep] building it.
+//
+// The context is read from the cache when caching is enabled and is
+// streamed from the built binary otherwise. It must be closed after use.
+func (s *GoBuild) Context() (io.ReadCloser, error) {
+	if s.cached != "" {
+		return os.Open(s.cached)
+	}
+	return osutil.BuildCtx(s.BuildCtxSpecs...)
+}
+
+// GoBuildStep returns a RunStep that builds the specs concurrently,
+// running at most GOMAXPROCS builds at a time.
+//
+// All builds run to completion and their errors are joined.
 func GoBuildStep(specs ...*GoBuild) RunStep {
 	return func(ctx context.Context, c *client.Client) error {
-		for _, s := range specs {
-			err := osutil.BuildGo(s.Dest, s.PkgPath)
-			if err != nil {
-				return fmt.Errorf("failed building %s package: %w", s.PkgPath, err)
-			}
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+		errs := make([]error, len(specs))
+		for i, s := range specs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				errs[i] = s.build(ctx)
+			}()
+		}
 
-			r, err := osutil.BuildCtx(s.BuildCtxSpecs...)
-			if err != nil {
-				return fmt.Errorf("failed building artifacts for %s package: %w", s.PkgPath, err)
-			}
+		wg.Wait()
+		return errors.Join(errs...)
+	}
+}
 
-			_, err = io.Copy(s.ArtifactStore, r)
-			if err != nil {
-				return fmt.Errorf("failed storing artifacts for %s package: %w", s.PkgPath, err)
+// build builds the package, unless its context is cached, and stores the context.
+func (s *GoBuild) build(ctx context.Context) error {
+	s.cached = ""
+	version, err := osutil.GoVersion(ctx, s.Opts)
+	if err != nil {
+		return fmt.Errorf("failed getting toolchain version for %s package: %w", s.PkgPath, err)
+	}
+	s.GoVersion = version
+
+	var cachePath string
+	if s.CacheDir != "" {
+		cachePath, err = s.cachePath(ctx)
+		if err != nil {
+			return fmt.Errorf("failed hashing sources of %s package: %w", s.PkgPath, err)
+		}
+	}
+
+	if _, err := os.Stat(cachePath); cachePath == "" || err != nil {
+		err := osutil.BuildGo(ctx, s.Dest, s.PkgPath, s.Opts)
+		if err != nil {
+			return fmt.Errorf("failed building %s package: %w", s.PkgPath, err)
+		}
+
+		if cachePath != "" {
+			if err := storeCached(cachePath, s.BuildCtxSpecs); err != nil {
+				return fmt.Errorf("failed caching artifacts for %s package: %w", s.PkgPath, err)
 			}
 		}
+	}
+	s.cached = cachePath
+
+	if err := s.checksum(); err != nil {
+		return fmt.Errorf("failed computing checksums for %s package: %w", s.PkgPath, err)
+	}
+
+	if s.ArtifactStore == nil {
 		return nil
 	}
+	r, err := s.Context()
+	if err != nil {
+		return fmt.Errorf("failed building artifacts for %s package: %w", s.PkgPath, err)
+	}
+	_, err = io.Copy(s.ArtifactStore, r)
+	if err = errors.Join(err, r.Close()); err != nil {
+		return fmt.Errorf("failed storing artifacts for %s package: %w", s.PkgPath, err)
+	}
+	return nil
+}
+
+// checksum computes the checksums of the build context and of
+// the binary archived in it, whether it was built or cached.
+func (s *GoBuild) checksum() error {
+	var binName string
+	for _, spec := range s.BuildCtxSpecs {
+		if spec.PathTo == s.Dest {
+			binName = spec.FineName
+		}
+	}
+
+	r, err := s.Context()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ctxHash := sha256.New()
+	gz, err := gzip.NewReader(io.TeeReader(r, ctxHash))
+	if err != nil {
+		return err
+	}
+	binHash := sha256.New()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Name == binName {
+			if _, err := io.Copy(binHash, tr); err != nil {
+				return err
+			}
+		}
+	}
+	// Consume the gzip trailer, so the context hash covers the whole stream.
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return err
+	}
+	if _, err := io.Copy(ctxHash, r); err != nil {
+		return err
+	}
+
+	s.ContextSHA256 = hex.EncodeToString(ctxHash.Sum(nil))
+	s.BinarySHA256 = hex.EncodeToString(binHash.Sum(nil))
+	return nil
+}
+
+// cachePath returns the path the build context is cached at, which
+// changes whenever the package sources or the other context files change.
+func (s *GoBuild) cachePath(ctx context.Context) (string, error) {
+	var extra []string
+	for _, spec := range s.BuildCtxSpecs {
+		if spec.Content == nil && spec.PathTo != s.Dest {
+			extra = append(extra, spec.PathTo)
+		}
+	}
+	hash, err := osutil.HashGoSources(ctx, s.PkgPath, s.Opts, extra...)
+	if err != nil {
+		return "", err
+	}
+
+	// In-memory entries are not files, so they are hashed here along with the entry names.
+	h := sha256.New()
+	fmt.Fprintln(h, hash)
+	for _, spec := range s.BuildCtxSpecs {
+		fmt.Fprintf(h, "%s %o %q\n", spec.FineName, spec.Mode, spec.Content)
+	}
+	return filepath.Join(s.CacheDir, hex.EncodeToString(h.Sum(nil))+".tar.gz"), nil
+}
+
+// storeCached writes the build context of specs to the cache at path.
+//
+// The context is written to a temporary file first, so interrupted
+// runs do not leave partial contexts in the cache.
+func storeCached(path string, specs []osutil.BuildCtxSpec) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	r, err := osutil.BuildCtx(specs...)
+	if err != nil {
+		return errors.Join(err, tmp.Close())
+	}
+	_, err = io.Copy(tmp, r)
+	if err = errors.Join(err, r.Close(), tmp.Close()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 type Image struct {
-	Tag      string
-	Rebuild  bool
+	Tag     string
+	Rebuild bool
+	// Platform is the os/arch the image is built for, the daemon platform is used when empty.
+	Platform string
 	BuildCtx io.Reader
+	// Pull, if set, pulls the image from its registry instead of building it.
+	Pull bool
+	// OpenBuildCtx, if set, is used instead of BuildCtx to open the
+	// context only when the image is built. The context is closed after.
+	OpenBuildCtx func() (io.ReadCloser, error)
 }
 
 func EnsureImageStep(specs ...*Image) RunStep {
@@ -328,8 +841,13 @@ func EnsureImageStep(specs ...*Image) RunStep {
 		tags := imageTagSet(res)
 		for _, s := range specs {
 			if _, ok := tags[s.Tag]; !ok || s.Rebuild {
-				resp, err := c.ImageBuild(ctx, s.BuildCtx, client.ImageBuildOptions{Tags: []string{s.Tag}, Remove: true})
-				if err := osutil.DrainCloseErr(resp.Body, err); err != nil {
+				if s.Pull {
+					if err := pullImage(ctx, c, s); err != nil {
+						return fmt.Errorf("failed pulling image %s: %w", s.Tag, err)
+					}
+					continue
+				}
+				if err := buildImage(ctx, c, s); err != nil {
 					return fmt.Errorf("failed building image %s: %w", s.Tag, err)
 				}
 			}
@@ -339,6 +857,32 @@ func EnsureImageStep(specs ...*Image) RunStep {
 	}
 }
 
+func buildImage(ctx context.Context, c *client.Client, s *Image) error {
+	buildCtx := s.BuildCtx
+	if s.OpenBuildCtx != nil {
+		rc, err := s.OpenBuildCtx()
+		if err != nil {
+			return fmt.Errorf("failed opening build context: %w", err)
+		}
+		defer rc.Close()
+		buildCtx = rc
+	}
+
+	resp, err := c.ImageBuild(ctx, buildCtx, client.ImageBuildOptions{Tags: []string{s.Tag}, Remove: true, Platform: s.Platform})
+	if err != nil {
+		return err
+	}
+	return osutil.DrainCloseErr(resp.Body, nil)
+}
+
+func pullImage(ctx context.Context, c *client.Client, s *Image) error {
+	resp, err := c.ImagePull(ctx, s.Tag, client.ImagePullOptions{Platform: s.Platform})
+	if err != nil {
+		return err
+	}
+	return osutil.DrainCloseErr(resp, nil)
+}
+
 func imageTagSet(imgs []image.Summary) map[string]struct{} {
 	tags := make(map[string]struct{})
 	for _, i := range imgs {
@@ -4,81 +4,405 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 )
 
+// BuildCtxSpec describes an entry of a build context.
+//
+// PathTo may point to a regular file, a directory, which is archived
+// recursively, or a symbolic link, which is preserved. If Content is
+// not nil, it is archived as a regular file instead of reading PathTo.
 type BuildCtxSpec struct {
 	FineName string
 	PathTo   string
 	Mode     int64
+	Content  []byte
 }
 
-func BuildGo(dest, mod string) error {
-	cmd := exec.Command("go", "build", "-o", dest, mod)
-	cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
-	out, err := cmd.Output()
+// GoBuildOpts holds the options passed to go build by [BuildGo].
+type GoBuildOpts struct {
+	// Tags are the build tags, passed with -tags.
+	Tags []string
+	// LDFlags are passed with -ldflags, e.g. to embed version information with -X.
+	LDFlags string
+	// GOOS and GOARCH select the target platform, the host platform is used when empty.
+	GOOS, GOARCH string
+	// TrimPath removes file system paths from the binary for reproducible builds.
+	TrimPath bool
+	// Timeout, if set, limits how long a build can take.
+	Timeout time.Duration
+	// Toolchain, if set, selects the go toolchain through GOTOOLCHAIN,
+	// e.g. go1.25.1, which is downloaded by the go command when missing.
+	// It cannot be older than the go version required by go.mod.
+	Toolchain string
+	// GoBin, if set, is the go command used instead of go, e.g. a
+	// go1.25.1 wrapper installed from golang.org/dl.
+	GoBin string
+}
+
+// goBin returns the go command used for the options.
+func (o GoBuildOpts) goBin() string {
+	if o.GoBin != "" {
+		return o.GoBin
+	}
+	return "go"
+}
+
+// args returns the go build arguments for the options.
+func (o GoBuildOpts) args() []string {
+	var args []string
+	if len(o.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(o.Tags, ","))
+	}
+	if o.LDFlags != "" {
+		args = append(args, "-ldflags", o.LDFlags)
+	}
+	if o.TrimPath {
+		args = append(args, "-trimpath")
+	}
+	return args
+}
+
+// env returns the environment variables for the options.
+func (o GoBuildOpts) env() []string {
+	env := []string{"CGO_ENABLED=0"}
+	if o.GOOS != "" {
+		env = append(env, "GOOS="+o.GOOS)
+	}
+	if o.GOARCH != "" {
+		env = append(env, "GOARCH="+o.GOARCH)
+	}
+	if o.Toolchain != "" {
+		env = append(env, "GOTOOLCHAIN="+o.Toolchain)
+	}
+	return env
+}
+
+// BuildGo builds the package mod into dest with the toolchain version reported by [GoVersion].
+//
+// The build is killed when ctx is done or, if set, when the timeout of opts expires.
+// The standard error of the toolchain is included in the returned error.
+func BuildGo(ctx context.Context, dest, mod string, opts GoBuildOpts) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	version, err := GoVersion(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("error to build %s with output %s and error: %w", mod, out, err)
+		return err
+	}
+
+	args := append([]string{"build", "-o", dest}, opts.args()...)
+	cmd := exec.CommandContext(ctx, opts.goBin(), append(args, mod)...)
+	cmd.Env = append(os.Environ(), opts.env()...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error to build %s with %s, stdout %q, stderr %q and error: %w", mod, version, stdout.Bytes(), stderr.Bytes(), errors.Join(err, ctx.Err()))
 	}
 	return nil
 }
 
-func BuildCtx(specs ...BuildCtxSpec) (io.Reader, error) {
+// GoVersion returns the version of the go toolchain used to build with opts, e.g. go1.25.1.
+func GoVersion(ctx context.Context, opts GoBuildOpts) (string, error) {
+	cmd := exec.CommandContext(ctx, opts.goBin(), "env", "GOVERSION")
+	cmd.Env = append(os.Environ(), opts.env()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error to get go version with stderr %q and error: %w", stderr.Bytes(), err)
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// BuildCtx streams a gzip compressed tar context with the files in specs.
+//
+// The context is written as it is read, so errors archiving the files are
+// returned by Read. The returned reader must be closed to release its resources.
+func BuildCtx(specs ...BuildCtxSpec) (io.ReadCloser, error) {
 	if len(specs) < 1 {
 		return nil, fmt.Errorf("cannot build context with no context specification")
 	}
 
-	var buf bytes.Buffer
-	gzw := gzip.NewWriter(&buf)
-	tw := tar.NewWriter(gzw)
+	return tarGz(func(tw *tar.Writer) error {
+		for _, s := range specs {
+			var err error
+			if s.Content != nil {
+				err = BytesToTar(s.FineName, s.Content, s.Mode, tw)
+			} else {
+				err = FileToTar(s.FineName, s.PathTo, s.Mode, tw)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
-	for _, s := range specs {
-		err := FileToTar(s.FineName, s.PathTo, s.Mode, tw)
-		if err != nil {
-			return nil, err
+// BuildCtxFromDir builds a context with the files and symbolic links under dir,
+// named by their path relative to dir and keeping their permissions.
+//
+// Patterns follow [path.Match] and are matched against both the slash separated
+// relative path and the base name of each entry. A file is added if it matches
+// any of the includes, or if includes is empty, and it does not match any of
+// the excludes. Excluded directories are skipped entirely.
+//
+// Like [BuildCtx], the context is streamed and the returned reader must be closed.
+func BuildCtxFromDir(dir string, includes, excludes []string) (io.ReadCloser, error) {
+	for _, p := range slices.Concat(includes, excludes) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", p, err)
 		}
 	}
 
-	if err := tw.Close(); err != nil {
-		return nil, fmt.Errorf("error to build context: %w", err)
-	}
-	if err := gzw.Close(); err != nil {
-		return nil, fmt.Errorf("error to compress context: %w", err)
+	return tarGz(func(tw *tar.Writer) error {
+		return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil || rel == "." {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			if matchAny(excludes, rel) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || (len(includes) > 0 && !matchAny(includes, rel)) {
+				return nil
+			}
+
+			fi, err := d.Info()
+			if err != nil {
+				return fmt.Errorf("error to get info on file %s: %w", p, err)
+			}
+			return FileToTar(rel, p, int64(fi.Mode().Perm()), tw)
+		})
+	})
+}
+
+// matchAny reports whether rel or its base name matches any of the patterns.
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(rel)); ok {
+			return true
+		}
 	}
+	return false
+}
+
+// tarGz streams the gzip compressed tar archive written by add through a pipe.
+//
+// Closing the returned reader before the archive is fully read stops add
+// on its next write.
+func tarGz(add func(tw *tar.Writer) error) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		gzw := gzip.NewWriter(pw)
+		tw := tar.NewWriter(gzw)
+
+		err := add(tw)
+		if err == nil {
+			if err = tw.Close(); err != nil {
+				err = fmt.Errorf("error to build context: %w", err)
+			}
+		}
+		if err == nil {
+			if err = gzw.Close(); err != nil {
+				err = fmt.Errorf("error to compress context: %w", err)
+			}
+		}
+		pw.CloseWithError(err)
+	}()
 
-	return &buf, nil
+	return pr, nil
 }
 
+// FileToTar archives the file at filePath as name with the given mode.
+//
+// Symbolic links are archived as links, not followed. Directories are
+// archived recursively, their entries keeping their own permissions.
 func FileToTar(name, filePath string, mode int64, tw *tar.Writer) error {
-	f, err := os.Open(filePath)
+	fi, err := os.Lstat(filePath)
 	if err != nil {
-		return fmt.Errorf("error to open file %s: %w", filePath, err)
+		return fmt.Errorf("error to get info on file %s: %w", filePath, err)
 	}
-	defer f.Close()
 
-	fi, err := f.Stat()
-	if err != nil {
-		return fmt.Errorf("error to get info on file %s: %w", filePath, err)
+	var link string
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		link, err = os.Readlink(filePath)
+		if err != nil {
+			return fmt.Errorf("error to read link %s: %w", filePath, err)
+		}
 	}
 
-	hdr, err := tar.FileInfoHeader(fi, "")
+	hdr, err := tar.FileInfoHeader(fi, link)
 	if err != nil {
 		return fmt.Errorf("error to create headers for file %s: %w", filePath, err)
 	}
 	hdr.Name = name
 	hdr.Mode = mode
+	if fi.IsDir() {
+		hdr.Name = strings.TrimSuffix(name, "/") + "/"
+	}
 
 	err = tw.WriteHeader(hdr)
 	if err != nil {
 		return fmt.Errorf("error to write headers for file %s: %w", filePath, err)
 	}
-	_, err = io.Copy(tw, f)
+
+	switch {
+	case fi.IsDir():
+		entries, err := os.ReadDir(filePath)
+		if err != nil {
+			return fmt.Errorf("error to read directory %s: %w", filePath, err)
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				return fmt.Errorf("error to get info on file %s: %w", e.Name(), err)
+			}
+			err = FileToTar(path.Join(hdr.Name, e.Name()), filepath.Join(filePath, e.Name()), int64(info.Mode().Perm()), tw)
+			if err != nil {
+				return err
+			}
+		}
+	case fi.Mode().IsRegular():
+		f, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("error to open file %s: %w", filePath, err)
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		if err != nil {
+			return fmt.Errorf("error to archive file %s: %w", filePath, err)
+		}
+	}
+
+	return nil
+}
+
+// BytesToTar archives content as a regular file named name with the given
+// mode, e.g. to add a Dockerfile generated in memory to a build context.
+func BytesToTar(name string, content []byte, mode int64, tw *tar.Writer) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     mode,
+		Size:     int64(len(content)),
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error to write headers for file %s: %w", name, err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		return fmt.Errorf("error to archive file %s: %w", name, err)
+	}
+	return nil
+}
+
+// HashGoSources returns a hex encoded SHA-256 hash of everything that goes
+// into building the package mod with opts: the source and embedded files of
+// the main module packages it depends on, the go.mod and go.sum files, the
+// toolchain version, the build options and the contents of the extra files.
+//
+// Dependencies from other modules are covered by the go.sum file.
+func HashGoSources(ctx context.Context, mod string, opts GoBuildOpts, extra ...string) (string, error) {
+	const listFmt = `{{if and .Module .Module.Main}}{{$dir := .Dir}}` +
+		`{{range .GoFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+		`{{range .EmbedFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}{{end}}`
+
+	args := append([]string{"list", "-deps", "-f", listFmt}, opts.args()...)
+	cmd := exec.CommandContext(ctx, opts.goBin(), append(args, mod)...)
+	cmd.Env = append(os.Environ(), opts.env()...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error to list sources of %s with output %s and error: %w", mod, out, err)
+	}
+
+	files := strings.Fields(string(out))
+	slices.Sort(files)
+	files = append(files, "go.mod", "go.sum")
+	files = append(files, extra...)
+
+	goVersion, err := GoVersion(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%q\n%q\n", goVersion, opts.args(), opts.env())
+	for _, name := range files {
+		if err := hashFile(h, name); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the name and contents of the file to w. Symbolic links
+// are written as their target and directories are hashed recursively.
+func hashFile(w io.Writer, name string) error {
+	fi, err := os.Lstat(name)
 	if err != nil {
-		return fmt.Errorf("error to archive file %s: %w", filePath, err)
+		return fmt.Errorf("error to get info on file %s: %w", name, err)
 	}
+	fmt.Fprintf(w, "%s %s\n", name, fi.Mode())
 
+	switch {
+	case fi.Mode()&fs.ModeSymlink != 0:
+		link, err := os.Readlink(name)
+		if err != nil {
+			return fmt.Errorf("error to read link %s: %w", name, err)
+		}
+		fmt.Fprintln(w, link)
+		return nil
+	case fi.IsDir():
+		entries, err := os.ReadDir(name)
+		if err != nil {
+			return fmt.Errorf("error to read directory %s: %w", name, err)
+		}
+		for _, e := range entries {
+			if err := hashFile(w, filepath.Join(name, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("error to open file %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("error to hash file %s: %w", name, err)
+	}
 	return nil
 }
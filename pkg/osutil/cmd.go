@@ -5,13 +5,49 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"sync"
 )
 
-// ExitOnErr writes the error to stderr and exits with a
-// 1 status code, only if the error provided is not nil.
+var (
+	cleanupsMu sync.Mutex
+	cleanups   []func() error
+)
+
+// RegisterCleanup registers f to be run by [RunCleanups], which
+// [ExitOnErr] calls before exiting, so resources are released even
+// when an error path exits the process and skips deferred calls.
+func RegisterCleanup(f func() error) {
+	cleanupsMu.Lock()
+	defer cleanupsMu.Unlock()
+	cleanups = append(cleanups, f)
+}
+
+// RunCleanups runs the registered cleanups in the reverse order they were
+// registered and unregisters them, so each cleanup runs at most once.
+//
+// Errors of the cleanups are joined together.
+func RunCleanups() error {
+	cleanupsMu.Lock()
+	fs := cleanups
+	cleanups = nil
+	cleanupsMu.Unlock()
+
+	var errs error
+	for _, f := range slices.Backward(fs) {
+		errs = errors.Join(errs, f())
+	}
+	return errs
+}
+
+// ExitOnErr writes the error to stderr, runs the registered cleanups and
+// exits with a 1 status code, only if the error provided is not nil.
 func ExitOnErr(err error) {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		if err := RunCleanups(); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed running cleanups: %w", err))
+		}
 		os.Exit(1)
 	}
 }
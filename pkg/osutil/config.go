@@ -0,0 +1,61 @@
+package osutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileVar is the environment variable holding the path of
+// the configuration file read by [Load] and [LoadFlags].
+//
+// [LoadFlags] also accepts it as the -config-file flag.
+const ConfigFileVar = "CONFIG_FILE"
+
+// readConfigFile reads a YAML or TOML configuration file, chosen by its
+// extension, and returns its top level values keyed by variable name.
+//
+// Keys are matched case-insensitively and dashes are treated as underscores,
+// so number_of_requests, number-of-requests and NUMBER_OF_REQUESTS all set
+// the NUMBER_OF_REQUESTS variable. Lists are joined with commas.
+func readConfigFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error to read config file %s: %w", path, err)
+	}
+
+	raw := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	case ".toml":
+		err = toml.Unmarshal(b, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %s, must be .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error to decode config file %s: %w", path, err)
+	}
+
+	vals := make(map[string]string, len(raw))
+	for k, v := range raw {
+		name := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		switch typed := v.(type) {
+		case map[string]any:
+			return nil, fmt.Errorf("unsupported nested value for key %s in config file %s", k, path)
+		case []any:
+			items := make([]string, len(typed))
+			for i, item := range typed {
+				items[i] = fmt.Sprint(item)
+			}
+			vals[name] = strings.Join(items, ",")
+		default:
+			vals[name] = fmt.Sprint(typed)
+		}
+	}
+	return vals, nil
+}
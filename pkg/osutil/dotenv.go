@@ -0,0 +1,67 @@
+package osutil
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DotenvFileVar is the environment variable holding the path of
+// the dotenv file read by [Load] and [LoadFlags].
+//
+// [LoadFlags] also accepts it as the -dotenv-file flag.
+const DotenvFileVar = "DOTENV_FILE"
+
+// LoadDotenv reads the KEY=VALUE pairs of the dotenv file at path into the
+// environment. Variables already set in the environment are not overridden.
+//
+// Blank lines and lines starting with # are ignored, an optional export
+// keyword before the key is accepted and values may be single or double quoted.
+func LoadDotenv(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error to open dotenv file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var errs error
+	scn := bufio.NewScanner(f)
+	for line := 1; scn.Scan(); line++ {
+		l := strings.TrimSpace(scn.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		k, v, ok := strings.Cut(strings.TrimPrefix(l, "export "), "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" {
+			errs = errors.Join(errs, fmt.Errorf("invalid line %d in dotenv file %s", line, path))
+			continue
+		}
+		switch {
+		case strings.HasPrefix(v, `"`):
+			uq, err := strconv.Unquote(v)
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("invalid quoted value at line %d in dotenv file %s", line, path))
+				continue
+			}
+			v = uq
+		case strings.HasPrefix(v, "'") && strings.HasSuffix(v, "'") && len(v) > 1:
+			v = v[1 : len(v)-1]
+		}
+
+		if _, ok := os.LookupEnv(k); ok {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error to set %s from dotenv file %s: %w", k, path, err))
+		}
+	}
+	if err := scn.Err(); err != nil {
+		return errors.Join(errs, fmt.Errorf("error to read dotenv file %s: %w", path, err))
+	}
+	return errs
+}
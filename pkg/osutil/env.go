@@ -2,23 +2,34 @@ package osutil
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
-// valPtr is a type constraint for pointers to string, int, or bool.
+// valPtr is a type constraint for pointers to string, int, bool,
+// duration, float, uint or a slice of strings.
 // It is used to ensure type safety when passing pointers to EnvVar.
 type valPtr interface {
-	*string | *int | *bool
+	*string | *int | *bool | *time.Duration | *float64 | *uint | *[]string
 }
 
 // EnvVar represents an environment variable to be loaded.
 // It contains the variable's name, a pointer to its value, and whether it is required.
 type EnvVar struct {
-	name     string // The name of the environment variable.
-	value    any    // A pointer to the variable's value.
-	required bool   // Whether the variable is required.
+	name        string // The name of the environment variable.
+	value       any    // A pointer to the variable's value.
+	required    bool   // Whether the variable is required.
+	def         string // The value held by the pointer when the variable was created.
+	description string // What the variable configures, shown by Usage.
+	validators  []Validator
+	wasSet      *bool // Whether the variable was set by the last load, if not nil.
 }
 
 // NewEnvVar creates an [EnvVar] instance for the given environment variable.
@@ -35,43 +46,355 @@ func NewEnvVar[T valPtr](name string, varP T, required bool) EnvVar {
 		name:     name,
 		value:    varP,
 		required: required,
+		def:      formatValue(varP),
 	}
 }
 
+// WithDescription sets the description of the variable shown by [Usage].
+func (ev EnvVar) WithDescription(description string) EnvVar {
+	ev.description = description
+	return ev
+}
+
+// EnvPrefix is the prefix of the environment variables read by the binaries,
+// so generic names like NUMBER_OF_REQUESTS do not collide with other tools in
+// shared environments.
+const EnvPrefix = "HMB_"
+
+// Loader loads variables as [Load] and [LoadFlags] do, looking them up in the
+// environment with Prefix prepended to their names first, e.g. [EnvPrefix].
+// Variables not set with the prefix fall back to their unprefixed name.
+//
+// The zero Loader looks the variables up by their names alone.
+type Loader struct {
+	Prefix string
+}
+
+// getenv retrieves the value of the environment variable name, looking
+// it up with the prefix of the loader first and without it after.
+func (l Loader) getenv(name string) string {
+	if l.Prefix != "" {
+		if v, ok := os.LookupEnv(l.Prefix + name); ok {
+			return v
+		}
+	}
+	return os.Getenv(name)
+}
+
+// WithValidators adds validators run on the value of the variable once it is loaded.
+//
+// Validators are not run on the default value.
+func (ev EnvVar) WithValidators(validators ...Validator) EnvVar {
+	ev.validators = append(slices.Clone(ev.validators), validators...)
+	return ev
+}
+
+// WasSet makes [Load] and [LoadFlags] report in set whether the variable was set,
+// with a flag, in the environment, in a secret file or in the configuration file,
+// rather than left at the value its pointer held, e.g. to reject options that can
+// not be set together even when one of them has a default.
+//
+// Panics if set is nil.
+func (ev EnvVar) WasSet(set *bool) EnvVar {
+	if set == nil {
+		panic(fmt.Sprintf("was set pointer for var %s must not be nil", ev.name))
+	}
+	ev.wasSet = set
+	return ev
+}
+
+// FlagName returns the command-line flag name of the variable,
+// which is its name in lower case with underscores replaced by dashes.
+//
+// For example, NUMBER_OF_REQUESTS becomes number-of-requests.
+func (ev EnvVar) FlagName() string {
+	return strings.ReplaceAll(strings.ToLower(ev.name), "_", "-")
+}
+
+// set converts v to the type of the variable and stores it.
+func (ev EnvVar) set(v string) error {
+	switch typed := ev.value.(type) {
+	case *string:
+		*typed = v
+	case *int:
+		cov, err := strconv.Atoi(v)
+		if err != nil {
+			return &ErrParse{Name: ev.name, Value: v, Type: "int", Err: err}
+		}
+		*typed = cov
+	case *bool:
+		cov, err := strconv.ParseBool(v)
+		if err != nil {
+			return &ErrParse{Name: ev.name, Value: v, Type: "bool", Err: err}
+		}
+		*typed = cov
+	case *time.Duration:
+		cov, err := time.ParseDuration(v)
+		if err != nil {
+			return &ErrParse{Name: ev.name, Value: v, Type: "duration", Err: err}
+		}
+		*typed = cov
+	case *float64:
+		cov, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return &ErrParse{Name: ev.name, Value: v, Type: "float", Err: err}
+		}
+		*typed = cov
+	case *uint:
+		cov, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return &ErrParse{Name: ev.name, Value: v, Type: "uint", Err: err}
+		}
+		*typed = uint(cov)
+	case *[]string:
+		// Values are comma-separated, surrounding spaces are trimmed.
+		items := strings.Split(v, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		*typed = items
+	default:
+		return fmt.Errorf("unrecognized env var type %T", ev.value)
+	}
+	return nil
+}
+
+// SecretFileSuffix is appended to a variable name to get the name of the
+// environment variable pointing to a file with its value, e.g. TOKEN_FILE for TOKEN.
+const SecretFileSuffix = "_FILE"
+
+// readSecretFile returns the contents of the file pointed by the name+[SecretFileSuffix]
+// environment variable, without trailing newlines, or an empty string if it is not set.
+func (l Loader) readSecretFile(name string) (string, error) {
+	path := l.getenv(name + SecretFileSuffix)
+	if path == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read file %s%s for variable %s: %w", name, SecretFileSuffix, name, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// validate runs the validators of the variable on its current value.
+func (ev EnvVar) validate() error {
+	v := reflect.ValueOf(ev.value).Elem().Interface()
+	for _, validate := range ev.validators {
+		if err := validate(v); err != nil {
+			return &ErrInvalid{Name: ev.name, Value: v, Err: err}
+		}
+	}
+	return nil
+}
+
 // Load loads the values of the provided environment variables into their respective pointers.
 // Accepts a variadic list of Var.
 // Returns an error if any required variable is missing or if a value cannot be converted to the expected type.
+// The errors of each variable are joined together and can be told apart
+// with [ErrMissingVar], [ErrParse] and [ErrInvalid].
+//
+// If a variable is not set but the same variable with the [SecretFileSuffix]
+// is, e.g. TOKEN_FILE for TOKEN, the value is read from the file it points to.
+//
+// If the [DotenvFileVar] environment variable is set, the dotenv file it
+// points to is loaded into the environment with [LoadDotenv] first.
+// If the [ConfigFileVar] environment variable is set, values missing from
+// the environment are taken from the configuration file it points to.
 func Load(vars ...EnvVar) error {
-	var errs error
+	return Loader{}.Load(vars...)
+}
+
+// Load loads the variables as [Load], looking them up with the prefix of the loader first.
+func (l Loader) Load(vars ...EnvVar) error {
+	return l.load(nil, l.getenv(DotenvFileVar), "", vars)
+}
+
+// HelpVar is the environment variable that, when true, makes
+// [LoadFlags] print the usage as if the -help flag was set.
+const HelpVar = "HELP"
+
+// LoadFlags registers a command-line flag for each variable in fs, named
+// after [EnvVar.FlagName], parses args and loads the values of the variables.
+//
+// A flag takes precedence over the environment variable of the same option,
+// which takes precedence over the configuration file, which takes precedence
+// over the value the variable pointer already holds.
+// Boolean flags can be set without a value, e.g. -force-image-rebuild.
+//
+// The configuration file is set with the -config-file flag or the [ConfigFileVar] environment variable.
+// The dotenv file is set with the -dotenv-file flag or the [DotenvFileVar] environment variable.
+//
+// The usage of fs is replaced to also print the variables with [Usage].
+func LoadFlags(fs *flag.FlagSet, args []string, vars ...EnvVar) error {
+	return Loader{}.LoadFlags(fs, args, vars...)
+}
+
+// LoadFlags registers the flags of the variables and loads them as [LoadFlags],
+// looking them up with the prefix of the loader first.
+func (l Loader) LoadFlags(fs *flag.FlagSet, args []string, vars ...EnvVar) error {
+	flagVals := make(map[string]string)
+	configFile := fs.String("config-file", "", fmt.Sprintf("path of a YAML or TOML configuration file, overrides the %s environment variable", ConfigFileVar))
+	dotenvFile := fs.String("dotenv-file", l.getenv(DotenvFileVar), "path of a dotenv file loaded into the environment")
 	for _, ev := range vars {
-		v := os.Getenv(ev.name)
-		if v == "" {
-			if ev.required {
-				errs = errors.Join(fmt.Errorf("missing required variable %s", ev.name), errs)
-			}
+		name := ev.FlagName()
+		usage := ev.description
+		if usage == "" {
+			usage = fmt.Sprintf("overrides the %s environment variable", ev.name)
+		}
+		store := func(v string) error {
+			flagVals[ev.name] = v
+			return nil
+		}
+		if _, ok := ev.value.(*bool); ok {
+			fs.BoolFunc(name, usage, store)
 			continue
 		}
+		fs.Func(name, usage, store)
+	}
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output())
+		l.Usage(fs.Output(), vars...)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if help, _ := strconv.ParseBool(l.getenv(HelpVar)); help {
+		fs.Usage()
+		if fs.ErrorHandling() == flag.ExitOnError {
+			os.Exit(0)
+		}
+		return flag.ErrHelp
+	}
+	return l.load(flagVals, *dotenvFile, *configFile, vars)
+}
+
+// load loads the variables taking their values from flagVals when present,
+// from the environment otherwise and, as a last resort, from the configuration file.
+//
+// The dotenv file, if any, is loaded into the environment first, so it can also
+// set the configuration file when configFile is empty.
+func (l Loader) load(flagVals map[string]string, dotenvFile, configFile string, vars []EnvVar) error {
+	if dotenvFile != "" {
+		if err := LoadDotenv(dotenvFile); err != nil {
+			return err
+		}
+	}
+	if configFile == "" {
+		configFile = l.getenv(ConfigFileVar)
+	}
 
-		switch typed := ev.value.(type) {
-		case *string:
-			*typed = v
-		case *int:
-			cov, err := strconv.Atoi(v)
+	var fileVals map[string]string
+	if configFile != "" {
+		var err error
+		fileVals, err = readConfigFile(configFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var errs error
+	for _, ev := range vars {
+		if ev.wasSet != nil {
+			*ev.wasSet = false
+		}
+		v, ok := flagVals[ev.name]
+		if !ok {
+			v = l.getenv(ev.name)
+		}
+		if v == "" {
+			var err error
+			v, err = l.readSecretFile(ev.name)
 			if err != nil {
-				errs = errors.Join(fmt.Errorf("unable to convert %s to type int", v), errs)
+				errs = errors.Join(err, errs)
 				continue
 			}
-			*typed = cov
-		case *bool:
-			cov, err := strconv.ParseBool(v)
-			if err != nil {
-				errs = errors.Join(fmt.Errorf("unable to convert %s to type bool", v), errs)
-				continue
+		}
+		if v == "" {
+			v = fileVals[ev.name]
+		}
+		if v == "" {
+			if ev.required {
+				errs = errors.Join(&ErrMissingVar{Name: ev.name}, errs)
 			}
-			*typed = cov
-		default:
-			errs = errors.Join(fmt.Errorf("unrecognized env var type %T", ev.value), errs)
+			continue
+		}
+
+		if err := ev.set(v); err != nil {
+			errs = errors.Join(err, errs)
+			continue
+		}
+		if ev.wasSet != nil {
+			*ev.wasSet = true
+		}
+		if err := ev.validate(); err != nil {
+			errs = errors.Join(err, errs)
 		}
 	}
 	return errs
 }
+
+// Usage writes the name, type, default value, whether it is
+// required and the description of each variable to w.
+func Usage(w io.Writer, vars ...EnvVar) {
+	Loader{}.Usage(w, vars...)
+}
+
+// Usage writes the variables as [Usage], with the prefix of the loader their names can be set with.
+func (l Loader) Usage(w io.Writer, vars ...EnvVar) {
+	fmt.Fprintln(w, "Environment variables:")
+	for _, ev := range vars {
+		name := ev.name
+		if l.Prefix != "" {
+			name = "[" + l.Prefix + "]" + name
+		}
+		fmt.Fprintf(w, "  %s (-%s) %s", name, ev.FlagName(), typeName(ev.value))
+		if ev.required {
+			fmt.Fprint(w, ", required")
+		} else if ev.def != "" {
+			fmt.Fprintf(w, ", default %q", ev.def)
+		}
+		fmt.Fprintln(w)
+		if ev.description != "" {
+			fmt.Fprintf(w, "    \t%s\n", ev.description)
+		}
+	}
+}
+
+// formatValue formats the value pointed by varP as it would be written in the environment.
+func formatValue(varP any) string {
+	switch typed := varP.(type) {
+	case *string:
+		return *typed
+	case *[]string:
+		return strings.Join(*typed, ",")
+	case *time.Duration:
+		return typed.String()
+	case *int:
+		return strconv.Itoa(*typed)
+	case *bool:
+		return strconv.FormatBool(*typed)
+	case *float64:
+		return strconv.FormatFloat(*typed, 'g', -1, 64)
+	case *uint:
+		return strconv.FormatUint(uint64(*typed), 10)
+	}
+	return ""
+}
+
+// typeName returns the name of the type of the value pointed by varP.
+func typeName(varP any) string {
+	switch varP.(type) {
+	case *time.Duration:
+		return "duration"
+	case *float64:
+		return "float"
+	case *[]string:
+		return "list"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", varP), "*")
+}
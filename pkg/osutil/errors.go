@@ -0,0 +1,43 @@
+package osutil
+
+import "fmt"
+
+// ErrMissingVar is returned by [Load] and [LoadFlags] when a required variable is not set.
+type ErrMissingVar struct {
+	Name string
+}
+
+func (e *ErrMissingVar) Error() string {
+	return fmt.Sprintf("missing required variable %s", e.Name)
+}
+
+// ErrParse is returned by [Load] and [LoadFlags] when the value
+// of a variable cannot be converted to the type of the variable.
+type ErrParse struct {
+	Name, Value, Type string
+	Err               error
+}
+
+func (e *ErrParse) Error() string {
+	return fmt.Sprintf("unable to convert %s to type %s for variable %s", e.Value, e.Type, e.Name)
+}
+
+func (e *ErrParse) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalid is returned by [Load] and [LoadFlags] when
+// the value of a variable is rejected by one of its validators.
+type ErrInvalid struct {
+	Name  string
+	Value any
+	Err   error
+}
+
+func (e *ErrInvalid) Error() string {
+	return fmt.Sprintf("invalid value %v for variable %s: %s", e.Value, e.Name, e.Err)
+}
+
+func (e *ErrInvalid) Unwrap() error {
+	return e.Err
+}
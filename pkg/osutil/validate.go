@@ -0,0 +1,112 @@
+package osutil
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// Validator checks the value of a variable after it is converted to its type.
+//
+// The value received is the one pointed by the variable pointer, e.g. an int for *int.
+type Validator func(v any) error
+
+// number is a type constraint for the ordered types an [EnvVar] can hold.
+type number interface {
+	~int | ~uint | ~float64 | time.Duration
+}
+
+// Min returns a [Validator] which rejects values lower than n.
+func Min[T number](n T) Validator {
+	return func(v any) error {
+		typed, ok := v.(T)
+		if !ok {
+			return fmt.Errorf("cannot compare %T with minimum %v", v, n)
+		}
+		if cmp.Less(typed, n) {
+			return fmt.Errorf("must be at least %v", n)
+		}
+		return nil
+	}
+}
+
+// Max returns a [Validator] which rejects values greater than n.
+func Max[T number](n T) Validator {
+	return func(v any) error {
+		typed, ok := v.(T)
+		if !ok {
+			return fmt.Errorf("cannot compare %T with maximum %v", v, n)
+		}
+		if cmp.Less(n, typed) {
+			return fmt.Errorf("must be at most %v", n)
+		}
+		return nil
+	}
+}
+
+// Match returns a [Validator] which rejects strings not matching the regular expression expr.
+//
+// Panics if expr does not compile.
+func Match(expr string) Validator {
+	re := regexp.MustCompile(expr)
+	return func(v any) error {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("cannot match %T against %s", v, expr)
+		}
+		if !re.MatchString(s) {
+			return fmt.Errorf("must match %s", expr)
+		}
+		return nil
+	}
+}
+
+// OneOf returns a [Validator] which rejects values not present in vals.
+func OneOf[T comparable](vals ...T) Validator {
+	return func(v any) error {
+		typed, ok := v.(T)
+		if !ok || !slices.Contains(vals, typed) {
+			return fmt.Errorf("must be one of %v", vals)
+		}
+		return nil
+	}
+}
+
+// URL returns a [Validator] which rejects strings that are not absolute URLs.
+func URL() Validator {
+	return func(v any) error {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("cannot parse %T as URL", v)
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return fmt.Errorf("must be a valid URL: %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("must be an absolute URL with scheme and host")
+		}
+		return nil
+	}
+}
+
+// Each returns a [Validator] which runs validators on every item of a list.
+func Each(validators ...Validator) Validator {
+	return func(v any) error {
+		items, ok := v.([]string)
+		if !ok {
+			return fmt.Errorf("cannot iterate over %T", v)
+		}
+		for _, item := range items {
+			for _, validate := range validators {
+				if err := validate(item); err != nil {
+					return fmt.Errorf("item %q %w", item, err)
+				}
+			}
+		}
+		return nil
+	}
+}
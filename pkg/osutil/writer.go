@@ -0,0 +1,134 @@
+package osutil
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// AsyncWriter buffers what is written to it in memory and writes it to the underlying
+// writer from a goroutine of its own, every interval or once half of its buffer is full,
+// so the writes to, e.g., stdout are taken off the path of its writers.
+//
+// Its writers still share a single buffer behind a single lock, so concurrent writers
+// wait for each other to copy their writes. Writes block once the buffer is full,
+// until it is written. The writes are written in
+// the order they were written in, and what is left in the buffer by [AsyncWriter.Close].
+type AsyncWriter struct {
+	w io.Writer
+
+	mu     sync.Mutex
+	cond   *sync.Cond // signaled when the buffer was swapped out to be written
+	buf    []byte
+	spare  []byte // buffer swapped in while buf is written
+	size   int
+	err    error // first error writing to w, returned by every write after it
+	closed bool
+
+	kick chan struct{}
+	done chan struct{}
+}
+
+// NewAsyncWriter returns an [AsyncWriter] writing to w every interval, from a buffer of size bytes.
+func NewAsyncWriter(w io.Writer, size int, interval time.Duration) *AsyncWriter {
+	aw := &AsyncWriter{
+		w:     w,
+		buf:   make([]byte, 0, size),
+		spare: make([]byte, 0, size),
+		size:  size,
+		kick:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	aw.cond = sync.NewCond(&aw.mu)
+	go aw.run(interval)
+	return aw
+}
+
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	// Writes larger than the buffer are buffered whole once it is empty.
+	for len(aw.buf) > 0 && len(aw.buf)+len(p) > aw.size && aw.err == nil && !aw.closed {
+		aw.flushSoon()
+		aw.cond.Wait()
+	}
+	if aw.err != nil {
+		return 0, aw.err
+	}
+	if aw.closed {
+		return 0, io.ErrClosedPipe
+	}
+	aw.buf = append(aw.buf, p...)
+	if len(aw.buf) >= aw.size/2 {
+		aw.flushSoon()
+	}
+	return len(p), nil
+}
+
+// flushSoon has the buffer written without waiting for the interval.
+func (aw *AsyncWriter) flushSoon() {
+	select {
+	case aw.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the buffer every interval, or when kicked, until the writer is closed.
+func (aw *AsyncWriter) run(interval time.Duration) {
+	defer close(aw.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-aw.kick:
+		}
+		aw.mu.Lock()
+		closed := aw.closed
+		aw.mu.Unlock()
+		aw.flush()
+		if closed {
+			return
+		}
+	}
+}
+
+// flush writes the buffer to the underlying writer, swapping in the spare buffer meanwhile.
+func (aw *AsyncWriter) flush() {
+	aw.mu.Lock()
+	b := aw.buf
+	aw.buf = aw.spare[:0]
+	aw.cond.Broadcast()
+	aw.mu.Unlock()
+	if len(b) == 0 {
+		aw.mu.Lock()
+		aw.spare = b
+		aw.mu.Unlock()
+		return
+	}
+
+	_, err := aw.w.Write(b)
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	aw.spare = b[:0]
+	if err != nil && aw.err == nil {
+		aw.err = err
+		aw.cond.Broadcast()
+	}
+}
+
+// Close writes what is left in the buffer and stops the writer,
+// returning the first error writing to the underlying writer.
+// Writes after it fail.
+func (aw *AsyncWriter) Close() error {
+	aw.mu.Lock()
+	aw.closed = true
+	aw.cond.Broadcast()
+	aw.mu.Unlock()
+	aw.flushSoon()
+	<-aw.done
+
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	return aw.err
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/client"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Options configures the reverse proxy returned by [New].
+type Options struct {
+	// UpstreamHTTPVersion is the HTTP protocol version used toward the upstream.
+	//
+	// HTTP/2 is sent unencrypted (h2c) to http upstreams.
+	UpstreamHTTPVersion client.HttpVersion
+	// FlushInterval is how often the response body is flushed to the client
+	// while it is copied, zero buffers it and a negative value flushes every write.
+	FlushInterval time.Duration
+	// BufferSize is the size of the buffers the response body is copied
+	// through, zero uses the default of [httputil.ReverseProxy].
+	BufferSize int
+	// DialTimeout limits how long connecting to the upstream takes.
+	DialTimeout time.Duration
+	// ResponseHeaderTimeout limits how long the upstream takes to send the response headers.
+	ResponseHeaderTimeout time.Duration
+	// IdleConnTimeout is how long idle upstream connections are kept open.
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost is the amount of idle upstream connections
+	// kept open, zero uses the default of [http.Transport].
+	MaxIdleConnsPerHost int
+}
+
+// New returns a reverse proxy forwarding the requests to the upstream URL.
+//
+// If logger is not nil, a "req proxied" entry is written for every request
+// forwarded with whether the upstream connection was reused and how long the
+// upstream took to respond, so the latency added by the proxy can be told apart.
+func New(upstream *url.URL, opts Options, logger *slog.Logger) (http.Handler, error) {
+	transp, err := newTransport(upstream, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+		},
+		Transport:     transp,
+		FlushInterval: opts.FlushInterval,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if logger != nil {
+				logger.Error("req failed", "error", err, "path", r.URL.Path)
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	if opts.BufferSize > 0 {
+		rp.BufferPool = newBufferPool(opts.BufferSize)
+	}
+	if logger == nil {
+		return rp, nil
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reused bool
+		var upstreamTime time.Duration
+		t1 := time.Now()
+		r = r.WithContext(httptrace.WithClientTrace(r.Context(), &httptrace.ClientTrace{
+			GotConn: func(gci httptrace.GotConnInfo) {
+				reused = gci.Reused
+			},
+			GotFirstResponseByte: func() {
+				upstreamTime = time.Since(t1)
+			},
+		}))
+		rp.ServeHTTP(w, r)
+		logger.Info("req proxied",
+			"path", r.URL.Path,
+			"proto", r.Proto,
+			"upstream_reused", reused,
+			"upstream_time_nano", upstreamTime.Nanoseconds(),
+			"proxy_time_nano", time.Since(t1).Nanoseconds(),
+		)
+	}), nil
+}
+
+// newTransport creates the transport of the requests sent to the upstream.
+//
+// As the servers of the benchmark, upstreams served over
+// TLS use self-signed certificates, which are not verified.
+func newTransport(upstream *url.URL, opts Options) (http.RoundTripper, error) {
+	tlsCfg := &tls.Config{InsecureSkipVerify: true}
+	if opts.UpstreamHTTPVersion == client.HTTP3 {
+		return &http3.Transport{TLSClientConfig: tlsCfg}, nil
+	}
+
+	protos := &http.Protocols{}
+	switch opts.UpstreamHTTPVersion {
+	case client.HTTP1:
+		protos.SetHTTP1(true)
+	case client.HTTP2:
+		if upstream.Scheme == "https" {
+			protos.SetHTTP2(true)
+		} else {
+			protos.SetUnencryptedHTTP2(true)
+		}
+	default:
+		return nil, fmt.Errorf("invalid HTTP version: %d", opts.UpstreamHTTPVersion)
+	}
+
+	return &http.Transport{
+		Protocols: protos,
+		DialContext: (&net.Dialer{
+			Timeout: opts.DialTimeout,
+		}).DialContext,
+		TLSClientConfig:       tlsCfg,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+	}, nil
+}
+
+// bufferPool is a [httputil.BufferPool] of buffers of a fixed size.
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	return &bufferPool{pool: sync.Pool{
+		New: func() any { return make([]byte, size) },
+	}}
+}
+
+func (p *bufferPool) Get() []byte  { return p.pool.Get().([]byte) }
+func (p *bufferPool) Put(b []byte) { p.pool.Put(b) }
@@ -0,0 +1,46 @@
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Chaos actions taken on containers during a run.
+const (
+	// ChaosStop stops a container gracefully, killing it if it does not stop in time.
+	ChaosStop = "stop"
+	// ChaosKill kills a container right away.
+	ChaosKill = "kill"
+	// ChaosStart starts a container taken down before.
+	ChaosStart = "start"
+)
+
+// ChaosEvent is an action taken on a container during a run, written as
+// a JSON line to the chaos file of the run when the action is taken.
+type ChaosEvent struct {
+	Time      time.Time `json:"time"`
+	Container string    `json:"container"`
+	Action    string    `json:"action"`
+}
+
+// ReadChaosEvents reads the chaos events written as JSON lines to r.
+//
+// Lines that are not valid JSON are skipped.
+func ReadChaosEvents(r io.Reader) ([]ChaosEvent, error) {
+	var events []ChaosEvent
+	scn := bufio.NewScanner(r)
+	for scn.Scan() {
+		var e ChaosEvent
+		if err := json.Unmarshal(scn.Bytes(), &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	if err := scn.Err(); err != nil {
+		return nil, fmt.Errorf("error to read chaos events: %w", err)
+	}
+	return events, nil
+}
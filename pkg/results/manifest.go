@@ -0,0 +1,125 @@
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/schema"
+)
+
+// ManifestFileName is the name of the manifest file written at the root of a run directory.
+const ManifestFileName = "manifest.json"
+
+// Container roles recorded in the manifest.
+const (
+	RoleClient = "client"
+	RoleServer = "server"
+	// RoleProxy is the role of a container forwarding the requests of its
+	// clients to its Target, it is the server of its clients and a client of its target.
+	RoleProxy = "proxy"
+	// RoleDNS is the role of a DNS server resolving the names of the servers for the clients.
+	RoleDNS = "dns"
+)
+
+// Manifest describes what a benchmark run was expected to produce.
+//
+// It is written by the benchmark runner before the containers are started
+// and used afterwards to validate that the results directory is complete.
+// TracesFile is set when the requests of the clients are traced and
+// ChaosFile when containers are taken down during the run.
+//
+// SchemaVersion is the version of the schema of the results of the run,
+// stamped by [WriteManifest]. It is 0 in results written before it was versioned.
+type Manifest struct {
+	SchemaVersion    int                 `json:"schema_version"`
+	CreatedAt        time.Time           `json:"created_at"`
+	NumberOfRequests int                 `json:"number_of_requests"`
+	ResponseLength   int                 `json:"response_length"`
+	Containers       []ManifestContainer `json:"containers"`
+	Artifacts        []ManifestArtifact  `json:"artifacts,omitempty"`
+	TracesFile       string              `json:"traces_file,omitempty"`
+	ChaosFile        string              `json:"chaos_file,omitempty"`
+}
+
+// ManifestArtifact identifies a binary used in a run, so runs
+// can be verified to have used identical binaries.
+type ManifestArtifact struct {
+	Name          string `json:"name"`
+	GoVersion     string `json:"go_version"`
+	BinarySHA256  string `json:"binary_sha256"`
+	ContextSHA256 string `json:"context_sha256"`
+}
+
+// ManifestContainer describes a single container of a run and the
+// files, relative to the run directory, its output is written to.
+//
+// Target is the name of the server container a client sends its requests to,
+// or the URI of the endpoint a worker of a distributed run sends them to.
+// NumberOfRequests is set when a client sends a share of the requests of
+// the run, e.g. a worker of a distributed run, instead of all of them, or
+// another amount, and ResponseLength when it requests responses of another length.
+// DurationNano is set when a client sends its requests for a duration instead,
+// so the amount of them is not known in advance.
+// PcapFile is set when the traffic of the container is captured and
+// PerfFile when its syscall and scheduling stats are recorded.
+// RuntimeFile is set when the Go runtime metrics of the container are sampled.
+type ManifestContainer struct {
+	Name             string `json:"name"`
+	Role             string `json:"role"`
+	Target           string `json:"target,omitempty"`
+	LogFile          string `json:"log_file,omitempty"`
+	StatFile         string `json:"stat_file,omitempty"`
+	NumberOfRequests int    `json:"number_of_requests,omitempty"`
+	ResponseLength   int    `json:"response_length,omitempty"`
+	DurationNano     int64  `json:"duration_nano,omitempty"`
+	PcapFile         string `json:"pcap_file,omitempty"`
+	PerfFile         string `json:"perf_file,omitempty"`
+	RuntimeFile      string `json:"runtime_file,omitempty"`
+}
+
+// WriteManifest writes the manifest as indented JSON into the run directory dir.
+//
+// The manifest is stamped with the current schema version.
+func WriteManifest(dir string, m Manifest) error {
+	m.SchemaVersion = schema.Version
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error to encode run manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFileName), b, 0644); err != nil {
+		return fmt.Errorf("error to write run manifest: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest reads the manifest from the run directory dir.
+//
+// It returns the manifest and an [*ErrSchemaVersion] if the results
+// of the run are not of the current schema version, since their
+// fields could be misread. Older results can be upgraded with [Migrate].
+func ReadManifest(dir string) (Manifest, error) {
+	m, err := readManifest(dir)
+	if err != nil {
+		return m, err
+	}
+	if v := manifestVersion(m); v != schema.Version {
+		return m, &ErrSchemaVersion{Dir: dir, Version: v}
+	}
+	return m, nil
+}
+
+// readManifest reads the manifest from the run directory dir, whatever its schema version.
+func readManifest(dir string) (Manifest, error) {
+	var m Manifest
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return m, fmt.Errorf("error to read run manifest: %w", err)
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return m, fmt.Errorf("error to decode run manifest: %w", err)
+	}
+	return m, nil
+}
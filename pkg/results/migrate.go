@@ -0,0 +1,126 @@
+package results
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pessolato/httpmicrobench/pkg/schema"
+)
+
+// ErrSchemaVersion is returned when the results of a run
+// are not of the current schema version.
+type ErrSchemaVersion struct {
+	Dir     string
+	Version int
+}
+
+func (e *ErrSchemaVersion) Error() string {
+	if e.Version > schema.Version {
+		return fmt.Sprintf("results in %s are of schema version %d, newer than the supported %d", e.Dir, e.Version, schema.Version)
+	}
+	return fmt.Sprintf("results in %s are of schema version %d, older than the current %d, upgrade them with: BENCH_RESULTS_DIRECTORY=%s go run ./cmd/migrate/",
+		e.Dir, e.Version, schema.Version, e.Dir)
+}
+
+// migrations upgrade the results of a run from the schema version
+// of their index plus one to the next one, given its manifest.
+// Each migration is appended when [schema.Version] is increased.
+var migrations = []func(dir string, m *Manifest) error{
+	// 1 to 2 stamps the schema version into the log entries.
+	func(dir string, m *Manifest) error {
+		for _, c := range m.Containers {
+			if c.LogFile == "" {
+				continue
+			}
+			if err := stampLogFile(filepath.Join(dir, c.LogFile), 2); err != nil {
+				return fmt.Errorf("error to migrate log file of %s container: %w", c.Name, err)
+			}
+		}
+		return nil
+	},
+}
+
+// Migrate upgrades the results of the run in dir to the current schema
+// version, applying the migrations of each version in order, and returns
+// the version they were of. Results of the current version are left as is.
+//
+// The manifest is rewritten last, so an interrupted migration is run again from its start.
+func Migrate(dir string) (int, error) {
+	m, err := readManifest(dir)
+	if err != nil {
+		return 0, err
+	}
+	from := manifestVersion(m)
+	if from > schema.Version {
+		return from, &ErrSchemaVersion{Dir: dir, Version: from}
+	}
+	for v := from; v < schema.Version; v++ {
+		if err := migrations[v-1](dir, &m); err != nil {
+			return from, fmt.Errorf("error to migrate %s from schema version %d to %d: %w", dir, v, v+1, err)
+		}
+	}
+	if from == schema.Version {
+		return from, nil
+	}
+	return from, WriteManifest(dir, m)
+}
+
+// manifestVersion returns the schema version of the results
+// described by m, 1 when they were written before it was versioned.
+func manifestVersion(m Manifest) int {
+	if m.SchemaVersion == 0 {
+		return 1
+	}
+	return m.SchemaVersion
+}
+
+// stampLogFile rewrites the JSONL log file at path, adding the schema version v
+// to its log entries that do not have one. Other lines are kept as they are.
+func stampLogFile(path string, v int) error {
+	in, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Missing files are reported by the validation of the run.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if info, err := in.Stat(); err == nil {
+		tmp.Chmod(info.Mode().Perm())
+	}
+
+	stamp := []byte(`,"` + schema.Key + `":` + strconv.Itoa(v) + `}`)
+	w := bufio.NewWriter(tmp)
+	scn := bufio.NewScanner(in)
+	scn.Buffer(nil, 1<<20)
+	for scn.Scan() {
+		line := scn.Bytes()
+		var entry map[string]json.RawMessage
+		if json.Unmarshal(line, &entry) == nil && len(entry) > 0 && entry[schema.Key] == nil {
+			trimmed := bytes.TrimRight(line, " \t\r")
+			line = append(trimmed[:len(trimmed)-1:len(trimmed)-1], stamp...)
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := scn.Err(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := errors.Join(w.Flush(), tmp.Close()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,228 @@
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// RequestRecord is the per-request record exported to Parquet.
+//
+// It merges all the log entries a client writes for a single request.
+// The column names are part of the export schema and must remain stable.
+//
+// ConnWaitNano is how long the request waited for a connection, from the
+// "get conn" to the "got conn" entries of the request, which includes
+// connecting when no idle connection could be reused. BytesRead is the
+// amount of bytes of the response body read by the client and BytesSent
+// of the request body it sent, if any. BytesDecoded is the amount of bytes
+// the client decompressed the response body into, if it was compressed.
+// TimedOut is set when the request failed as it took longer than the request
+// timeout of the client, and Invalid when its response did not live up to
+// the expectations of the client.
+// Retries is the amount of attempts of the request the client retried,
+// whose times are included in MaxTimeNano.
+//
+// DNSNano, ConnectNano and TLSNano are how long the DNS lookup, connection
+// and TLS handshake of the request took, 0 when it reused a connection, and
+// TTFBNano how long it took to get the first byte of the response.
+// TLSResumed is whether the connection of the request resumed a TLS session.
+// DNSSource is where the address of the target was resolved from, as
+// "static", "resolver" or "system", set when the client was configured
+// with static addresses or a DNS server of its own.
+// Target is the URL the request was sent to, set when the client
+// picked it among several targets, Method its method, set when it was not GET, and Workers the amount of workers
+// of the client at the time it completed, set when it ramped them up.
+// StartDelayNano is how long after its scheduled time the request started,
+// when the client sent its requests on a schedule, in an open loop.
+// IdleNano is how long the connection the request reused was idle for, and
+// StaleConn whether the request was sent again over a new connection, as the
+// idle one it was first sent over was closed by the server. IdleGapNano is
+// the idle gap of the client before the request, when it swept idle gaps.
+// Redirects is the amount of redirects the request followed.
+type RequestRecord struct {
+	ReqUUID        string    `parquet:"req_uuid" json:"req_uuid"`
+	Time           time.Time `parquet:"time,timestamp(nanosecond)" json:"time"`
+	StatusCode     int32     `parquet:"status_code" json:"status_code"`
+	MaxTimeNano    int64     `parquet:"max_time_nano" json:"max_time_nano"`
+	Reused         bool      `parquet:"reused" json:"reused"`
+	Failed         bool      `parquet:"failed" json:"failed"`
+	Error          string    `parquet:"error,optional" json:"error,omitempty"`
+	TimedOut       bool      `parquet:"timed_out" json:"timed_out"`
+	Invalid        bool      `parquet:"invalid" json:"invalid"`
+	Retries        int32     `parquet:"retries" json:"retries"`
+	ConnWaitNano   int64     `parquet:"conn_wait_nano" json:"conn_wait_nano"`
+	BytesRead      int64     `parquet:"bytes_read" json:"bytes_read"`
+	BytesSent      int64     `parquet:"bytes_sent" json:"bytes_sent"`
+	BytesDecoded   int64     `parquet:"bytes_decoded" json:"bytes_decoded"`
+	DNSNano        int64     `parquet:"dns_nano" json:"dns_nano"`
+	ConnectNano    int64     `parquet:"connect_nano" json:"connect_nano"`
+	TLSNano        int64     `parquet:"tls_nano" json:"tls_nano"`
+	TLSResumed     bool      `parquet:"tls_resumed" json:"tls_resumed"`
+	TTFBNano       int64     `parquet:"ttfb_nano" json:"ttfb_nano"`
+	DNSSource      string    `parquet:"dns_source,optional" json:"dns_source,omitempty"`
+	Target         string    `parquet:"target,optional" json:"target,omitempty"`
+	Method         string    `parquet:"method,optional" json:"method,omitempty"`
+	Workers        int32     `parquet:"workers" json:"workers"`
+	StartDelayNano int64     `parquet:"start_delay_nano" json:"start_delay_nano"`
+	IdleNano       int64     `parquet:"idle_nano" json:"idle_nano"`
+	StaleConn      bool      `parquet:"stale_conn" json:"stale_conn"`
+	IdleGapNano    int64     `parquet:"idle_gap_nano" json:"idle_gap_nano"`
+	Redirects      int32     `parquet:"redirects" json:"redirects"`
+}
+
+// clientLogLine holds the fields of a client log entry that make up a [RequestRecord].
+type clientLogLine struct {
+	Time           time.Time `json:"time"`
+	Msg            string    `json:"msg"`
+	ReqUUID        string    `json:"req_uuid"`
+	Reused         bool      `json:"reused"`
+	StatusCode     int32     `json:"status_code"`
+	MaxTimeNano    int64     `json:"max_time_nano"`
+	Error          string    `json:"error"`
+	Timeout        bool      `json:"timeout"`
+	BytesRead      int64     `json:"bytes_read"`
+	BytesSent      int64     `json:"bytes_sent"`
+	BytesDecoded   int64     `json:"bytes_decoded"`
+	DNSNano        int64     `json:"dns_nano"`
+	ConnectNano    int64     `json:"connect_nano"`
+	TLSNano        int64     `json:"tls_nano"`
+	TLSResumed     bool      `json:"tls_resumed"`
+	TTFBNano       int64     `json:"ttfb_nano"`
+	DNSSource      string    `json:"dns_source"`
+	Target         string    `json:"target"`
+	Method         string    `json:"method"`
+	Workers        int32     `json:"workers"`
+	StartDelayNano int64     `json:"start_delay_nano"`
+	IdleNano       int64     `json:"idle_nano"`
+	StaleConn      bool      `json:"stale_conn"`
+	IdleGapNano    int64     `json:"idle_gap_nano"`
+	Redirects      int32     `json:"redirects"`
+}
+
+// ReadRequestRecords reads client JSONL logs from r and merges
+// them into one [RequestRecord] per request, in order of first appearance.
+//
+// Lines that are not valid JSON or do not belong to a request are skipped.
+func ReadRequestRecords(r io.Reader) ([]RequestRecord, error) {
+	var recs []RequestRecord
+	idx := make(map[string]int)
+	// getConn holds when the requests started waiting for a connection.
+	getConn := make(map[string]time.Time)
+
+	scn := bufio.NewScanner(r)
+	for scn.Scan() {
+		var l clientLogLine
+		if err := json.Unmarshal(scn.Bytes(), &l); err != nil || l.ReqUUID == "" {
+			continue
+		}
+
+		i, ok := idx[l.ReqUUID]
+		if !ok {
+			i = len(recs)
+			idx[l.ReqUUID] = i
+			recs = append(recs, RequestRecord{ReqUUID: l.ReqUUID, Time: l.Time})
+		}
+
+		rec := &recs[i]
+		switch l.Msg {
+		case "get conn":
+			getConn[l.ReqUUID] = l.Time
+		case "got conn":
+			rec.Reused = l.Reused
+			if t, ok := getConn[l.ReqUUID]; ok {
+				rec.ConnWaitNano = l.Time.Sub(t).Nanoseconds()
+			}
+		case "req completion":
+			rec.Time = l.Time
+			rec.StatusCode = l.StatusCode
+			rec.MaxTimeNano = l.MaxTimeNano
+			rec.BytesRead = l.BytesRead
+			rec.BytesSent = l.BytesSent
+			rec.BytesDecoded = l.BytesDecoded
+			rec.DNSNano = l.DNSNano
+			rec.ConnectNano = l.ConnectNano
+			rec.TLSNano = l.TLSNano
+			rec.TLSResumed = l.TLSResumed
+			rec.TTFBNano = l.TTFBNano
+			rec.DNSSource = l.DNSSource
+			rec.Target = l.Target
+			rec.Method = l.Method
+			rec.Workers = l.Workers
+			rec.StartDelayNano = l.StartDelayNano
+			rec.IdleNano = l.IdleNano
+			rec.StaleConn = l.StaleConn
+			rec.IdleGapNano = l.IdleGapNano
+			rec.Redirects = l.Redirects
+		case "req invalid":
+			rec.Invalid = true
+			rec.Error = l.Error
+		case "req retry":
+			rec.Retries++
+		case "req failed":
+			rec.Time = l.Time
+			rec.Failed = true
+			rec.Error = l.Error
+			rec.TimedOut = l.Timeout
+		}
+	}
+	if err := scn.Err(); err != nil {
+		return nil, fmt.Errorf("error to read client logs: %w", err)
+	}
+	return recs, nil
+}
+
+// ExportParquet converts the client JSONL logs read from r into
+// a Parquet file of [RequestRecord] rows written to w.
+func ExportParquet(r io.Reader, w io.Writer) error {
+	recs, err := ReadRequestRecords(r)
+	if err != nil {
+		return err
+	}
+
+	pw := parquet.NewGenericWriter[RequestRecord](w)
+	if _, err := pw.Write(recs); err != nil {
+		return fmt.Errorf("error to write parquet rows: %w", err)
+	}
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("error to close parquet writer: %w", err)
+	}
+	return nil
+}
+
+// Fields returns the record values keyed by their column names.
+func (r RequestRecord) Fields() map[string]any {
+	return map[string]any{
+		"req_uuid":         r.ReqUUID,
+		"status_code":      r.StatusCode,
+		"max_time_nano":    r.MaxTimeNano,
+		"reused":           r.Reused,
+		"failed":           r.Failed,
+		"error":            r.Error,
+		"timed_out":        r.TimedOut,
+		"invalid":          r.Invalid,
+		"retries":          r.Retries,
+		"conn_wait_nano":   r.ConnWaitNano,
+		"bytes_read":       r.BytesRead,
+		"bytes_sent":       r.BytesSent,
+		"bytes_decoded":    r.BytesDecoded,
+		"dns_nano":         r.DNSNano,
+		"connect_nano":     r.ConnectNano,
+		"tls_nano":         r.TLSNano,
+		"tls_resumed":      r.TLSResumed,
+		"ttfb_nano":        r.TTFBNano,
+		"dns_source":       r.DNSSource,
+		"target":           r.Target,
+		"method":           r.Method,
+		"workers":          r.Workers,
+		"start_delay_nano": r.StartDelayNano,
+		"idle_nano":        r.IdleNano,
+		"stale_conn":       r.StaleConn,
+		"idle_gap_nano":    r.IdleGapNano,
+		"redirects":        r.Redirects,
+	}
+}
@@ -0,0 +1,103 @@
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// RunsFileName is the name of the file, at the root of an output directory,
+// the summaries of the runs written into it are appended to.
+const RunsFileName = "runs.jsonl"
+
+// RunSummary summarizes a run, appended as a JSON line to the runs
+// file of its output directory once it is done, so the results of
+// recurring runs of the same scenario can be tracked over time.
+type RunSummary struct {
+	ID         string          `json:"id"`
+	Scenario   string          `json:"scenario"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Clients    []ClientSummary `json:"clients,omitempty"`
+}
+
+// ClientSummary summarizes the requests of a client of a run.
+type ClientSummary struct {
+	Name       string `json:"name"`
+	Completed  int    `json:"completed"`
+	Failed     int    `json:"failed"`
+	MeanNano   int64  `json:"mean_nano"`
+	MedianNano int64  `json:"median_nano"`
+	P99Nano    int64  `json:"p99_nano"`
+}
+
+// SummarizeClients summarizes the requests of the clients of the run in dir, according to its manifest.
+func SummarizeClients(dir string) ([]ClientSummary, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	var sums []ClientSummary
+	for _, c := range m.Containers {
+		if c.Role != RoleClient || c.LogFile == "" {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, c.LogFile))
+		if err != nil {
+			return nil, fmt.Errorf("error to open log file of %s container: %w", c.Name, err)
+		}
+		recs, err := ReadRequestRecords(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		s := ClientSummary{Name: c.Name}
+		var times []int64
+		var sum int64
+		for _, r := range recs {
+			switch {
+			case r.Failed:
+				s.Failed++
+			case r.MaxTimeNano > 0:
+				s.Completed++
+				times = append(times, r.MaxTimeNano)
+				sum += r.MaxTimeNano
+			}
+		}
+		if len(times) > 0 {
+			slices.Sort(times)
+			s.MeanNano = sum / int64(len(times))
+			s.MedianNano = times[len(times)/2]
+			s.P99Nano = times[(len(times)-1)*99/100]
+		}
+		sums = append(sums, s)
+	}
+	return sums, nil
+}
+
+// AppendRunSummary appends the summary s to the runs file of the output directory dir.
+func AppendRunSummary(dir string, s RunSummary) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error to encode run summary: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error to create output directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, RunsFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error to open runs file: %w", err)
+	}
+	_, err = f.Write(append(b, '\n'))
+	if err = errors.Join(err, f.Close()); err != nil {
+		return fmt.Errorf("error to append run summary: %w", err)
+	}
+	return nil
+}
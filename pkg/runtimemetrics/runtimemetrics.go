@@ -0,0 +1,160 @@
+// Package runtimemetrics exposes Go runtime metrics of the benchmark binaries
+// over HTTP, so they can be sampled while the benchmark runs.
+package runtimemetrics
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime/metrics"
+	"time"
+)
+
+// Path is the path the metrics are served at.
+const Path = "/debug/metrics"
+
+// LogMsg is the message the samples logged by [LogEvery] are logged with.
+const LogMsg = "runtime sample"
+
+// Runtime metrics read for every sample.
+const (
+	gcCycles    = "/gc/cycles/total:gc-cycles"
+	gcPauses    = "/sched/pauses/total/gc:seconds"
+	heapObjects = "/memory/classes/heap/objects:bytes"
+	heapGoal    = "/gc/heap/goal:bytes"
+	heapAllocs  = "/gc/heap/allocs:bytes"
+	goroutines  = "/sched/goroutines:goroutines"
+)
+
+// Sample is a sample of the runtime metrics.
+//
+// GC cycles, GC pauses and allocations are cumulative since the process
+// started, so the difference between samples is what happened in between.
+type Sample struct {
+	Time             time.Time `json:"time"`
+	GCCycles         uint64    `json:"gc_cycles"`
+	GCPauses         uint64    `json:"gc_pauses"`
+	GCPauseTotalNano int64     `json:"gc_pause_total_nano"`
+	GCPauseMaxNano   int64     `json:"gc_pause_max_nano"`
+	HeapObjectsBytes uint64    `json:"heap_objects_bytes"`
+	HeapGoalBytes    uint64    `json:"heap_goal_bytes"`
+	HeapAllocsBytes  uint64    `json:"heap_allocs_bytes"`
+	Goroutines       uint64    `json:"goroutines"`
+}
+
+// Read reads a sample of the runtime metrics.
+//
+// GC pauses are read from a histogram, so their total and maximum
+// are estimated from the bounds of its buckets.
+func Read() Sample {
+	samples := []metrics.Sample{
+		{Name: gcCycles},
+		{Name: gcPauses},
+		{Name: heapObjects},
+		{Name: heapGoal},
+		{Name: heapAllocs},
+		{Name: goroutines},
+	}
+	metrics.Read(samples)
+
+	s := Sample{Time: time.Now()}
+	for _, m := range samples {
+		switch m.Value.Kind() {
+		case metrics.KindUint64:
+			v := m.Value.Uint64()
+			switch m.Name {
+			case gcCycles:
+				s.GCCycles = v
+			case heapObjects:
+				s.HeapObjectsBytes = v
+			case heapGoal:
+				s.HeapGoalBytes = v
+			case heapAllocs:
+				s.HeapAllocsBytes = v
+			case goroutines:
+				s.Goroutines = v
+			}
+		case metrics.KindFloat64Histogram:
+			s.GCPauses, s.GCPauseTotalNano, s.GCPauseMaxNano = pauses(m.Value.Float64Histogram())
+		}
+	}
+	return s
+}
+
+// pauses returns the count, the estimated total and the
+// estimated maximum, in nanoseconds, of the pauses of h.
+//
+// Every pause is estimated as the middle of its bucket, and the maximum
+// as the upper bound of the last bucket with pauses, when it is finite.
+func pauses(h *metrics.Float64Histogram) (n uint64, totalNano, maxNano int64) {
+	var total float64
+	for i, c := range h.Counts {
+		if c == 0 {
+			continue
+		}
+		lo, hi := h.Buckets[i], h.Buckets[i+1]
+		if math.IsInf(lo, -1) {
+			lo = 0
+		}
+		if math.IsInf(hi, 1) {
+			hi = lo
+		}
+		n += c
+		total += float64(c) * (lo + hi) / 2
+		maxNano = int64(hi * 1e9)
+	}
+	return n, int64(total * 1e9), maxNano
+}
+
+// Handler returns a handler responding with a [Sample] encoded as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Read())
+	})
+}
+
+// LogEvery logs a sample of the runtime metrics as [LogMsg] with logger every interval, with
+// the fields of a [Sample], until ctx is done, so they land in the same log as the events
+// they can be correlated with. A sample is logged right away, and the returned function
+// stops the sampling, logging a last sample.
+func LogEvery(ctx context.Context, logger *slog.Logger, interval time.Duration) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			logSample(logger)
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		logSample(logger)
+	}
+}
+
+// logSample logs a sample of the runtime metrics as [LogMsg].
+func logSample(logger *slog.Logger) {
+	s := Read()
+	logger.Info(LogMsg, "gc_cycles", s.GCCycles, "gc_pauses", s.GCPauses,
+		"gc_pause_total_nano", s.GCPauseTotalNano, "gc_pause_max_nano", s.GCPauseMaxNano,
+		"heap_objects_bytes", s.HeapObjectsBytes, "heap_goal_bytes", s.HeapGoalBytes,
+		"heap_allocs_bytes", s.HeapAllocsBytes, "goroutines", s.Goroutines)
+}
+
+// ListenAndServe serves the metrics at [Path] of the address addr.
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	return http.ListenAndServe(addr, mux)
+}
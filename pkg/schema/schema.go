@@ -0,0 +1,26 @@
+// Package schema versions the results of benchmark runs, the JSON log
+// entries of the clients, servers, proxies and DNS servers and the run
+// manifests, so results written by older versions can be told apart.
+package schema
+
+import (
+	"io"
+	"log/slog"
+)
+
+// Version is the current version of the results schema. It is increased
+// whenever the meaning of logged or manifest fields changes, along with
+// a migration upgrading results of the previous version.
+//
+// Version 1 is the schema of results written before it was versioned.
+const Version = 2
+
+// Key is the key the schema version is logged with in every log entry,
+// and written with into the run manifests.
+const Key = "schema_version"
+
+// NewJSONLogger returns a logger writing JSON log entries,
+// stamped with the schema version, to w.
+func NewJSONLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, nil)).With(Key, Version)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+)
+
+// CompressPath is the path prefix of the endpoint of the server started by [ListenAndServeRand]
+// which responds as the root path to the path after it, with the response compressed with
+// brotli or gzip, as preferred by the Accept-Encoding header of the request, so /compress/100
+// responds with 100 random bytes compressed. Requests accepting neither are not compressed.
+//
+// Random bytes do not compress, so the responses measure the cost of compressing
+// them rather than the bytes it saves.
+const CompressPath = "/compress/"
+
+// compress wraps the handler h compressing its responses as the requests accept.
+func compress(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := brotli.HTTPCompressor(w, r)
+		defer cw.Close()
+		h.ServeHTTP(&compressWriter{ResponseWriter: w, w: cw}, r)
+	})
+}
+
+// compressWriter is an [http.ResponseWriter] writing the
+// response body through the compressing writer w.
+type compressWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	return w.w.Write(b)
+}
+
+// Unwrap returns the underlying [http.ResponseWriter] so that
+// [http.ResponseController] can reach its optional interfaces.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
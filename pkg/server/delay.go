@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	mrand "math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DelayParam is the query parameter of the requests to the server started by [ListenAndServeRand]
+// delaying their responses, so the time of the requests spent on the network and in the client
+// can be told apart from the time spent serving them. The delay is either fixed, as a duration,
+// e.g. ?delay=10ms, or sampled for every request from a distribution:
+//
+//   - uniform:<min>:<max>, between the durations min and max, e.g. uniform:5ms:15ms
+//   - lognormal:<median>:<sigma>, log-normal of the duration median and the shape sigma, e.g. lognormal:10ms:0.5
+//   - pareto:<min>:<alpha>, Pareto of the duration min and the shape alpha, e.g. pareto:1ms:1.5
+//
+// Delays are capped at [MaxDelay]. The delay of every response is also sent in its
+// Server-Timing header, as delay, so clients can subtract it from the time of the request.
+const DelayParam = "delay"
+
+// MaxDelay is the longest delay a response is delayed by, however long the sampled one.
+const MaxDelay = time.Minute
+
+// delayDist samples the delays of the responses.
+type delayDist func() time.Duration
+
+// parseDelay parses the delay distribution s of [DelayParam].
+func parseDelay(s string) (delayDist, error) {
+	kind, args, ok := strings.Cut(s, ":")
+	if !ok {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid delay %q", s)
+		}
+		return func() time.Duration { return d }, nil
+	}
+	first, second, ok := strings.Cut(args, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid %s delay %q, expected two parameters", kind, s)
+	}
+	d, err := time.ParseDuration(first)
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("invalid duration %q of %s delay", first, kind)
+	}
+	switch kind {
+	case "uniform":
+		hi, err := time.ParseDuration(second)
+		if err != nil || hi < d {
+			return nil, fmt.Errorf("invalid maximum %q of uniform delay", second)
+		}
+		return func() time.Duration { return d + time.Duration(mrand.Int64N(int64(hi-d)+1)) }, nil
+	case "lognormal", "pareto":
+		shape, err := strconv.ParseFloat(second, 64)
+		if err != nil || shape <= 0 || math.IsInf(shape, 0) {
+			return nil, fmt.Errorf("invalid shape %q of %s delay", second, kind)
+		}
+		if kind == "lognormal" {
+			return func() time.Duration { return scaleDelay(d, math.Exp(shape*mrand.NormFloat64())) }, nil
+		}
+		// 1-Float64 is in (0, 1], so the scale never divides by 0.
+		return func() time.Duration { return scaleDelay(d, math.Pow(1-mrand.Float64(), -1/shape)) }, nil
+	default:
+		return nil, fmt.Errorf("unknown delay distribution %q", kind)
+	}
+}
+
+// scaleDelay returns d scaled by f, capped at [MaxDelay].
+func scaleDelay(d time.Duration, f float64) time.Duration {
+	scaled := float64(d) * f
+	if scaled >= float64(MaxDelay) {
+		return MaxDelay
+	}
+	return time.Duration(scaled)
+}
+
+// delayed wraps the handler h delaying its responses by the delay
+// of the [DelayParam] query parameter of the requests, if any.
+func delayed(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get(DelayParam)
+		if q == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		dist, err := parseDelay(q)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "unable to delay the response: %s", err)
+			return
+		}
+		d := min(dist(), MaxDelay)
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Server-Timing", fmt.Sprintf("delay;dur=%.3f", float64(d)/float64(time.Millisecond)))
+		h.ServeHTTP(w, r)
+	})
+}
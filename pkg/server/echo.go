@@ -0,0 +1,55 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// EchoPath is the path of the endpoint of the server started by [ListenAndServeRand] which
+// reads the whole request body, e.g. of POST requests and uploads, and responds with it, with
+// the Content-Type of the request. With the echo query parameter set to false, the body is
+// only read, and the response has no body, so /echo?echo=false measures the cost of draining
+// request bodies on the server alone.
+//
+// The body is read in full before the response is written, so echoed
+// bodies are held in memory and sent back with their Content-Length.
+const EchoPath = "/echo"
+
+// echoBody serves the requests of [EchoPath].
+func echoBody(w http.ResponseWriter, r *http.Request) {
+	echo := true
+	if q := r.URL.Query().Get("echo"); q != "" {
+		var err error
+		if echo, err = strconv.ParseBool(q); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "unable to convert echo %s into a boolean", q)
+			return
+		}
+	}
+	if !echo {
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "unable to read the request body: %s", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var body bytes.Buffer
+	if r.ContentLength > 0 {
+		body.Grow(int(min(r.ContentLength, 64<<20)))
+	}
+	if _, err := body.ReadFrom(r.Body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "unable to read the request body: %s", err)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	body.WriteTo(w)
+}
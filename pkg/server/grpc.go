@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/pessolato/httpmicrobench/pkg/grpcecho"
+)
+
+// ListenAndServeEcho starts a gRPC server which echoes the messages it receives.
+//
+// The size of the response is controlled by the client, through the size of its messages.
+// If logger is not nil, an access log entry is written for every message echoed.
+func ListenAndServeEcho(addr string, logger *slog.Logger) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer()
+	grpcecho.Register(s, &echoServer{logger})
+	return s.Serve(ln)
+}
+
+// echoServer implements [grpcecho.Server].
+type echoServer struct {
+	logger *slog.Logger
+}
+
+func (s *echoServer) Unary(ctx context.Context, msg []byte) ([]byte, error) {
+	if s.logger != nil {
+		var reqUuid string
+		if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(grpcecho.UuidMetadataKey)) > 0 {
+			reqUuid = md.Get(grpcecho.UuidMetadataKey)[0]
+		}
+		// The message is written by gRPC after it is returned, the serve time only covers the handler.
+		s.logServed(grpcecho.UnaryMethod, reqUuid, len(msg), time.Now())
+	}
+	return msg, nil
+}
+
+func (s *echoServer) Stream(stream grpc.ServerStream) error {
+	for {
+		var msg []byte
+		if err := stream.RecvMsg(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		t1 := time.Now()
+		if err := stream.SendMsg(&msg); err != nil {
+			return err
+		}
+		if s.logger != nil {
+			s.logServed(grpcecho.StreamMethod, "", len(msg), t1)
+		}
+	}
+}
+
+// logServed writes an access log entry with the fields of the ones written by [AccessLog],
+// except for the status code, as failed calls are not echoed.
+func (s *echoServer) logServed(method, reqUuid string, n int, t1 time.Time) {
+	attrs := []any{
+		"path", method,
+		"proto", "grpc",
+		"bytes_written", n,
+		"serve_time_nano", time.Since(t1).Nanoseconds(),
+	}
+	if reqUuid != "" {
+		attrs = append(attrs, "req_uuid", reqUuid)
+	}
+	s.logger.Info("req served", attrs...)
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"log/slog"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ListenAndServeRandH3 starts an HTTP/3 server, listening at the UDP
+// address addr, which serves the same handler as [ListenAndServeRand].
+//
+// The server uses the certificate of [LoadTLSConfig] and lets
+// clients resume their sessions as configured by resumption.
+func ListenAndServeRandH3(addr, certFile, keyFile string, resumption TLSResumption, logger *slog.Logger) error {
+	tlsCfg, err := LoadTLSConfig(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	tlsCfg.SessionTicketsDisabled = resumption.DisableSessionTickets
+	srv := &http3.Server{
+		Addr:       addr,
+		Handler:    RandHandler(logger),
+		TLSConfig:  http3.ConfigureTLSConfig(tlsCfg),
+		QUICConfig: &quic.Config{Allow0RTT: !resumption.Disable0RTT},
+	}
+	return srv.ListenAndServe()
+}
@@ -5,15 +5,56 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// SinkPath is the path prefix of the endpoint of the server started by [ListenAndServeRand]
+// which reads and discards the request body, e.g. of uploads, before responding as the
+// root path to the path after it, so /sink/100 responds with 100 random bytes.
+const SinkPath = "/sink/"
+
+// MultipartPath is the path prefix of the endpoint of the server started by [ListenAndServeRand]
+// which reads and discards the parts of multipart/form-data request bodies, e.g. of file uploads,
+// before responding as the root path to the path after it, as [SinkPath]. The parts are read
+// through a buffer of the size in bytes of the buffer query parameter, 32 KiB if not set, so
+// /multipart/100?buffer=4096 reads them 4 KiB at a time.
+const MultipartPath = "/multipart/"
+
 // ListenAndServeRand starts a server which responds with a random amount of bytes.
 //
 // The size of the response is controlled by the client.
-func ListenAndServeRand(addr string) error {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+// If logger is not nil, an access log entry is written for every request served.
+//
+// The server also echoes WebSocket messages at [WebSocketPath] and the
+// request bodies sent to [EchoPath], discards the request bodies sent to
+// [SinkPath] and the parts of those sent to [MultipartPath], compresses
+// the responses of [CompressPath], streams those of [StreamPath] in chunks
+// and responds to those of [StatusPath] with the status codes requested.
+// Requests with a [DelayParam] query parameter are responded to with a delay.
+//
+// Besides HTTP/1.1, the server accepts unencrypted HTTP/2 (h2c),
+// which proxies use when forwarding to it over HTTP/2, with its
+// connections configured by h2, e.g. their limit of concurrent streams.
+func ListenAndServeRand(addr string, h2 http.HTTP2Config, logger *slog.Logger) error {
+	protos := &http.Protocols{}
+	protos.SetHTTP1(true)
+	protos.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   RandHandler(logger),
+		Protocols: protos,
+		HTTP2:     &h2,
+	}
+	return srv.ListenAndServe()
+}
+
+// RandHandler returns the handler of the server started by [ListenAndServeRand].
+func RandHandler(logger *slog.Logger) http.Handler {
+	randBytes := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		pathParam := r.URL.Path[1:]
 		numBytes, err := strconv.Atoi(pathParam)
 		if err != nil {
@@ -28,6 +69,153 @@ func ListenAndServeRand(addr string) error {
 			return
 		}
 	})
+	h := delayed(randBytes)
+	sink := http.StripPrefix(strings.TrimSuffix(SinkPath, "/"), discardBody(h))
+	parts := http.StripPrefix(strings.TrimSuffix(MultipartPath, "/"), discardParts(h))
+	compressed := http.StripPrefix(strings.TrimSuffix(CompressPath, "/"), compress(h))
+	statuses := http.StripPrefix(strings.TrimSuffix(StatusPath, "/"), delayed(http.HandlerFunc(respondStatus)))
+	echoed := delayed(http.HandlerFunc(echoBody))
+	streamed := http.StripPrefix(strings.TrimSuffix(StreamPath, "/"), delayed(http.HandlerFunc(streamBytes)))
+	if logger != nil {
+		h = AccessLog(logger, h)
+		sink = AccessLog(logger, sink)
+		parts = AccessLog(logger, parts)
+		compressed = AccessLog(logger, compressed)
+		streamed = AccessLog(logger, streamed)
+		echoed = AccessLog(logger, echoed)
+		statuses = AccessLog(logger, statuses)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", h)
+	mux.Handle(SinkPath, sink)
+	mux.Handle(MultipartPath, parts)
+	mux.Handle(CompressPath, compressed)
+	mux.Handle(StreamPath, streamed)
+	mux.Handle(EchoPath, echoed)
+	mux.Handle(StatusPath, statuses)
+	mux.Handle(WebSocketPath, WebSocketEcho(logger))
+	return mux
+}
+
+// discardBody wraps the handler h reading and discarding the
+// whole request body before h serves the request.
+func discardBody(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "unable to read the request body: %s", err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// discardParts wraps the handler h reading and discarding the parts of the
+// multipart request body, through a buffer of the size of the buffer query
+// parameter, before h serves the request.
+func discardParts(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := 32 << 10
+		if q := r.URL.Query().Get("buffer"); q != "" {
+			n, err := strconv.Atoi(q)
+			if err != nil || n < 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "unable to convert buffer size %s into a valid amount of bytes", q)
+				return
+			}
+			size = n
+		}
+		mr, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "unable to read the multipart request body: %s", err)
+			return
+		}
+		buf := make([]byte, size)
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			// io.Copy would read through the buffer of io.Discard instead.
+			for err == nil {
+				_, err = part.Read(buf)
+			}
+			if err != io.EOF {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "unable to read the multipart request body: %s", err)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// AccessLog wraps the handler h logging the status code, the amount
+// of bytes written and the duration of every request it serves.
+//
+// Requests served over TLS are also logged with whether their connection
+// resumed a TLS session and whether they were sent as TLS 1.3 early data
+// (0-RTT), before the handshake of their connection completed. Requests
+// with a body are logged with the amount of bytes read from it.
+func AccessLog(logger *slog.Logger, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &recordingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		body := &recordingBody{ReadCloser: r.Body}
+		r.Body = body
+		t1 := time.Now()
+		h.ServeHTTP(rec, r)
+		attrs := []any{
+			"path", r.URL.Path,
+			"proto", r.Proto,
+			"status_code", rec.statusCode,
+			"bytes_written", rec.bytesWritten,
+			"serve_time_nano", time.Since(t1).Nanoseconds(),
+		}
+		if body.bytesRead > 0 {
+			attrs = append(attrs, "bytes_read", body.bytesRead)
+		}
+		if r.TLS != nil {
+			attrs = append(attrs, "tls_resumed", r.TLS.DidResume, "early_data", !r.TLS.HandshakeComplete)
+		}
+		logger.Info("req served", attrs...)
+	})
+}
+
+// recordingWriter is an [http.ResponseWriter] that records the
+// status code and the amount of bytes written to the response.
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode   int
+	bytesWritten int64
+}
+
+func (w *recordingWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytesWritten += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying [http.ResponseWriter] so that
+// [http.ResponseController] can reach its optional interfaces.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// recordingBody is a request body that records the amount of bytes read from it.
+type recordingBody struct {
+	io.ReadCloser
+	bytesRead int64
+}
 
-	return http.ListenAndServe(addr, nil)
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytesRead += int64(n)
+	return n, err
 }
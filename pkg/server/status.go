@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	mrand "math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// StatusPath is the path prefix of the endpoint of the server started by [ListenAndServeRand]
+// which responds with the status code of the path after it, between 200 and 599, and its text
+// as body, so /status/503 responds 503 Service Unavailable.
+//
+// The errors query parameter has the endpoint respond with other status codes instead, at
+// random, each in the percentage of the requests after it, as comma-separated code:percent
+// pairs, so /status/200?errors=500:1,503:0.5 responds 500 to 1% of the requests, 503 to
+// 0.5% of them and 200 to the others, as a controllable source of errors for the clients.
+const StatusPath = "/status/"
+
+// statusWeight is a status code responded to a percentage of the requests.
+type statusWeight struct {
+	code    int
+	percent float64
+}
+
+// parseStatusCode parses the status code s, between 200 and 599.
+func parseStatusCode(s string) (int, error) {
+	code, err := strconv.Atoi(s)
+	if err != nil || code < 200 || code > 599 {
+		return 0, fmt.Errorf("invalid status code %q", s)
+	}
+	return code, nil
+}
+
+// parseStatusErrors parses the errors query parameter s of [StatusPath].
+func parseStatusErrors(s string) ([]statusWeight, error) {
+	var weights []statusWeight
+	var total float64
+	for pair := range strings.SplitSeq(s, ",") {
+		c, p, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid error %q, expected code:percent", pair)
+		}
+		code, err := parseStatusCode(c)
+		if err != nil {
+			return nil, err
+		}
+		percent, err := strconv.ParseFloat(p, 64)
+		if err != nil || percent < 0 {
+			return nil, fmt.Errorf("invalid percent %q of status code %d", p, code)
+		}
+		total += percent
+		weights = append(weights, statusWeight{code: code, percent: percent})
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("percents of errors %q add up to more than 100", s)
+	}
+	return weights, nil
+}
+
+// respondStatus serves the requests of [StatusPath], stripped of it.
+func respondStatus(w http.ResponseWriter, r *http.Request) {
+	code, err := parseStatusCode(r.URL.Path[1:])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "unable to respond: %s", err)
+		return
+	}
+	if q := r.URL.Query().Get("errors"); q != "" {
+		weights, err := parseStatusErrors(q)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "unable to respond: %s", err)
+			return
+		}
+		x := mrand.Float64() * 100
+		for _, sw := range weights {
+			if x < sw.percent {
+				code = sw.code
+				break
+			}
+			x -= sw.percent
+		}
+	}
+	w.WriteHeader(code)
+	fmt.Fprint(w, http.StatusText(code))
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StreamPath is the path prefix of the endpoint of the server started by [ListenAndServeRand]
+// which responds with the amount of random bytes of the path after it, as the root path, but
+// written and flushed in chunks of the size in bytes of the chunk query parameter, 4 KiB if
+// not set, waiting for the duration of the interval query parameter between them, so
+// /stream/100000?chunk=1000&interval=10ms streams 100 chunks of 1000 bytes over a second.
+//
+// The chunks are flushed to the client as they are written, so the time to the first byte
+// and the consumption of streamed responses can be benchmarked, rather than one-shot bodies.
+const StreamPath = "/stream/"
+
+// streamBytes serves the requests of [StreamPath], stripped of it.
+func streamBytes(w http.ResponseWriter, r *http.Request) {
+	pathParam := r.URL.Path[1:]
+	numBytes, err := strconv.Atoi(pathParam)
+	if err != nil || numBytes < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "unable to convert requested value %s into a valid amount of bytes", pathParam)
+		return
+	}
+	chunk := 4 << 10
+	if q := r.URL.Query().Get("chunk"); q != "" {
+		chunk, err = strconv.Atoi(q)
+		if err != nil || chunk < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "unable to convert chunk size %s into a valid amount of bytes", q)
+			return
+		}
+	}
+	var interval time.Duration
+	if q := r.URL.Query().Get("interval"); q != "" {
+		interval, err = time.ParseDuration(q)
+		if err != nil || interval < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "unable to convert interval %s into a valid duration", q)
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	buf := make([]byte, min(chunk, numBytes))
+	for left := numBytes; left > 0; left -= len(buf) {
+		if left < len(buf) {
+			buf = buf[:left]
+		}
+		if left < numBytes && interval > 0 {
+			t := time.NewTimer(interval)
+			select {
+			case <-t.C:
+			case <-r.Context().Done():
+				t.Stop()
+				return
+			}
+		}
+		if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+			return
+		}
+		if _, err := w.Write(buf); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// SelfSignedTLSConfig returns a TLS configuration with a certificate
+// generated on the fly, valid for a day, for protocols that require TLS.
+//
+// Clients must skip the verification of the certificate.
+func SelfSignedTLSConfig() (*tls.Config, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certificate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certificate serial number: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"httpmicrobench"}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}, nil
+}
+
+// TLSResumption configures whether clients can resume their TLS sessions
+// with servers served over TLS, so the savings of resumed handshakes over
+// full ones can be measured.
+type TLSResumption struct {
+	// DisableSessionTickets has the server not issue session tickets,
+	// so every connection has a full handshake.
+	DisableSessionTickets bool
+	// Disable0RTT has the server reject requests sent as TLS 1.3 early
+	// data (0-RTT) by clients resuming their sessions.
+	Disable0RTT bool
+}
+
+// LoadTLSConfig returns a TLS configuration with the certificate of the PEM
+// files certFile and keyFile or, if certFile is empty, from [SelfSignedTLSConfig].
+func LoadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" {
+		return SelfSignedTLSConfig()
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+// ListenAndServeRandTLS starts an HTTPS server, listening at the TCP address addr, which
+// serves the same handler as [ListenAndServeRand] over HTTP/1.1 and HTTP/2, as negotiated
+// by the clients, with its HTTP/2 connections configured by h2.
+//
+// The server uses the certificate of [LoadTLSConfig], so HTTPS can be benchmarked without
+// a certificate authority, and lets clients resume their sessions as configured by
+// resumption. Requests sent as TLS 1.3 early data are not accepted over TCP.
+func ListenAndServeRandTLS(addr, certFile, keyFile string, h2 http.HTTP2Config, resumption TLSResumption, logger *slog.Logger) error {
+	tlsCfg, err := LoadTLSConfig(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	tlsCfg.SessionTicketsDisabled = resumption.DisableSessionTickets
+	protos := &http.Protocols{}
+	protos.SetHTTP1(true)
+	protos.SetHTTP2(true)
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   RandHandler(logger),
+		Protocols: protos,
+		HTTP2:     &h2,
+		TLSConfig: tlsCfg,
+	}
+	return srv.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketPath is the path of the WebSocket echo endpoint of the server started by [ListenAndServeRand].
+const WebSocketPath = "/ws"
+
+// WebSocketEcho returns a handler which echoes every message received on a WebSocket connection.
+//
+// If logger is not nil, an access log entry is written for every message echoed.
+func WebSocketEcho(logger *slog.Logger) http.Handler {
+	return websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+		for {
+			var msg []byte
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+			t1 := time.Now()
+			if err := websocket.Message.Send(ws, msg); err != nil {
+				return
+			}
+			if logger != nil {
+				logger.Info("req served",
+					"path", WebSocketPath,
+					"proto", "websocket",
+					"bytes_written", len(msg),
+					"serve_time_nano", time.Since(t1).Nanoseconds(),
+				)
+			}
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/client"
+	"github.com/pessolato/httpmicrobench/pkg/osutil"
+	"github.com/pessolato/httpmicrobench/pkg/schema"
+)
+
+// RunPath is the path of the endpoint a worker accepts jobs at.
+const RunPath = "/run"
+
+// SecretHeader is the header of the job requests holding the secret shared
+// by the coordinator and its workers, which reject the jobs without it.
+const SecretHeader = "X-Worker-Secret"
+
+// Job describes the share of a distributed run a single worker sends.
+//
+// With a Duration, the worker sends its requests for that long instead of NumberOfRequests
+// of them. With a TargetRPS, it paces them at that rate, its share of the rate of the run.
+type Job struct {
+	TargetEndpointURI string        `json:"target_endpoint_uri"`
+	NumberOfRequests  int           `json:"number_of_requests"`
+	Duration          time.Duration `json:"duration,omitempty"`
+	TargetRPS         float64       `json:"target_rps,omitempty"`
+	HTTPVersion       int           `json:"http_version"`
+	DrainClose        bool          `json:"drain_close"`
+}
+
+// Handler returns the handler of a worker agent.
+//
+// It accepts a [Job] as JSON with a POST request at [RunPath], sends its requests
+// and streams the JSON timing logs of the client back in the response body as
+// they are written, so the coordinator gathers the results while the job runs.
+//
+// Jobs are only accepted with secret in their [SecretHeader] and a target whose
+// host, or host and port, is one of targets, so the worker can not be used to
+// send requests to any other endpoint.
+//
+// The job is canceled if the coordinator goes away.
+func Handler(secret string, targets []string) http.Handler {
+	want := []byte(secret)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+RunPath, func(w http.ResponseWriter, r *http.Request) {
+		if secret == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretHeader)), want) != 1 {
+			http.Error(w, "missing or invalid secret", http.StatusUnauthorized)
+			return
+		}
+		var job Job
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			http.Error(w, fmt.Sprintf("invalid job: %s", err), http.StatusBadRequest)
+			return
+		}
+		if job.Duration < 0 || job.TargetRPS < 0 {
+			http.Error(w, "invalid job: duration and target rate must not be negative", http.StatusBadRequest)
+			return
+		}
+		if job.Duration == 0 && job.NumberOfRequests < 1 {
+			http.Error(w, "invalid job: number of requests must be at least 1", http.StatusBadRequest)
+			return
+		}
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, job.TargetEndpointURI, nil)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid job: %s", err), http.StatusBadRequest)
+			return
+		}
+		if !allowed(req.URL, targets) {
+			http.Error(w, fmt.Sprintf("target %s is not allowed", req.URL.Host), http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		logger := schema.NewJSONLogger(&flushWriter{w, http.NewResponseController(w)})
+		c, err := client.NewDoTimeRepeatClient(req, logger, client.HttpVersion(job.HTTPVersion))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid job: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		if job.TargetRPS > 0 {
+			c.WithTargetRate(job.TargetRPS)
+		}
+
+		respHandler := client.CloseBody
+		if job.DrainClose {
+			respHandler = client.DrainCloseBody
+		}
+		if job.Duration > 0 {
+			err = c.DoTimeRepeatFor(r.Context(), job.Duration, 1, respHandler, c.LogErr)
+		} else {
+			err = c.DoTimeRepeat(r.Context(), job.NumberOfRequests, respHandler, c.LogErr)
+		}
+		if err != nil {
+			logger.Error("job failed", "error", err)
+		}
+	})
+	return mux
+}
+
+// allowed reports whether the host, or host and port, of u is one of targets.
+func allowed(u *url.URL, targets []string) bool {
+	for _, t := range targets {
+		if strings.EqualFold(t, u.Host) || strings.EqualFold(t, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// flushWriter flushes the response after every write, each
+// log line is sent to the coordinator as soon as it is written.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (fw *flushWriter) Write(b []byte) (int, error) {
+	n, err := fw.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, fw.rc.Flush()
+}
+
+// Run sends the job to the worker listening at addr, e.g. host:9090, with the
+// secret shared with it, and copies the logs it streams back to w until the job is done.
+func Run(ctx context.Context, addr, secret string, job Job, w io.Writer) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("error to encode job for worker %s: %w", addr, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+addr+RunPath, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("error to create request for worker %s: %w", addr, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SecretHeader, secret)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error to send job to worker %s: %w", addr, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return osutil.DrainCloseErr(resp.Body, fmt.Errorf("worker %s rejected job with status %s", addr, resp.Status))
+	}
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		err = fmt.Errorf("error to gather logs from worker %s: %w", addr, err)
+	}
+	return errors.Join(err, resp.Body.Close())
+}
+
+// Split divides n requests into parts shares, which differ by at most one request.
+func Split(n, parts int) []int {
+	shares := make([]int, parts)
+	for i := range shares {
+		shares[i] = n / parts
+		if i < n%parts {
+			shares[i]++
+		}
+	}
+	return shares
+}
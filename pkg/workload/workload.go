@@ -0,0 +1,142 @@
+// Package workload defines the contract of workload plugins, executables the
+// client runs to send requests the harness does not know how to send itself.
+//
+// The harness starts the plugin once and, for every request, writes a [Request]
+// as a JSON line to the stdin of the plugin, which sends the request and writes a
+// [Result] as a JSON line to its stdout. The harness logs and times the results like
+// its own requests, so plugins are orchestrated and summarized as any other client.
+// Once all requests are sent, the stdin of the plugin is closed and it must exit.
+//
+// The stderr of the plugin is passed through to the stderr of the harness.
+// Plugins written in Go can implement the contract with [Serve].
+package workload
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pessolato/httpmicrobench/pkg/client"
+)
+
+// Request is a request the plugin must send.
+type Request struct {
+	// ID identifies the request, it must be set in its result.
+	ID string `json:"id"`
+	// Target is the URI of the endpoint under test.
+	Target string `json:"target"`
+}
+
+// Result is the outcome of a request sent by the plugin.
+type Result struct {
+	// ID is the ID of the request.
+	ID string `json:"id"`
+	// Error, if not empty, reports the request failed.
+	Error string `json:"error,omitempty"`
+	// StatusCode is the status code of the response, if the protocol has one.
+	StatusCode int `json:"status_code,omitempty"`
+	// TimeNano is the time the request took, measured by the plugin. If
+	// zero, the round trip to the plugin is used, which includes its overhead.
+	TimeNano int64 `json:"time_nano,omitempty"`
+}
+
+// Plugin is a running workload plugin.
+type Plugin struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+	logger *slog.Logger // logger for request timing
+}
+
+// Start starts the plugin executable at path, with the environment of the harness.
+func Start(ctx context.Context, path string, logger *slog.Logger) (*Plugin, error) {
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start workload plugin %s: %w", path, err)
+	}
+	return &Plugin{cmd, stdin, bufio.NewScanner(stdout), logger}, nil
+}
+
+// Repeat has the plugin send n requests to target, one after the other,
+// logging every result as a request completion or failure.
+//
+// Failed requests do not abort the others, but a broken protocol does.
+func (p *Plugin) Repeat(ctx context.Context, n int, target string) error {
+	enc := json.NewEncoder(p.stdin)
+	for range n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		req := Request{ID: rand.Text(), Target: target}
+
+		t1 := time.Now()
+		if err := enc.Encode(req); err != nil {
+			return fmt.Errorf("failed to send request to workload plugin: %w", err)
+		}
+		if !p.stdout.Scan() {
+			return fmt.Errorf("workload plugin exited before answering: %w", errors.Join(p.stdout.Err(), io.ErrUnexpectedEOF))
+		}
+		elapsed := time.Since(t1)
+
+		var res Result
+		if err := json.Unmarshal(p.stdout.Bytes(), &res); err != nil {
+			return fmt.Errorf("invalid result from workload plugin: %w", err)
+		}
+		if res.ID != req.ID {
+			return fmt.Errorf("workload plugin answered request %s with the result of %s", req.ID, res.ID)
+		}
+		if res.Error != "" {
+			p.logger.Error("req failed", "error", res.Error, client.UuidLogField, req.ID)
+			continue
+		}
+		if res.TimeNano != 0 {
+			elapsed = time.Duration(res.TimeNano)
+		}
+		p.logger.Info("req completion", "status_code", res.StatusCode, "max_time_nano", elapsed.Nanoseconds(), client.UuidLogField, req.ID)
+	}
+	return nil
+}
+
+// Close closes the stdin of the plugin and waits for it to exit.
+func (p *Plugin) Close() error {
+	return errors.Join(p.stdin.Close(), p.cmd.Wait())
+}
+
+// Serve implements the plugin side of the contract, calling send for
+// every request read from stdin and writing its result to stdout,
+// until stdin is closed.
+//
+// The ID of the results is set by Serve.
+func Serve(ctx context.Context, send func(ctx context.Context, req Request) Result) error {
+	scn := bufio.NewScanner(os.Stdin)
+	enc := json.NewEncoder(os.Stdout)
+	for scn.Scan() {
+		var req Request
+		if err := json.Unmarshal(scn.Bytes(), &req); err != nil {
+			return fmt.Errorf("invalid request from harness: %w", err)
+		}
+		res := send(ctx, req)
+		res.ID = req.ID
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+	return scn.Err()
+}